@@ -0,0 +1,163 @@
+package nibblers
+
+import (
+	"fmt"
+	"io"
+)
+
+// BitOrder determines the order in which a BitNibbler consumes the bits of each byte.
+type BitOrder int
+
+const (
+	// MostSignificantBitFirst consumes the bits of each byte from the most significant bit down, and the
+	// first bit read is the most significant bit of the value returned by ReadBits.  This is the order used
+	// by most network protocol headers and codec bitstreams.
+	MostSignificantBitFirst BitOrder = iota
+
+	// LeastSignificantBitFirst consumes the bits of each byte from the least significant bit up, and the
+	// first bit read is the least significant bit of the value returned by ReadBits.  This is the order
+	// used by DEFLATE.
+	LeastSignificantBitFirst
+)
+
+// BitNibbler is a wrapper around a ByteNibbler that reads fields of arbitrary bit width.  The cursor of the
+// underlying ByteNibbler always rests on the byte that holds the next unread bit, so when the BitNibbler is
+// aligned to a byte boundary (see AlignToByte), byte-level reading can continue on the underlying ByteNibbler,
+// and bit-level reading can later resume from wherever that leaves the cursor.
+type BitNibbler struct {
+	byteNibbler                    ByteNibbler
+	bitOrder                       BitOrder
+	countOfBitsReadFromCurrentByte int
+}
+
+// NewBitNibbler creates a new BitNibbler using the provided ByteNibbler as the Read source, starting at its
+// cursor.
+func NewBitNibbler(byteNibbler ByteNibbler, bitOrder BitOrder) *BitNibbler {
+	return &BitNibbler{
+		byteNibbler:                    byteNibbler,
+		bitOrder:                       bitOrder,
+		countOfBitsReadFromCurrentByte: 0,
+	}
+}
+
+// ReadBits reads the next countOfBits bits, which must be between 1 and 64, and returns them as the low
+// countOfBits bits of the returned value.  If the stream ends before countOfBits bits have been read,
+// io.ErrUnexpectedEOF is returned (or io.EOF, if no bits remain) and the cursor is not moved.
+func (nibbler *BitNibbler) ReadBits(countOfBits int) (uint64, error) {
+	if countOfBits < 1 || countOfBits > 64 {
+		return 0, fmt.Errorf("bit count (%d) must be between 1 and 64", countOfBits)
+	}
+
+	mark := nibbler.byteNibbler.Mark()
+	defer nibbler.byteNibbler.Release(mark)
+	countOfBitsReadFromByteAtMark := nibbler.countOfBitsReadFromCurrentByte
+
+	var value uint64
+	for countOfBitsRead := 0; countOfBitsRead < countOfBits; {
+		currentByte, err := nibbler.byteNibbler.PeekAtNextByte()
+		if err != nil {
+			nibbler.byteNibbler.ResetTo(mark)
+			nibbler.countOfBitsReadFromCurrentByte = countOfBitsReadFromByteAtMark
+
+			if err == io.EOF && countOfBitsRead > 0 {
+				return 0, io.ErrUnexpectedEOF
+			}
+
+			return 0, err
+		}
+
+		countOfBitsToTake := 8 - nibbler.countOfBitsReadFromCurrentByte
+		if countOfBitsToTake > countOfBits-countOfBitsRead {
+			countOfBitsToTake = countOfBits - countOfBitsRead
+		}
+
+		maskForBitsToTake := byte(1<<uint(countOfBitsToTake) - 1)
+
+		if nibbler.bitOrder == LeastSignificantBitFirst {
+			takenBits := (currentByte >> uint(nibbler.countOfBitsReadFromCurrentByte)) & maskForBitsToTake
+			value |= uint64(takenBits) << uint(countOfBitsRead)
+		} else {
+			takenBits := (currentByte >> uint(8-nibbler.countOfBitsReadFromCurrentByte-countOfBitsToTake)) & maskForBitsToTake
+			value = value<<uint(countOfBitsToTake) | uint64(takenBits)
+		}
+
+		countOfBitsRead += countOfBitsToTake
+		nibbler.countOfBitsReadFromCurrentByte += countOfBitsToTake
+
+		if nibbler.countOfBitsReadFromCurrentByte == 8 {
+			nibbler.byteNibbler.ReadByte()
+			nibbler.countOfBitsReadFromCurrentByte = 0
+		}
+	}
+
+	return value, nil
+}
+
+// PeekAtNextBits returns the next countOfBits bits in the same way as ReadBits, but does not advance the
+// cursor.
+func (nibbler *BitNibbler) PeekAtNextBits(countOfBits int) (uint64, error) {
+	mark := nibbler.byteNibbler.Mark()
+	defer nibbler.byteNibbler.Release(mark)
+	countOfBitsReadFromByteAtMark := nibbler.countOfBitsReadFromCurrentByte
+
+	value, err := nibbler.ReadBits(countOfBits)
+
+	nibbler.byteNibbler.ResetTo(mark)
+	nibbler.countOfBitsReadFromCurrentByte = countOfBitsReadFromByteAtMark
+
+	return value, err
+}
+
+// UnreadBits moves the cursor back by countOfBits bits.  Moving back past the start of the current byte
+// unreads bytes from the underlying ByteNibbler, so an error is returned (and the cursor is not moved) if
+// the ByteNibbler cannot unread far enough.
+func (nibbler *BitNibbler) UnreadBits(countOfBits int) error {
+	if countOfBits < 0 {
+		return fmt.Errorf("bit count (%d) must not be negative", countOfBits)
+	}
+
+	countOfBitsReadFromCurrentByte := nibbler.countOfBitsReadFromCurrentByte - countOfBits
+
+	countOfUnreadBytes := 0
+	for ; countOfBitsReadFromCurrentByte < 0; countOfBitsReadFromCurrentByte += 8 {
+		if err := nibbler.byteNibbler.UnreadByte(); err != nil {
+			for ; countOfUnreadBytes > 0; countOfUnreadBytes-- {
+				nibbler.byteNibbler.ReadByte()
+			}
+
+			return err
+		}
+
+		countOfUnreadBytes++
+	}
+
+	nibbler.countOfBitsReadFromCurrentByte = countOfBitsReadFromCurrentByte
+
+	return nil
+}
+
+// AlignToByte discards the unread bits of a partially read byte, so that the next read starts at a byte
+// boundary.  It returns the number of discarded bits, which is zero if the cursor is already aligned.
+func (nibbler *BitNibbler) AlignToByte() int {
+	if nibbler.countOfBitsReadFromCurrentByte == 0 {
+		return 0
+	}
+
+	countOfDiscardedBits := 8 - nibbler.countOfBitsReadFromCurrentByte
+	nibbler.byteNibbler.ReadByte()
+	nibbler.countOfBitsReadFromCurrentByte = 0
+
+	return countOfDiscardedBits
+}
+
+// IsAlignedToByte returns true if the next unread bit is the first bit of a byte.
+func (nibbler *BitNibbler) IsAlignedToByte() bool {
+	return nibbler.countOfBitsReadFromCurrentByte == 0
+}
+
+// UnderlyingNibbler returns the ByteNibbler used by the BitNibbler.  If the BitNibbler is aligned to a byte
+// boundary, the next byte read from the ByteNibbler is the byte following the last bit read.  Otherwise, it
+// is the partially read byte.
+func (nibbler *BitNibbler) UnderlyingNibbler() ByteNibbler {
+	return nibbler.byteNibbler
+}
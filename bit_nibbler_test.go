@@ -0,0 +1,137 @@
+package nibblers_test
+
+import (
+	"fmt"
+	"io"
+	"testing"
+
+	nibblers "github.com/blorticus-go/nibblers"
+	mock "github.com/blorticus/go-test-mocks"
+)
+
+type bitNibblerTestStep struct {
+	operation     string // "Read", "Peek", "Unread", "Align" or "ReadByte"
+	countOfBits   int
+	expectedValue uint64
+	expectedError error
+	expectAnError bool
+}
+
+func (step *bitNibblerTestStep) runAgainst(nibbler *nibblers.BitNibbler) error {
+	var value uint64
+	var err error
+
+	switch step.operation {
+	case "Read":
+		value, err = nibbler.ReadBits(step.countOfBits)
+	case "Peek":
+		value, err = nibbler.PeekAtNextBits(step.countOfBits)
+	case "Unread":
+		err = nibbler.UnreadBits(step.countOfBits)
+	case "Align":
+		value = uint64(nibbler.AlignToByte())
+	case "ReadByte":
+		if !nibbler.IsAlignedToByte() {
+			return fmt.Errorf("expected bit nibbler to be aligned before ReadByte")
+		}
+		var b byte
+		b, err = nibbler.UnderlyingNibbler().ReadByte()
+		value = uint64(b)
+	default:
+		return fmt.Errorf("invalid step operation (%s)", step.operation)
+	}
+
+	if step.expectAnError {
+		if err == nil {
+			return fmt.Errorf("expected an error, got no error")
+		}
+		return nil
+	}
+
+	if err != step.expectedError {
+		return fmt.Errorf("expected error (%v), got (%v)", step.expectedError, err)
+	}
+
+	if value != step.expectedValue {
+		return fmt.Errorf("expected value (0x%x), got (0x%x)", step.expectedValue, value)
+	}
+
+	return nil
+}
+
+func testBitNibblerSteps(byteNibbler nibblers.ByteNibbler, bitOrder nibblers.BitOrder, steps []*bitNibblerTestStep, baseTestName string, t *testing.T) {
+	nibbler := nibblers.NewBitNibbler(byteNibbler, bitOrder)
+
+	for stepIndex, step := range steps {
+		if err := step.runAgainst(nibbler); err != nil {
+			t.Errorf("(%s) (step %d: %s %d) %s", baseTestName, stepIndex+1, step.operation, step.countOfBits, err.Error())
+		}
+	}
+}
+
+func TestBitNibbler(t *testing.T) {
+	stream := []byte{0xb5, 0x3c, 0xff, 0x01, 0x80, 0x7e, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xa5}
+
+	mostSignificantBitFirstSteps := []*bitNibblerTestStep{
+		{operation: "Read", countOfBits: 0, expectAnError: true},
+		{operation: "Read", countOfBits: 65, expectAnError: true},
+		{operation: "Read", countOfBits: 1, expectedValue: 0x1},
+		{operation: "Read", countOfBits: 3, expectedValue: 0x3},
+		{operation: "Peek", countOfBits: 8, expectedValue: 0x53},
+		{operation: "Read", countOfBits: 12, expectedValue: 0x53c},
+		{operation: "Unread", countOfBits: 6, expectedValue: 0},
+		{operation: "Read", countOfBits: 2, expectedValue: 0x3},
+		{operation: "Align", expectedValue: 4},
+		{operation: "Align", expectedValue: 0},
+		{operation: "ReadByte", expectedValue: 0xff},
+		{operation: "Read", countOfBits: 9, expectedValue: 0x003},
+		{operation: "Read", countOfBits: 7, expectedValue: 0x00},
+		{operation: "Read", countOfBits: 64, expectedValue: 0x7e00000000000000},
+		{operation: "Read", countOfBits: 16, expectedError: io.ErrUnexpectedEOF},
+		{operation: "Read", countOfBits: 8, expectedValue: 0xa5},
+		{operation: "Read", countOfBits: 1, expectedError: io.EOF},
+		{operation: "Unread", countOfBits: 3},
+		{operation: "Read", countOfBits: 3, expectedValue: 0x5},
+	}
+
+	leastSignificantBitFirstSteps := []*bitNibblerTestStep{
+		{operation: "Read", countOfBits: 1, expectedValue: 0x1},
+		{operation: "Read", countOfBits: 3, expectedValue: 0x2},
+		{operation: "Peek", countOfBits: 8, expectedValue: 0xcb},
+		{operation: "Read", countOfBits: 12, expectedValue: 0x3cb},
+		{operation: "Unread", countOfBits: 6},
+		{operation: "Read", countOfBits: 2, expectedValue: 0x3},
+		{operation: "Align", expectedValue: 4},
+		{operation: "ReadByte", expectedValue: 0xff},
+		{operation: "Read", countOfBits: 9, expectedValue: 0x001},
+		{operation: "Read", countOfBits: 7, expectedValue: 0x40},
+		{operation: "Read", countOfBits: 64, expectedValue: 0x7e},
+	}
+
+	testBitNibblerSteps(nibblers.NewByteSliceNibbler(stream), nibblers.MostSignificantBitFirst, mostSignificantBitFirstSteps, "TestBitNibbler MSB-first ByteSliceNibbler", t)
+	testBitNibblerSteps(nibblers.NewByteSliceNibbler(stream), nibblers.LeastSignificantBitFirst, leastSignificantBitFirstSteps, "TestBitNibbler LSB-first ByteSliceNibbler", t)
+
+	reader := mock.NewReader()
+	for i := 0; i < len(stream); i += 3 {
+		end := i + 3
+		if end > len(stream) {
+			end = len(stream)
+		}
+		reader.AddGoodRead(stream[i:end])
+	}
+
+	testBitNibblerSteps(nibblers.NewByteReaderNibbler(reader.AddEOF()), nibblers.MostSignificantBitFirst, mostSignificantBitFirstSteps, "TestBitNibbler MSB-first ByteReaderNibbler", t)
+}
+
+func TestBitNibblerUnreadBeyondStart(t *testing.T) {
+	nibbler := nibblers.NewBitNibbler(nibblers.NewByteSliceNibbler([]byte{0xf0, 0x0f}), nibblers.MostSignificantBitFirst)
+
+	nibbler.ReadBits(12)
+	if err := nibbler.UnreadBits(13); err == nil {
+		t.Errorf("(TestBitNibblerUnreadBeyondStart) expected error on UnreadBits past start of stream, got no error")
+	}
+
+	if value, err := nibbler.ReadBits(4); err != nil || value != 0xf {
+		t.Errorf("(TestBitNibblerUnreadBeyondStart) expected (0xf) after failed UnreadBits, got (0x%x) and (%v)", value, err)
+	}
+}
@@ -1,47 +1,26 @@
 package nibblers
 
 import (
-	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
 )
 
-// NamedByteSetsMap stores sets of bytes, associated with a name.  These can
-// be provided to ByteNibblers when reading a string of characters from the input stream to
-// determine which characters are allowed as part of the read.
-type NamedByteSetsMap struct {
-	mapOfSetsByName map[string]map[byte]bool
-}
-
-// NewNamedByteSetsMap creates a new empty map.
-func NewNamedByteSetsMap() *NamedByteSetsMap {
-	return &NamedByteSetsMap{
-		mapOfSetsByName: make(map[string]map[byte]bool),
-	}
-}
-
-// AddNamedByteSetFromString treats stringOfBytes as a series of bytes.  The set is added to the SetsMap with the
-// provided name.
-func (setsMap *NamedByteSetsMap) AddNamedByteSetFromString(nameOfSet string, stringOfBytes string) *NamedByteSetsMap {
-	return setsMap.AddNamedByteSetFromByteArray(nameOfSet, []byte(stringOfBytes))
-}
-
-// AddNamedByteSetFromByteArray adds the bytes in byteArray as a byte set with the provided name.
-func (setsMap *NamedByteSetsMap) AddNamedByteSetFromByteArray(nameOfSet string, byteArray []byte) *NamedByteSetsMap {
-	mapOfBytes := make(map[byte]bool)
+// DefaultMaximumUnreadDepth is the number of already read bytes that a reader-backed nibbler
+// retains (and thus can be unread) unless a different depth is set.
+const DefaultMaximumUnreadDepth = 4096
 
-	for _, nextByte := range byteArray {
-		mapOfBytes[nextByte] = true
-	}
+// UnlimitedUnreadDepth can be provided as the maximum unread depth for a reader-backed nibbler
+// to instruct it never to discard read bytes.  This means that the nibbler's internal buffer
+// will grow to the size of the entire stream.
+const UnlimitedUnreadDepth = -1
 
-	setsMap.mapOfSetsByName[nameOfSet] = mapOfBytes
-
-	return setsMap
-}
-
-func (setsMap *NamedByteSetsMap) retrieveNamedCharacterSet(nameOfSet string) map[byte]bool {
-	return setsMap.mapOfSetsByName[nameOfSet]
-}
+// ErrDetached is returned when a reader-backed nibbler would need to read from its stream after
+// Remainder has handed the rest of the stream to the caller.
+var ErrDetached = errors.New("nibbler is detached from its stream")
 
 // ByteNibbler is an interface for dealing with a byte buffer or byte stream one byte-at-a-time
 // or in chunks based on character sets.  One can read a byte from the stream, return a read byte to the stream,
@@ -51,33 +30,133 @@ type ByteNibbler interface {
 	ReadByte() (byte, error)
 	UnreadByte() error
 	PeekAtNextByte() (byte, error)
+
+	// PeekAtNextBytes returns the next countOfBytesToPeek bytes without advancing the cursor.  If fewer bytes
+	// remain in the stream, the remaining bytes are returned with io.EOF.
+	PeekAtNextBytes(countOfBytesToPeek uint) ([]byte, error)
+
+	// Read reads up to len(p) bytes from the cursor into p and advances the cursor past them, so that a
+	// ByteNibbler is an io.Reader (and, with ReadByte and UnreadByte, an io.ByteScanner).  Bytes that the
+	// nibbler has already buffered are returned before the underlying stream is read again.  If p is
+	// empty, Read returns 0 and no error without examining the stream.
+	Read(p []byte) (int, error)
+
+	// WriteTo writes every byte from the cursor to the end of the stream to w, advancing the cursor past
+	// the written bytes.  It returns the number of bytes written.  Reaching the end of the stream is not an
+	// error.
+	WriteTo(w io.Writer) (int64, error)
+
 	AddNamedByteSetsMap(*NamedByteSetsMap)
 	ReadNextBytesMatchingSet(setName string) ([]byte, error)
 	ReadNextBytesNotMatchingSet(setName string) ([]byte, error)
 	ReadFixedNumberOfBytes(countOfBytesToRead uint) ([]byte, error)
+
+	// ReadUntilSequence reads bytes up to the delimiter sequence, which is handled as directed by handling.
+	// If the delimiter does not start within maximumLength bytes of the cursor (unless maximumLength is
+	// UnlimitedSequenceLength), ErrSequenceLengthExceeded is returned and the cursor is not moved.  If the
+	// stream ends before the delimiter, the remaining bytes are read and returned with io.EOF.
+	ReadUntilSequence(delimiter []byte, handling DelimiterHandling, maximumLength int) ([]byte, error)
+
+	// SkipUntilSequence does the same thing as ReadUntilSequence, but discards the bytes rather than returning
+	// them.  It returns the number of bytes by which the cursor moved.
+	SkipUntilSequence(delimiter []byte, handling DelimiterHandling, maximumLength int) (int, error)
+
+	// The fixed-width Read methods decode the next bytes as an integer or IEEE 754 floating point number and
+	// advance the cursor past them.  The PeekAtNext methods do the same without advancing the cursor.  If the
+	// stream ends before enough bytes are available, a *PartialReadError is returned (or io.EOF, if no bytes
+	// remain) and the cursor is not moved.
+	ReadUint8() (uint8, error)
+	ReadUint16(order binary.ByteOrder) (uint16, error)
+	ReadUint24(order binary.ByteOrder) (uint32, error)
+	ReadUint32(order binary.ByteOrder) (uint32, error)
+	ReadUint64(order binary.ByteOrder) (uint64, error)
+	ReadInt8() (int8, error)
+	ReadInt16(order binary.ByteOrder) (int16, error)
+	ReadInt24(order binary.ByteOrder) (int32, error)
+	ReadInt32(order binary.ByteOrder) (int32, error)
+	ReadInt64(order binary.ByteOrder) (int64, error)
+	ReadFloat32(order binary.ByteOrder) (float32, error)
+	ReadFloat64(order binary.ByteOrder) (float64, error)
+	PeekAtNextUint8() (uint8, error)
+	PeekAtNextUint16(order binary.ByteOrder) (uint16, error)
+	PeekAtNextUint24(order binary.ByteOrder) (uint32, error)
+	PeekAtNextUint32(order binary.ByteOrder) (uint32, error)
+	PeekAtNextUint64(order binary.ByteOrder) (uint64, error)
+	PeekAtNextInt8() (int8, error)
+	PeekAtNextInt16(order binary.ByteOrder) (int16, error)
+	PeekAtNextInt24(order binary.ByteOrder) (int32, error)
+	PeekAtNextInt32(order binary.ByteOrder) (int32, error)
+	PeekAtNextInt64(order binary.ByteOrder) (int64, error)
+	PeekAtNextFloat32(order binary.ByteOrder) (float32, error)
+	PeekAtNextFloat64(order binary.ByteOrder) (float64, error)
+
+	// The variable-length integer Read methods decode a varint and advance the cursor past it.  If the varint
+	// is truncated or malformed, an error is returned and the cursor is left at the start of the varint.
+	ReadUnsignedLEB128() (uint64, error)
+	ReadSignedLEB128() (int64, error)
+	ReadZigZagVarint() (int64, error)
+	ReadQUICVarint() (uint64, error)
+	PeekAtNextQUICVarint() (uint64, error)
+
+	// StartBookending instructs the nibbler to preserve the bytes that are read, starting with the next unread
+	// byte (though that byte may have been peeked).  The bookend start is implicitly a checkpoint.
+	StartBookending() error
+
+	// BookendCheckpoint returns the bytes read since the last checkpoint, through the most recently read (but
+	// not peeked) byte.  If there is no active bookend, an empty slice is returned.
+	BookendCheckpoint() []byte
+
+	// StopBookending stops the bookend at the last read (but not peeked) byte and returns the bytes between
+	// the bookends.  If there is no active bookend, an empty slice is returned.
+	StopBookending() []byte
+
+	// Mark returns a savepoint at the cursor.  Marks may be nested.  While a Mark is live, the nibbler retains
+	// every byte from the Mark onward, so that ResetTo the Mark always succeeds.
+	Mark() Mark
+
+	// ResetTo moves the cursor to the provided Mark, which remains live.  If an active bookend starts after the
+	// Mark, the bookend is moved to start at the Mark.  Return ErrInvalidMark if the Mark is not live.
+	ResetTo(mark Mark) error
+
+	// Release releases the provided Mark and every Mark created after it.  This permits the nibbler to discard
+	// bytes that it retained for the Marks.
+	Release(mark Mark)
 }
 
 // ByteSliceNibbler is a ByteNibbler using a static byte buffer.  A ReadByte or PeekAtNextbyte at
 // the end of the slice will return io.EOF.
 type ByteSliceNibbler struct {
-	backingBuffer               []byte
-	indexInBufferOfNextReadByte int
-	delegate                    *byteNibblerDelegate
+	backingBuffer                 []byte
+	indexInBufferOfNextReadByte   int
+	indexInBufferOfBookendStart   int // negative if no bookend start is active
+	indexInBufferOfLastCheckpoint int // negative if no bookend start is active
+	marks                         *markRegistry
+	delegate                      *byteNibblerDelegate
+	fixedWidthDecoder
 }
 
 // NewByteSliceNibbler returns a new ByteSliceNibbler using the backing buffer.  Elements of the buffer
 // backing array are not changed by any operation of the ByteSliceNibbler.
 func NewByteSliceNibbler(buffer []byte) *ByteSliceNibbler {
 	nibbler := &ByteSliceNibbler{
-		backingBuffer:               buffer,
-		indexInBufferOfNextReadByte: 0,
+		backingBuffer:                 buffer,
+		indexInBufferOfNextReadByte:   0,
+		indexInBufferOfBookendStart:   -1,
+		indexInBufferOfLastCheckpoint: -1,
+		marks:                         newMarkRegistry(),
 	}
 
 	nibbler.delegate = newByteNibblerDelegate(nibbler)
+	nibbler.fixedWidthDecoder = fixedWidthDecoder{delegate: nibbler.delegate}
 
 	return nibbler
 }
 
+// errorAtCursor wraps err in a NibbleError for the operation op at the cursor.
+func (nibbler *ByteSliceNibbler) errorAtCursor(op string, err error) error {
+	return newNibbleError(op, int64(nibbler.indexInBufferOfNextReadByte), err)
+}
+
 // AddNamedByteSetsMap receives a NamedByteSetsMap, to be used by ReadBytesFromSet().
 func (nibbler *ByteSliceNibbler) AddNamedByteSetsMap(setsMap *NamedByteSetsMap) {
 	nibbler.delegate.namedCharacterSets = setsMap
@@ -100,7 +179,7 @@ func (nibbler *ByteSliceNibbler) ReadByte() (byte, error) {
 // the backing slice is empty or bytes have been unshifted back to the start of the slice.
 func (nibbler *ByteSliceNibbler) UnreadByte() error {
 	if nibbler.indexInBufferOfNextReadByte == 0 {
-		return fmt.Errorf("already at the start of the backing buffer")
+		return newNibbleError("UnreadByte", 0, ErrAtStartOfStream)
 	}
 
 	nibbler.indexInBufferOfNextReadByte--
@@ -116,21 +195,101 @@ func (nibbler *ByteSliceNibbler) PeekAtNextByte() (byte, error) {
 	return nibbler.backingBuffer[nibbler.indexInBufferOfNextReadByte], nil
 }
 
+// PeekAtNextBytes returns the next countOfBytesToPeek bytes in the slice without advancing the cursor.  If
+// fewer bytes remain, the remaining bytes are returned with io.EOF.  The returned slice is a subslice of the
+// backing buffer rather than a copy.
+func (nibbler *ByteSliceNibbler) PeekAtNextBytes(countOfBytesToPeek uint) ([]byte, error) {
+	s := nibbler.indexInBufferOfNextReadByte
+
+	if uint(len(nibbler.backingBuffer)-s) < countOfBytesToPeek {
+		return nibbler.backingBuffer[s:len(nibbler.backingBuffer):len(nibbler.backingBuffer)], io.EOF
+	}
+
+	e := s + int(countOfBytesToPeek)
+	return nibbler.backingBuffer[s:e:e], nil
+}
+
+// Read copies up to len(p) bytes from the cursor into p and advances the cursor past them.  If all bytes
+// have been read, then io.EOF is returned, unless p is empty.
+func (nibbler *ByteSliceNibbler) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	if len(nibbler.backingBuffer) <= nibbler.indexInBufferOfNextReadByte {
+		return 0, io.EOF
+	}
+
+	countOfCopiedBytes := copy(p, nibbler.backingBuffer[nibbler.indexInBufferOfNextReadByte:])
+	nibbler.indexInBufferOfNextReadByte += countOfCopiedBytes
+
+	return countOfCopiedBytes, nil
+}
+
+// WriteTo writes the unread bytes of the backing buffer to w with a single Write and advances the cursor
+// past the bytes that w accepted.
+func (nibbler *ByteSliceNibbler) WriteTo(w io.Writer) (int64, error) {
+	unreadBytes := nibbler.backingBuffer[nibbler.indexInBufferOfNextReadByte:]
+	if len(unreadBytes) == 0 {
+		return 0, nil
+	}
+
+	countOfWrittenBytes, err := w.Write(unreadBytes)
+	nibbler.indexInBufferOfNextReadByte += countOfWrittenBytes
+
+	if err == nil && countOfWrittenBytes < len(unreadBytes) {
+		err = io.ErrShortWrite
+	}
+
+	return int64(countOfWrittenBytes), err
+}
+
 // ReadNextBytesMatchingSet reads bytes in the stream as long as they match the characters in the
 // setName (which, in turn, must be supplied to the NamedCharacterSetsMap provided in UseNamedCharacterSetsMap).
 // Return an error if no named character sets map has been provided, if the setName provided is
 // not in that map, or if the stream read produces an error.  Note that this error may be io.EOF.
 // Whether or not an error is returned, the assembled slice of bytes read from the stream is also returned.
 // After this method returns, the nibbler's next byte is the one after the last character in the returned set.
+// The returned slice is a subslice of the backing buffer rather than a copy.
 func (nibbler *ByteSliceNibbler) ReadNextBytesMatchingSet(setName string) ([]byte, error) {
-	return nibbler.delegate.readNextBytesMatchingSet(setName)
+	set, err := nibbler.delegate.retrieveNamedSet(setName)
+	if err != nil {
+		return nil, nibbler.errorAtCursor("ReadNextBytesMatchingSet", err)
+	}
+
+	return nibbler.readNextBytesWhereSetMembershipIs(set, true)
 }
 
 // ReadNextBytesNotMatchingSet is the inverse of ReadNextBytesMatchingSet.  It reads the underlying
 // byte slice from the first byte in the unread stream, returning the contiguous bytes that do not
 // match the bytes in named set.
 func (nibbler *ByteSliceNibbler) ReadNextBytesNotMatchingSet(setName string) ([]byte, error) {
-	return nibbler.delegate.readNextBytesNotMatchingSet(setName)
+	set, err := nibbler.delegate.retrieveNamedSet(setName)
+	if err != nil {
+		return nil, nibbler.errorAtCursor("ReadNextBytesNotMatchingSet", err)
+	}
+
+	return nibbler.readNextBytesWhereSetMembershipIs(set, false)
+}
+
+// readNextBytesWhereSetMembershipIs scans the backing buffer from the cursor while each byte's membership
+// in set is membershipOfReadBytes.  The returned slice shares the backing array, but its capacity is
+// limited so that an append to it cannot alter the backing buffer.
+func (nibbler *ByteSliceNibbler) readNextBytesWhereSetMembershipIs(set *byteSet, membershipOfReadBytes bool) ([]byte, error) {
+	s := nibbler.indexInBufferOfNextReadByte
+	i := s
+
+	for i < len(nibbler.backingBuffer) && set.contains(nibbler.backingBuffer[i]) == membershipOfReadBytes {
+		i++
+	}
+
+	nibbler.indexInBufferOfNextReadByte = i
+
+	if i == len(nibbler.backingBuffer) {
+		return nibbler.backingBuffer[s:i:i], io.EOF
+	}
+
+	return nibbler.backingBuffer[s:i:i], nil
 }
 
 // ReadFixedNumberOfBytes reads a number of bytes from the underlying byte slice equal to countOfBytesToRead.
@@ -151,55 +310,313 @@ func (nibbler *ByteSliceNibbler) ReadFixedNumberOfBytes(countOfBytesToRead uint)
 	return returnSlice, nil
 }
 
+// ReadUntilSequence reads bytes up to the delimiter sequence, which is handled as directed by handling.
+// If the delimiter does not start within maximumLength bytes of the cursor (unless maximumLength is
+// UnlimitedSequenceLength), ErrSequenceLengthExceeded is returned and the cursor is not moved.  If the
+// backing buffer ends before the delimiter, the remaining bytes are read and returned with io.EOF.  The
+// returned slice is a subslice of the backing buffer rather than a copy.
+func (nibbler *ByteSliceNibbler) ReadUntilSequence(delimiter []byte, handling DelimiterHandling, maximumLength int) ([]byte, error) {
+	countOfBytesToAdvance, countOfBytesToReturn, err := nibbler.findSequence(delimiter, handling, maximumLength)
+	if err != nil && err != io.EOF {
+		return nil, nibbler.errorAtCursor("ReadUntilSequence", err)
+	}
+
+	s := nibbler.indexInBufferOfNextReadByte
+	nibbler.indexInBufferOfNextReadByte += countOfBytesToAdvance
+
+	return nibbler.backingBuffer[s : s+countOfBytesToReturn : s+countOfBytesToReturn], err
+}
+
+// SkipUntilSequence does the same thing as ReadUntilSequence, but discards the bytes rather than returning
+// them.  It returns the number of bytes by which the cursor moved.
+func (nibbler *ByteSliceNibbler) SkipUntilSequence(delimiter []byte, handling DelimiterHandling, maximumLength int) (int, error) {
+	countOfBytesToAdvance, _, err := nibbler.findSequence(delimiter, handling, maximumLength)
+	if err != nil && err != io.EOF {
+		return 0, nibbler.errorAtCursor("SkipUntilSequence", err)
+	}
+
+	nibbler.indexInBufferOfNextReadByte += countOfBytesToAdvance
+
+	return countOfBytesToAdvance, err
+}
+
+// findSequence searches the backing buffer from the cursor for the delimiter, returning how far the cursor
+// should move and how many bytes should be returned.
+func (nibbler *ByteSliceNibbler) findSequence(delimiter []byte, handling DelimiterHandling, maximumLength int) (int, int, error) {
+	if len(delimiter) == 0 {
+		return 0, 0, errEmptyDelimiter
+	}
+
+	remainingBytes := nibbler.backingBuffer[nibbler.indexInBufferOfNextReadByte:]
+
+	searchedBytes := remainingBytes
+	if maximumLength >= 0 && len(searchedBytes) > maximumLength+len(delimiter) {
+		searchedBytes = searchedBytes[:maximumLength+len(delimiter)]
+	}
+
+	if countOfBytesBeforeDelimiter := bytes.Index(searchedBytes, delimiter); countOfBytesBeforeDelimiter >= 0 {
+		countOfBytesToAdvance, countOfBytesToReturn := handling.countOfUnitsToAdvance(countOfBytesBeforeDelimiter, len(delimiter))
+		return countOfBytesToAdvance, countOfBytesToReturn, nil
+	}
+
+	if maximumLength >= 0 && len(remainingBytes) > maximumLength {
+		return 0, 0, ErrSequenceLengthExceeded
+	}
+
+	return len(remainingBytes), len(remainingBytes), io.EOF
+}
+
+// StartBookending starts a bookend at the next unread byte.  Return io.EOF if the cursor is
+// already at the end of the slice, or ErrBookendAlreadyActive if a bookend is already active.
+func (nibbler *ByteSliceNibbler) StartBookending() error {
+	if nibbler.indexInBufferOfNextReadByte >= len(nibbler.backingBuffer) {
+		return io.EOF
+	}
+
+	if nibbler.indexInBufferOfBookendStart >= 0 {
+		return nibbler.errorAtCursor("StartBookending", ErrBookendAlreadyActive)
+	}
+
+	nibbler.indexInBufferOfBookendStart = nibbler.indexInBufferOfNextReadByte
+	nibbler.indexInBufferOfLastCheckpoint = nibbler.indexInBufferOfNextReadByte
+
+	return nil
+}
+
+// BookendCheckpoint returns the bytes between the last bookending checkpoint and the last byte read.
+// The returned slice shares the backing array of the slice provided to NewByteSliceNibbler (no copy
+// is made), but its capacity is limited so that appending to it cannot overwrite the backing slice.
+func (nibbler *ByteSliceNibbler) BookendCheckpoint() []byte {
+	if nibbler.indexInBufferOfLastCheckpoint < 0 {
+		return nil
+	}
+
+	s := nibbler.indexInBufferOfLastCheckpoint
+	nibbler.indexInBufferOfLastCheckpoint = nibbler.indexInBufferOfNextReadByte
+
+	return nibbler.backingBuffer[s:nibbler.indexInBufferOfNextReadByte:nibbler.indexInBufferOfNextReadByte]
+}
+
+// StopBookending stops the bookend at the last read byte and returns the bytes between the bookends.
+// As with BookendCheckpoint, the returned slice shares the backing array of the nibbler's slice.
+func (nibbler *ByteSliceNibbler) StopBookending() []byte {
+	if nibbler.indexInBufferOfBookendStart < 0 {
+		return nil
+	}
+
+	s := nibbler.indexInBufferOfBookendStart
+	nibbler.indexInBufferOfBookendStart = -1
+	nibbler.indexInBufferOfLastCheckpoint = -1
+
+	return nibbler.backingBuffer[s:nibbler.indexInBufferOfNextReadByte:nibbler.indexInBufferOfNextReadByte]
+}
+
+// byteOffsetOfCursor returns the offset in the slice of the cursor.
+func (nibbler *ByteSliceNibbler) byteOffsetOfCursor() int64 {
+	return int64(nibbler.indexInBufferOfNextReadByte)
+}
+
+// Mark returns a savepoint at the cursor.
+func (nibbler *ByteSliceNibbler) Mark() Mark {
+	return nibbler.marks.add(nibbler.byteOffsetOfCursor(), 0)
+}
+
+// ResetTo moves the cursor to the provided Mark.  Return ErrInvalidMark if the Mark is not live.
+func (nibbler *ByteSliceNibbler) ResetTo(mark Mark) error {
+	if !nibbler.marks.isLive(mark) {
+		return nibbler.errorAtCursor("ResetTo", ErrInvalidMark)
+	}
+
+	nibbler.indexInBufferOfNextReadByte = int(mark.byteOffset)
+
+	if nibbler.indexInBufferOfLastCheckpoint > nibbler.indexInBufferOfNextReadByte {
+		nibbler.indexInBufferOfLastCheckpoint = nibbler.indexInBufferOfNextReadByte
+	}
+
+	if nibbler.indexInBufferOfBookendStart > nibbler.indexInBufferOfNextReadByte {
+		nibbler.indexInBufferOfBookendStart = nibbler.indexInBufferOfNextReadByte
+	}
+
+	return nil
+}
+
+// Release releases the provided Mark and every Mark created after it.
+func (nibbler *ByteSliceNibbler) Release(mark Mark) {
+	nibbler.marks.release(mark)
+}
+
 // ByteReaderNibbler is a ByteNibbler that uses an io.Reader as its dynamic backing stream.
-// There is no guarantee that the internal buffer representing the pseudo queue grows to
-// the size of all bytes read, so if UnreadByte() is called repeatedly in succession, it may
-// eventually return an error and may not allow a return of every byte previously read.  If
-// a reading action or look-ahead action triggers a Read() of the associated Reader, and that
-// call returns no error, no EOF and zero bytes, an error is raised.  This means that a non-blocking
-// Reader shouldn't be provided.
+// The internal buffer representing the pseudo queue does not grow to the size of all bytes
+// read.  Instead, it retains only the bytes in a rewind window (by default, DefaultMaximumUnreadDepth
+// bytes behind the furthest byte read), discarding older bytes when more data is read from the
+// stream.  Every byte from the start of an active bookend, or from the oldest live Mark, onward is
+// retained until the bookend is stopped or the Mark is released.  If UnreadByte() is called
+// repeatedly in succession, it will eventually return ErrUnreadLimitExceeded.  If a reading action
+// or look-ahead action triggers a Read() of the associated Reader, and that call returns no error,
+// no EOF and zero bytes, the Read is retried as directed by the EmptyReadPolicy (see
+// SetEmptyReadPolicy), after which ErrNoProgress is returned.
 type ByteReaderNibbler struct {
-	backingReader               io.Reader
-	internalBuffer              []byte
-	readBuffer                  []byte
-	indexOfNextReadByteInBuffer int
-	delegate                    *byteNibblerDelegate
+	backingReader                      *contextualStreamReader
+	internalBuffer                     []byte
+	readBuffer                         []byte
+	indexOfNextReadByteInBuffer        int
+	indexInBufferAfterFurthestReadByte int
+	streamOffsetOfInternalBufferStart  int64
+	indexInBufferOfBookendStart        int // negative if no bookend start is active
+	indexInBufferOfLastCheckpoint      int // negative if no bookend start is active
+	maximumUnreadDepth                 int
+	isDetached                         bool
+	marks                              *markRegistry
+	delegate                           *byteNibblerDelegate
+	fixedWidthDecoder
 }
 
 // NewByteReaderNibbler returns a ByteReaderNibbler.
 func NewByteReaderNibbler(streamReader io.Reader) *ByteReaderNibbler {
 	reader := &ByteReaderNibbler{
-		backingReader:               bufio.NewReader(streamReader),
-		readBuffer:                  make([]byte, 9000),
-		internalBuffer:              make([]byte, 0, 18000),
-		indexOfNextReadByteInBuffer: 0,
+		backingReader:                      newContextualStreamReader(streamReader),
+		readBuffer:                         make([]byte, 9000),
+		internalBuffer:                     make([]byte, 0, 18000),
+		indexOfNextReadByteInBuffer:        0,
+		indexInBufferAfterFurthestReadByte: 0,
+		streamOffsetOfInternalBufferStart:  0,
+		indexInBufferOfBookendStart:        -1,
+		indexInBufferOfLastCheckpoint:      -1,
+		maximumUnreadDepth:                 DefaultMaximumUnreadDepth,
+		isDetached:                         false,
+		marks:                              newMarkRegistry(),
 	}
 
 	reader.delegate = newByteNibblerDelegate(reader)
+	reader.fixedWidthDecoder = fixedWidthDecoder{delegate: reader.delegate}
 
 	return reader
 }
 
+// SetContext binds the nibbler to ctx.  When a read from the stream is needed and ctx is done (or becomes
+// done while the stream Read is blocked), the read is abandoned and ctx.Err() is returned.  No bytes from the
+// stream are lost, so the operation can be retried after binding a new context.  If the stream has a
+// SetReadDeadline method (as net.Conn and os.File do), the read deadline is used to interrupt a blocked Read.
+// Otherwise, the Read is made in a goroutine, and if ctx is done first, its result is kept for the next read
+// from the stream.  A nil ctx is the same as context.Background().
+//
+// While the nibbler is bound to a context that can be done, it owns the read deadline of the stream: each
+// Read replaces any deadline set by the caller, and clears it afterward.  While it is bound to a context
+// that can never be done, such as context.Background(), the read deadline is left alone, so the caller may
+// set its own.
+func (nibbler *ByteReaderNibbler) SetContext(ctx context.Context) {
+	nibbler.backingReader.setContext(ctx)
+}
+
+// SetEmptyReadPolicy sets what the nibbler does when a Read of the stream returns no bytes and no error.
+// By default, ErrNoProgress is returned immediately.
+func (nibbler *ByteReaderNibbler) SetEmptyReadPolicy(policy EmptyReadPolicy) {
+	nibbler.backingReader.setEmptyReadPolicy(policy)
+}
+
+// SetMaximumUnreadDepth sets the number of bytes behind the furthest read byte that the nibbler
+// retains, and thus the number of bytes that can be unread.  Bytes outside of this window are
+// discarded the next time the nibbler reads from the underlying stream.  If depth is
+// UnlimitedUnreadDepth (or any negative value), no bytes are ever discarded.  Reducing the depth
+// does not restore bytes that have already been discarded.
+func (nibbler *ByteReaderNibbler) SetMaximumUnreadDepth(depth int) {
+	nibbler.maximumUnreadDepth = depth
+}
+
 // AddNamedByteSetsMap receives a NamedCharacterSetsMap, to be used by ReadBytesFromSet().
 func (nibbler *ByteReaderNibbler) AddNamedByteSetsMap(setsMap *NamedByteSetsMap) {
 	nibbler.delegate.addNamedCharacterSetsMap(setsMap)
 }
 
+// errorAtCursor wraps err in a NibbleError for the operation op at the cursor.
+func (nibbler *ByteReaderNibbler) errorAtCursor(op string, err error) error {
+	return newNibbleError(op, nibbler.streamOffsetOfInternalBufferStart+int64(nibbler.indexOfNextReadByteInBuffer), err)
+}
+
+// discardBytesOutsideOfRewindWindow removes bytes from the start of the internal buffer that are
+// more than maximumUnreadDepth behind the furthest read byte (but never a byte at or after the start
+// of an active bookend, the oldest live Mark or the cursor), shifting the retained bytes to the
+// start of the buffer so that the buffer's backing array can be reused.
+func (nibbler *ByteReaderNibbler) discardBytesOutsideOfRewindWindow() {
+	if nibbler.maximumUnreadDepth < 0 {
+		return
+	}
+
+	countOfBytesToDiscard := nibbler.indexInBufferAfterFurthestReadByte - nibbler.maximumUnreadDepth
+	if nibbler.indexInBufferOfBookendStart >= 0 && nibbler.indexInBufferOfBookendStart < countOfBytesToDiscard {
+		countOfBytesToDiscard = nibbler.indexInBufferOfBookendStart
+	}
+
+	if oldestMarkOffset, thereIsALiveMark := nibbler.marks.oldestByteOffset(); thereIsALiveMark {
+		if indexOfOldestMark := int(oldestMarkOffset - nibbler.streamOffsetOfInternalBufferStart); indexOfOldestMark < countOfBytesToDiscard {
+			countOfBytesToDiscard = indexOfOldestMark
+		}
+	}
+
+	// after a ResetTo a Mark that is then released, the cursor can be further back than the unread depth,
+	// and bytes at or after the cursor must be kept
+	if nibbler.indexOfNextReadByteInBuffer < countOfBytesToDiscard {
+		countOfBytesToDiscard = nibbler.indexOfNextReadByteInBuffer
+	}
+
+	if countOfBytesToDiscard <= 0 {
+		return
+	}
+
+	countOfRetainedBytes := copy(nibbler.internalBuffer, nibbler.internalBuffer[countOfBytesToDiscard:])
+	nibbler.internalBuffer = nibbler.internalBuffer[:countOfRetainedBytes]
+	nibbler.indexOfNextReadByteInBuffer -= countOfBytesToDiscard
+	nibbler.indexInBufferAfterFurthestReadByte -= countOfBytesToDiscard
+	nibbler.streamOffsetOfInternalBufferStart += int64(countOfBytesToDiscard)
+
+	if nibbler.indexInBufferOfBookendStart >= 0 {
+		nibbler.indexInBufferOfBookendStart -= countOfBytesToDiscard
+		nibbler.indexInBufferOfLastCheckpoint -= countOfBytesToDiscard
+	}
+}
+
 func (nibbler *ByteReaderNibbler) readFromStreamAndAppendToInternalBuffer() error {
+	if nibbler.isDetached {
+		return newNibbleError("Read", nibbler.streamOffsetOfInternalBufferStart+int64(len(nibbler.internalBuffer)), ErrDetached)
+	}
+
+	nibbler.discardBytesOutsideOfRewindWindow()
+
 	bytesReadFromStream, err := nibbler.backingReader.Read(nibbler.readBuffer)
+	if err == ErrNoProgress {
+		return newNibbleError("Read", nibbler.streamOffsetOfInternalBufferStart+int64(len(nibbler.internalBuffer)), err)
+	}
+
 	if err != nil {
 		return err
 	}
 
-	if bytesReadFromStream > 0 {
-		nibbler.internalBuffer = append(nibbler.internalBuffer, nibbler.readBuffer[:bytesReadFromStream]...)
-	} else {
-		return fmt.Errorf("read of stream returned no bytes, no eof, and no error")
-	}
+	nibbler.internalBuffer = append(nibbler.internalBuffer, nibbler.readBuffer[:bytesReadFromStream]...)
 
 	return nil
 }
 
+// Remainder detaches the nibbler from its stream and returns an io.Reader that yields the bytes that the
+// nibbler has buffered after the cursor, followed by the rest of the stream.  This permits the rest of the
+// stream to be handed to another decoder without losing buffered bytes.  After Remainder, bytes before the
+// cursor can still be unread and re-read, but any operation that would read past the cursor returns
+// ErrDetached.  The EmptyReadPolicy does not apply to the returned io.Reader, which returns an empty Read of
+// the stream as it is.  Calling Remainder again returns an empty io.Reader.
+func (nibbler *ByteReaderNibbler) Remainder() io.Reader {
+	if nibbler.isDetached {
+		return bytes.NewReader(nil)
+	}
+
+	bufferedBytesAfterCursor := append([]byte(nil), nibbler.internalBuffer[nibbler.indexOfNextReadByteInBuffer:]...)
+	remainder := io.MultiReader(bytes.NewReader(bufferedBytesAfterCursor), nibbler.backingReader.detach())
+
+	nibbler.internalBuffer = nibbler.internalBuffer[:nibbler.indexOfNextReadByteInBuffer]
+	nibbler.indexInBufferAfterFurthestReadByte = nibbler.indexOfNextReadByteInBuffer
+	nibbler.isDetached = true
+
+	return remainder
+}
+
 // ReadByte reads the next byte from the stream.  Return io.EOF if the end of the stream
 // has been reached.
 func (nibbler *ByteReaderNibbler) ReadByte() (byte, error) {
@@ -212,15 +629,28 @@ func (nibbler *ByteReaderNibbler) ReadByte() (byte, error) {
 	b := nibbler.internalBuffer[nibbler.indexOfNextReadByteInBuffer]
 	nibbler.indexOfNextReadByteInBuffer++
 
+	if nibbler.indexOfNextReadByteInBuffer > nibbler.indexInBufferAfterFurthestReadByte {
+		nibbler.indexInBufferAfterFurthestReadByte = nibbler.indexOfNextReadByteInBuffer
+	}
+
 	return b, nil
 }
 
 // UnreadByte returns the last read byte back to the buffered stream.  A subsequent ReadByte()
 // will return this same byte.  An error is returned if the stream is empty or if the last
-// read byte is the first byte in the stream.
+// read byte is the first byte in the stream.  ErrUnreadLimitExceeded is returned if the cursor
+// is already at the back of the rewind window (see SetMaximumUnreadDepth).
 func (nibbler *ByteReaderNibbler) UnreadByte() error {
+	if nibbler.indexOfNextReadByteInBuffer < 1 && nibbler.streamOffsetOfInternalBufferStart == 0 {
+		return newNibbleError("UnreadByte", 0, ErrAtStartOfStream)
+	}
+
 	if nibbler.indexOfNextReadByteInBuffer < 1 {
-		return fmt.Errorf("already at the start of the stream buffer")
+		return nibbler.errorAtCursor("UnreadByte", ErrUnreadLimitExceeded)
+	}
+
+	if nibbler.maximumUnreadDepth >= 0 && nibbler.indexInBufferAfterFurthestReadByte-nibbler.indexOfNextReadByteInBuffer >= nibbler.maximumUnreadDepth {
+		return nibbler.errorAtCursor("UnreadByte", ErrUnreadLimitExceeded)
 	}
 
 	nibbler.indexOfNextReadByteInBuffer--
@@ -240,6 +670,72 @@ func (nibbler *ByteReaderNibbler) PeekAtNextByte() (byte, error) {
 	return nibbler.internalBuffer[nibbler.indexOfNextReadByteInBuffer], nil
 }
 
+// PeekAtNextBytes returns a copy of the next countOfBytesToPeek bytes in the stream without advancing the
+// cursor, reading from the stream as needed.  If the stream ends first, the remaining bytes are returned with
+// io.EOF.  If a stream read returns any other error, the bytes that are available are returned with that error.
+func (nibbler *ByteReaderNibbler) PeekAtNextBytes(countOfBytesToPeek uint) ([]byte, error) {
+	for uint(len(nibbler.internalBuffer)-nibbler.indexOfNextReadByteInBuffer) < countOfBytesToPeek {
+		if err := nibbler.readFromStreamAndAppendToInternalBuffer(); err != nil {
+			return append([]byte(nil), nibbler.internalBuffer[nibbler.indexOfNextReadByteInBuffer:]...), err
+		}
+	}
+
+	s := nibbler.indexOfNextReadByteInBuffer
+	return append([]byte(nil), nibbler.internalBuffer[s:s+int(countOfBytesToPeek)]...), nil
+}
+
+// Read copies up to len(p) bytes from the cursor into p and advances the cursor past them.  Bytes that are
+// already in the internal buffer are returned without reading from the stream.  Otherwise, the stream is
+// read once, so Read may return fewer than len(p) bytes before the end of the stream.  Return io.EOF if the
+// end of the stream has been reached.
+func (nibbler *ByteReaderNibbler) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	if nibbler.indexOfNextReadByteInBuffer >= len(nibbler.internalBuffer) {
+		if err := nibbler.readFromStreamAndAppendToInternalBuffer(); err != nil {
+			return 0, err
+		}
+	}
+
+	countOfCopiedBytes := copy(p, nibbler.internalBuffer[nibbler.indexOfNextReadByteInBuffer:])
+	nibbler.advanceCursor(countOfCopiedBytes)
+
+	return countOfCopiedBytes, nil
+}
+
+// WriteTo writes the buffered bytes after the cursor to w, then reads from the stream and writes each chunk
+// to w until the end of the stream is reached.  The written bytes pass through the internal buffer, so they
+// remain subject to the rewind window, bookends and Marks.
+func (nibbler *ByteReaderNibbler) WriteTo(w io.Writer) (int64, error) {
+	var countOfWrittenBytes int64
+
+	for {
+		if unreadBytes := nibbler.internalBuffer[nibbler.indexOfNextReadByteInBuffer:]; len(unreadBytes) > 0 {
+			countOfBytesWrittenFromBuffer, err := w.Write(unreadBytes)
+			nibbler.advanceCursor(countOfBytesWrittenFromBuffer)
+			countOfWrittenBytes += int64(countOfBytesWrittenFromBuffer)
+
+			if err != nil {
+				return countOfWrittenBytes, err
+			}
+
+			if countOfBytesWrittenFromBuffer < len(unreadBytes) {
+				return countOfWrittenBytes, io.ErrShortWrite
+			}
+		}
+
+		if err := nibbler.readFromStreamAndAppendToInternalBuffer(); err != nil {
+			if err == io.EOF {
+				return countOfWrittenBytes, nil
+			}
+
+			return countOfWrittenBytes, err
+		}
+	}
+}
+
 // ReadNextBytesMatchingSet reads bytes in the stream as long as they match the characters in the
 // setName (which, in turn, must be supplied to the NamedCharacterSetsMap provided in UseNamedCharacterSetsMap).
 // Return an error if no named character sets map has been provided, if the setName provided is
@@ -247,14 +743,53 @@ func (nibbler *ByteReaderNibbler) PeekAtNextByte() (byte, error) {
 // Whether or not an error is returned, the assembled slice of bytes read from the stream is also returned.
 // After this method returns, the nibbler's next byte is the one after the last character in the returned set.
 func (nibbler *ByteReaderNibbler) ReadNextBytesMatchingSet(setName string) ([]byte, error) {
-	return nibbler.delegate.readNextBytesMatchingSet(setName)
+	set, err := nibbler.delegate.retrieveNamedSet(setName)
+	if err != nil {
+		return nil, nibbler.errorAtCursor("ReadNextBytesMatchingSet", err)
+	}
+
+	return nibbler.readNextBytesWhereSetMembershipIs(set, true)
 }
 
 // ReadNextBytesNotMatchingSet is the inverse of ReadNextBytesMatchingSet.  It reads the underlying
 // byte slice from the first byte in the unread stream, returning the contiguous bytes that do not
 // match the bytes in named set.
 func (nibbler *ByteReaderNibbler) ReadNextBytesNotMatchingSet(setName string) ([]byte, error) {
-	return nibbler.delegate.readNextBytesNotMatchingSet(setName)
+	set, err := nibbler.delegate.retrieveNamedSet(setName)
+	if err != nil {
+		return nil, nibbler.errorAtCursor("ReadNextBytesNotMatchingSet", err)
+	}
+
+	return nibbler.readNextBytesWhereSetMembershipIs(set, false)
+}
+
+// readNextBytesWhereSetMembershipIs scans the internal buffer from the cursor while each byte's membership
+// in set is membershipOfReadBytes, reading from the stream when the scan reaches the end of the buffer.
+// Accepted bytes are copied out before each stream read, because the read may discard them from the buffer.
+func (nibbler *ByteReaderNibbler) readNextBytesWhereSetMembershipIs(set *byteSet, membershipOfReadBytes bool) ([]byte, error) {
+	acceptedBytes := make([]byte, 0, 20)
+
+	for {
+		if nibbler.indexOfNextReadByteInBuffer >= len(nibbler.internalBuffer) {
+			if err := nibbler.readFromStreamAndAppendToInternalBuffer(); err != nil {
+				return acceptedBytes, err
+			}
+		}
+
+		s := nibbler.indexOfNextReadByteInBuffer
+		i := s
+
+		for i < len(nibbler.internalBuffer) && set.contains(nibbler.internalBuffer[i]) == membershipOfReadBytes {
+			i++
+		}
+
+		acceptedBytes = append(acceptedBytes, nibbler.internalBuffer[s:i]...)
+		nibbler.advanceCursor(i - s)
+
+		if i < len(nibbler.internalBuffer) {
+			return acceptedBytes, nil
+		}
+	}
 }
 
 // ReadFixedNumberOfBytes reads a number of bytes from the underlying byte slice equal to countOfBytesToRead.
@@ -275,6 +810,184 @@ func (nibbler *ByteReaderNibbler) ReadFixedNumberOfBytes(countOfBytesToRead uint
 	return returnSlice, nil
 }
 
+// ReadUntilSequence reads bytes up to the delimiter sequence, which is handled as directed by handling,
+// reading from the stream as needed.  If the delimiter does not start within maximumLength bytes of the
+// cursor (unless maximumLength is UnlimitedSequenceLength), ErrSequenceLengthExceeded is returned and the
+// cursor is not moved.  If the stream ends before the delimiter, the remaining bytes are read and returned
+// with io.EOF.  If a stream read returns any other error, that error is returned and the cursor is not moved.
+// Every byte up to the delimiter is held in the internal buffer until the delimiter is found.
+func (nibbler *ByteReaderNibbler) ReadUntilSequence(delimiter []byte, handling DelimiterHandling, maximumLength int) ([]byte, error) {
+	_, countOfBytesToAdvance, countOfBytesToReturn, err := nibbler.findSequence("ReadUntilSequence", delimiter, handling, maximumLength, false)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	s := nibbler.indexOfNextReadByteInBuffer
+	returnSlice := append([]byte(nil), nibbler.internalBuffer[s:s+countOfBytesToReturn]...)
+	nibbler.advanceCursor(countOfBytesToAdvance)
+
+	return returnSlice, err
+}
+
+// SkipUntilSequence does the same thing as ReadUntilSequence, but discards the bytes rather than returning
+// them.  It returns the number of bytes by which the cursor moved.  If maximumLength is
+// UnlimitedSequenceLength, skipped bytes are not held in the internal buffer while the delimiter is sought,
+// so the cursor may have moved when a stream read returns an error other than io.EOF.
+func (nibbler *ByteReaderNibbler) SkipUntilSequence(delimiter []byte, handling DelimiterHandling, maximumLength int) (int, error) {
+	countOfBytesAdvancedWhileSearching, countOfBytesToAdvance, _, err := nibbler.findSequence("SkipUntilSequence", delimiter, handling, maximumLength, maximumLength < 0)
+	if err != nil && err != io.EOF {
+		return countOfBytesAdvancedWhileSearching, err
+	}
+
+	nibbler.advanceCursor(countOfBytesToAdvance)
+
+	return countOfBytesAdvancedWhileSearching + countOfBytesToAdvance, err
+}
+
+// findSequence searches from the cursor for the delimiter, reading from the stream as needed, and returns
+// how far the cursor should move and how many bytes should be returned.  All counts are relative to the
+// cursor, which stays fixed unless advanceWhileSearching is true.  In that case, the cursor is moved past
+// bytes that cannot be the start of the delimiter before each stream read, so that they can be discarded,
+// and the number of bytes moved this way is also returned.  Errors other than those of the stream are wrapped
+// in a NibbleError for the operation op.
+func (nibbler *ByteReaderNibbler) findSequence(op string, delimiter []byte, handling DelimiterHandling, maximumLength int, advanceWhileSearching bool) (countOfBytesAdvancedWhileSearching int, countOfBytesToAdvance int, countOfBytesToReturn int, err error) {
+	if len(delimiter) == 0 {
+		return 0, 0, 0, nibbler.errorAtCursor(op, errEmptyDelimiter)
+	}
+
+	countOfBytesAlreadySearched := 0
+
+	for {
+		unreadBytes := nibbler.internalBuffer[nibbler.indexOfNextReadByteInBuffer:]
+
+		if i := bytes.Index(unreadBytes[countOfBytesAlreadySearched:], delimiter); i >= 0 {
+			countOfBytesBeforeDelimiter := countOfBytesAlreadySearched + i
+			if maximumLength >= 0 && countOfBytesBeforeDelimiter > maximumLength {
+				return countOfBytesAdvancedWhileSearching, 0, 0, nibbler.errorAtCursor(op, ErrSequenceLengthExceeded)
+			}
+
+			countOfBytesToAdvance, countOfBytesToReturn = handling.countOfUnitsToAdvance(countOfBytesBeforeDelimiter, len(delimiter))
+			return countOfBytesAdvancedWhileSearching, countOfBytesToAdvance, countOfBytesToReturn, nil
+		}
+
+		// the last len(delimiter)-1 bytes may be the start of a delimiter that continues in the next read
+		if countOfBytesAlreadySearched = len(unreadBytes) - len(delimiter) + 1; countOfBytesAlreadySearched < 0 {
+			countOfBytesAlreadySearched = 0
+		}
+
+		if maximumLength >= 0 && countOfBytesAlreadySearched > maximumLength {
+			return countOfBytesAdvancedWhileSearching, 0, 0, nibbler.errorAtCursor(op, ErrSequenceLengthExceeded)
+		}
+
+		if advanceWhileSearching {
+			nibbler.advanceCursor(countOfBytesAlreadySearched)
+			countOfBytesAdvancedWhileSearching += countOfBytesAlreadySearched
+			countOfBytesAlreadySearched = 0
+		}
+
+		if err := nibbler.readFromStreamAndAppendToInternalBuffer(); err != nil {
+			if err != io.EOF {
+				return countOfBytesAdvancedWhileSearching, 0, 0, err
+			}
+
+			countOfRemainingBytes := len(nibbler.internalBuffer) - nibbler.indexOfNextReadByteInBuffer
+			if maximumLength >= 0 && countOfRemainingBytes > maximumLength {
+				return countOfBytesAdvancedWhileSearching, 0, 0, nibbler.errorAtCursor(op, ErrSequenceLengthExceeded)
+			}
+
+			return countOfBytesAdvancedWhileSearching, countOfRemainingBytes, countOfRemainingBytes, io.EOF
+		}
+	}
+}
+
+// advanceCursor moves the cursor forward by countOfBytes bytes, which must already be in the internal buffer.
+func (nibbler *ByteReaderNibbler) advanceCursor(countOfBytes int) {
+	nibbler.indexOfNextReadByteInBuffer += countOfBytes
+
+	if nibbler.indexOfNextReadByteInBuffer > nibbler.indexInBufferAfterFurthestReadByte {
+		nibbler.indexInBufferAfterFurthestReadByte = nibbler.indexOfNextReadByteInBuffer
+	}
+}
+
+// StartBookending starts a bookend at the next unread byte.  Return ErrBookendAlreadyActive if a
+// bookend is already active.
+func (nibbler *ByteReaderNibbler) StartBookending() error {
+	if nibbler.indexInBufferOfBookendStart >= 0 {
+		return nibbler.errorAtCursor("StartBookending", ErrBookendAlreadyActive)
+	}
+
+	nibbler.indexInBufferOfBookendStart = nibbler.indexOfNextReadByteInBuffer
+	nibbler.indexInBufferOfLastCheckpoint = nibbler.indexOfNextReadByteInBuffer
+
+	return nil
+}
+
+// BookendCheckpoint returns the bytes between the last bookending checkpoint and the last byte read.
+// Because the internal buffer is reused, the returned slice is a copy.
+func (nibbler *ByteReaderNibbler) BookendCheckpoint() []byte {
+	if nibbler.indexInBufferOfLastCheckpoint < 0 {
+		return nil
+	}
+
+	s := nibbler.indexInBufferOfLastCheckpoint
+	nibbler.indexInBufferOfLastCheckpoint = nibbler.indexOfNextReadByteInBuffer
+
+	return append([]byte(nil), nibbler.internalBuffer[s:nibbler.indexOfNextReadByteInBuffer]...)
+}
+
+// StopBookending stops the bookend at the last read byte and returns a copy of the bytes between
+// the bookends.
+func (nibbler *ByteReaderNibbler) StopBookending() []byte {
+	if nibbler.indexInBufferOfBookendStart < 0 {
+		return nil
+	}
+
+	s := nibbler.indexInBufferOfBookendStart
+	nibbler.indexInBufferOfBookendStart = -1
+	nibbler.indexInBufferOfLastCheckpoint = -1
+
+	return append([]byte(nil), nibbler.internalBuffer[s:nibbler.indexOfNextReadByteInBuffer]...)
+}
+
+// byteOffsetOfCursor returns the offset in the stream of the cursor.
+func (nibbler *ByteReaderNibbler) byteOffsetOfCursor() int64 {
+	return nibbler.streamOffsetOfInternalBufferStart + int64(nibbler.indexOfNextReadByteInBuffer)
+}
+
+// Mark returns a savepoint at the cursor.
+func (nibbler *ByteReaderNibbler) Mark() Mark {
+	return nibbler.marks.add(nibbler.byteOffsetOfCursor(), 0)
+}
+
+// ResetTo moves the cursor to the provided Mark.  Return ErrInvalidMark if the Mark is not live.
+func (nibbler *ByteReaderNibbler) ResetTo(mark Mark) error {
+	if !nibbler.marks.isLive(mark) {
+		return nibbler.errorAtCursor("ResetTo", ErrInvalidMark)
+	}
+
+	// a Mark after the cursor is beyond the internal buffer once the nibbler is detached
+	if int(mark.byteOffset-nibbler.streamOffsetOfInternalBufferStart) > len(nibbler.internalBuffer) {
+		return nibbler.errorAtCursor("ResetTo", ErrInvalidMark)
+	}
+
+	nibbler.indexOfNextReadByteInBuffer = int(mark.byteOffset - nibbler.streamOffsetOfInternalBufferStart)
+
+	if nibbler.indexInBufferOfLastCheckpoint > nibbler.indexOfNextReadByteInBuffer {
+		nibbler.indexInBufferOfLastCheckpoint = nibbler.indexOfNextReadByteInBuffer
+	}
+
+	if nibbler.indexInBufferOfBookendStart > nibbler.indexOfNextReadByteInBuffer {
+		nibbler.indexInBufferOfBookendStart = nibbler.indexOfNextReadByteInBuffer
+	}
+
+	return nil
+}
+
+// Release releases the provided Mark and every Mark created after it.
+func (nibbler *ByteReaderNibbler) Release(mark Mark) {
+	nibbler.marks.release(mark)
+}
+
 // The implementation of a ByteSliceNibbler and ByteReaderNibbler are mostly the same, except
 // for the actual per-byte stream manipulation function.  This underlying type is used by both
 // which then use composition to delegate the common functions.
@@ -294,56 +1007,23 @@ func (delegate *byteNibblerDelegate) addNamedCharacterSetsMap(setMap *NamedByteS
 	delegate.namedCharacterSets = setMap
 }
 
-func (delegate *byteNibblerDelegate) readNextBytesMatchingSet(setName string) ([]byte, error) {
-	if delegate.namedCharacterSets == nil {
-		return nil, fmt.Errorf("no character set with that name is defined")
-	}
-
-	setMap := delegate.namedCharacterSets.retrieveNamedCharacterSet(setName)
-	if setMap == nil {
-		return nil, fmt.Errorf("no character set with that name is defined")
-	}
-
-	matchingContiguousBytes := make([]byte, 0, 20)
-
-	for {
-		character, err := delegate.actualNibbler.ReadByte()
-		if err != nil {
-			return matchingContiguousBytes, err
-		}
-
-		if _, characterIsInMap := setMap[character]; characterIsInMap {
-			matchingContiguousBytes = append(matchingContiguousBytes, character)
-		} else {
-			_ = delegate.actualNibbler.UnreadByte()
-			return matchingContiguousBytes, nil
-		}
-	}
+// errorAtCursor wraps err in a NibbleError for the operation op at the cursor of the nibbler for which the
+// delegate acts.  Both ByteNibblers in this package track the stream offset of the cursor.
+func (delegate *byteNibblerDelegate) errorAtCursor(op string, err error) error {
+	return newNibbleError(op, delegate.actualNibbler.(byteOffsetTracker).byteOffsetOfCursor(), err)
 }
 
-func (delegate *byteNibblerDelegate) readNextBytesNotMatchingSet(setName string) ([]byte, error) {
+// retrieveNamedSet returns the set named setName, or an error wrapping ErrUnknownSet if there is no such set.
+// The caller wraps the error in a NibbleError.
+func (delegate *byteNibblerDelegate) retrieveNamedSet(setName string) (*byteSet, error) {
 	if delegate.namedCharacterSets == nil {
-		return nil, fmt.Errorf("no character set with that name is defined")
+		return nil, fmt.Errorf("%w (%s): no NamedByteSetsMap has been added", ErrUnknownSet, setName)
 	}
 
-	setMap := delegate.namedCharacterSets.retrieveNamedCharacterSet(setName)
-	if setMap == nil {
-		return nil, fmt.Errorf("no character set with that name is defined")
+	set := delegate.namedCharacterSets.retrieveNamedCharacterSet(setName)
+	if set == nil {
+		return nil, fmt.Errorf("%w (%s)", ErrUnknownSet, setName)
 	}
 
-	nonMatchingContiguousBytes := make([]byte, 0, 20)
-
-	for {
-		character, err := delegate.actualNibbler.ReadByte()
-		if err != nil {
-			return nonMatchingContiguousBytes, err
-		}
-
-		if _, characterIsInMap := setMap[character]; !characterIsInMap {
-			nonMatchingContiguousBytes = append(nonMatchingContiguousBytes, character)
-		} else {
-			_ = delegate.actualNibbler.UnreadByte()
-			return nonMatchingContiguousBytes, nil
-		}
-	}
+	return set, nil
 }
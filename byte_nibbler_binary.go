@@ -0,0 +1,402 @@
+package nibblers
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// ErrVarintOverflow is returned by the variable-length integer reads (e.g., ReadUnsignedLEB128) when the
+// encoded value does not fit in 64 bits.  The cursor is left at the start of the varint.
+var ErrVarintOverflow = errors.New("varint overflows 64 bits")
+
+// PartialReadError is returned by the fixed-width Read and Peek methods (e.g., ReadUint32) when the stream
+// ends after at least one, but fewer than the required number of bytes.  The cursor is not moved.  If no
+// bytes remain, io.EOF is returned instead.  errors.Is(err, io.ErrUnexpectedEOF) is true for a PartialReadError.
+type PartialReadError struct {
+	RequiredBytes  int
+	AvailableBytes int
+}
+
+func (err *PartialReadError) Error() string {
+	return fmt.Sprintf("required %d bytes but only %d were available before the end of the stream", err.RequiredBytes, err.AvailableBytes)
+}
+
+// Unwrap returns io.ErrUnexpectedEOF.
+func (err *PartialReadError) Unwrap() error {
+	return io.ErrUnexpectedEOF
+}
+
+// readFixedWidth returns the next width bytes.  If consumeBytes is true, the cursor is moved past them.
+// The cursor is not moved if fewer than width bytes are available.  A PartialReadError is wrapped in a
+// NibbleError for the operation op.
+func (delegate *byteNibblerDelegate) readFixedWidth(op string, width int, consumeBytes bool) ([]byte, error) {
+	nextBytes, err := delegate.actualNibbler.PeekAtNextBytes(uint(width))
+	if err != nil {
+		if err == io.EOF && len(nextBytes) > 0 {
+			return nil, delegate.errorAtCursor(op, &PartialReadError{RequiredBytes: width, AvailableBytes: len(nextBytes)})
+		}
+
+		return nil, err
+	}
+
+	if consumeBytes {
+		if _, err := delegate.actualNibbler.ReadFixedNumberOfBytes(uint(width)); err != nil {
+			return nil, err
+		}
+	}
+
+	return nextBytes, nil
+}
+
+// readUnsigned decodes the next width bytes (1, 2, 3, 4 or 8) as an unsigned integer.
+func (delegate *byteNibblerDelegate) readUnsigned(op string, width int, order binary.ByteOrder, consumeBytes bool) (uint64, error) {
+	nextBytes, err := delegate.readFixedWidth(op, width, consumeBytes)
+	if err != nil {
+		return 0, err
+	}
+
+	switch width {
+	case 1:
+		return uint64(nextBytes[0]), nil
+	case 2:
+		return uint64(order.Uint16(nextBytes)), nil
+	case 3:
+		if byteOrderIsBigEndian(order) {
+			return uint64(order.Uint32([]byte{0, nextBytes[0], nextBytes[1], nextBytes[2]})), nil
+		}
+		return uint64(order.Uint32([]byte{nextBytes[0], nextBytes[1], nextBytes[2], 0})), nil
+	case 4:
+		return uint64(order.Uint32(nextBytes)), nil
+	default:
+		return order.Uint64(nextBytes), nil
+	}
+}
+
+// readSigned decodes the next width bytes (1, 2, 3, 4 or 8) as a two's complement signed integer.
+func (delegate *byteNibblerDelegate) readSigned(op string, width int, order binary.ByteOrder, consumeBytes bool) (int64, error) {
+	unsignedValue, err := delegate.readUnsigned(op, width, order, consumeBytes)
+	if err != nil {
+		return 0, err
+	}
+
+	unusedBits := uint(64 - 8*width)
+	return int64(unsignedValue<<unusedBits) >> unusedBits, nil
+}
+
+// byteOrderIsBigEndian determines the order of an arbitrary binary.ByteOrder, which is needed for widths
+// that binary.ByteOrder does not support directly.
+func byteOrderIsBigEndian(order binary.ByteOrder) bool {
+	encoded := make([]byte, 2)
+	order.PutUint16(encoded, 1)
+	return encoded[1] == 1
+}
+
+// readUnsignedLEB128 decodes an unsigned LEB128 value, which is also the encoding of a protobuf varint.  If
+// the varint is truncated or overflows, the cursor is returned to its start.
+func (delegate *byteNibblerDelegate) readUnsignedLEB128(op string) (uint64, error) {
+	mark := delegate.actualNibbler.Mark()
+	defer delegate.actualNibbler.Release(mark)
+
+	var value uint64
+	for i := 0; ; i++ {
+		nextByte, err := delegate.actualNibbler.ReadByte()
+		if err != nil {
+			return 0, delegate.resetToStartOfVarint(op, mark, i, err)
+		}
+
+		// the tenth byte can contribute only bit 63 and cannot be continued
+		if i == 9 && nextByte > 1 {
+			return 0, delegate.resetToStartOfVarint(op, mark, i, ErrVarintOverflow)
+		}
+
+		value |= uint64(nextByte&0x7f) << (7 * i)
+
+		if nextByte&0x80 == 0 {
+			return value, nil
+		}
+	}
+}
+
+// readSignedLEB128 decodes a signed LEB128 value.  If the varint is truncated or overflows, the cursor is
+// returned to its start.
+func (delegate *byteNibblerDelegate) readSignedLEB128(op string) (int64, error) {
+	mark := delegate.actualNibbler.Mark()
+	defer delegate.actualNibbler.Release(mark)
+
+	var value int64
+	for i := 0; ; i++ {
+		nextByte, err := delegate.actualNibbler.ReadByte()
+		if err != nil {
+			return 0, delegate.resetToStartOfVarint(op, mark, i, err)
+		}
+
+		// the tenth byte can contribute only bit 63, so it must be a sign extension of that bit
+		if i == 9 && nextByte != 0x00 && nextByte != 0x7f {
+			return 0, delegate.resetToStartOfVarint(op, mark, i, ErrVarintOverflow)
+		}
+
+		value |= int64(nextByte&0x7f) << (7 * i)
+
+		if nextByte&0x80 == 0 {
+			if shift := 7 * (i + 1); shift < 64 && nextByte&0x40 != 0 {
+				value |= -1 << shift
+			}
+
+			return value, nil
+		}
+	}
+}
+
+// resetToStartOfVarint moves the cursor back to mark after a failed varint read, returning the error
+// to report.  Reaching the end of the stream after countOfReadBytes is io.ErrUnexpectedEOF unless no bytes
+// were read.  io.ErrUnexpectedEOF and ErrVarintOverflow are wrapped in a NibbleError for the operation op.
+func (delegate *byteNibblerDelegate) resetToStartOfVarint(op string, mark Mark, countOfReadBytes int, err error) error {
+	delegate.actualNibbler.ResetTo(mark)
+
+	if err == io.EOF && countOfReadBytes > 0 {
+		return delegate.errorAtCursor(op, io.ErrUnexpectedEOF)
+	}
+
+	if err == ErrVarintOverflow {
+		return delegate.errorAtCursor(op, err)
+	}
+
+	return err
+}
+
+// readQUICVarint decodes a QUIC variable-length integer (RFC 9000, Section 16), in which the two most
+// significant bits of the first byte give the length of the encoding.
+func (delegate *byteNibblerDelegate) readQUICVarint(op string, consumeBytes bool) (uint64, error) {
+	firstByte, err := delegate.actualNibbler.PeekAtNextByte()
+	if err != nil {
+		return 0, err
+	}
+
+	encodedBytes, err := delegate.readFixedWidth(op, 1<<(firstByte>>6), consumeBytes)
+	if err != nil {
+		return 0, err
+	}
+
+	value := uint64(encodedBytes[0] & 0x3f)
+	for _, nextByte := range encodedBytes[1:] {
+		value = value<<8 | uint64(nextByte)
+	}
+
+	return value, nil
+}
+
+// fixedWidthDecoder provides the fixed-width Read and PeekAtNext methods of a ByteNibbler (see the
+// ByteNibbler interface for how a short stream is reported).  It is embedded in each ByteNibbler
+// implementation so that the methods are written once.
+type fixedWidthDecoder struct {
+	delegate *byteNibblerDelegate
+}
+
+// ReadUint8 reads a uint8.
+func (decoder fixedWidthDecoder) ReadUint8() (uint8, error) {
+	value, err := decoder.delegate.readUnsigned("ReadUint8", 1, nil, true)
+	return uint8(value), err
+}
+
+// PeekAtNextUint8 does the same thing as ReadUint8, but does not advance the cursor.
+func (decoder fixedWidthDecoder) PeekAtNextUint8() (uint8, error) {
+	value, err := decoder.delegate.readUnsigned("PeekAtNextUint8", 1, nil, false)
+	return uint8(value), err
+}
+
+// ReadUint16 reads a uint16 in the provided byte order.
+func (decoder fixedWidthDecoder) ReadUint16(order binary.ByteOrder) (uint16, error) {
+	value, err := decoder.delegate.readUnsigned("ReadUint16", 2, order, true)
+	return uint16(value), err
+}
+
+// PeekAtNextUint16 does the same thing as ReadUint16, but does not advance the cursor.
+func (decoder fixedWidthDecoder) PeekAtNextUint16(order binary.ByteOrder) (uint16, error) {
+	value, err := decoder.delegate.readUnsigned("PeekAtNextUint16", 2, order, false)
+	return uint16(value), err
+}
+
+// ReadUint24 reads a 24-bit unsigned integer (as a uint32) in the provided byte order.
+func (decoder fixedWidthDecoder) ReadUint24(order binary.ByteOrder) (uint32, error) {
+	value, err := decoder.delegate.readUnsigned("ReadUint24", 3, order, true)
+	return uint32(value), err
+}
+
+// PeekAtNextUint24 does the same thing as ReadUint24, but does not advance the cursor.
+func (decoder fixedWidthDecoder) PeekAtNextUint24(order binary.ByteOrder) (uint32, error) {
+	value, err := decoder.delegate.readUnsigned("PeekAtNextUint24", 3, order, false)
+	return uint32(value), err
+}
+
+// ReadUint32 reads a uint32 in the provided byte order.
+func (decoder fixedWidthDecoder) ReadUint32(order binary.ByteOrder) (uint32, error) {
+	value, err := decoder.delegate.readUnsigned("ReadUint32", 4, order, true)
+	return uint32(value), err
+}
+
+// PeekAtNextUint32 does the same thing as ReadUint32, but does not advance the cursor.
+func (decoder fixedWidthDecoder) PeekAtNextUint32(order binary.ByteOrder) (uint32, error) {
+	value, err := decoder.delegate.readUnsigned("PeekAtNextUint32", 4, order, false)
+	return uint32(value), err
+}
+
+// ReadUint64 reads a uint64 in the provided byte order.
+func (decoder fixedWidthDecoder) ReadUint64(order binary.ByteOrder) (uint64, error) {
+	value, err := decoder.delegate.readUnsigned("ReadUint64", 8, order, true)
+	return value, err
+}
+
+// PeekAtNextUint64 does the same thing as ReadUint64, but does not advance the cursor.
+func (decoder fixedWidthDecoder) PeekAtNextUint64(order binary.ByteOrder) (uint64, error) {
+	value, err := decoder.delegate.readUnsigned("PeekAtNextUint64", 8, order, false)
+	return value, err
+}
+
+// ReadInt8 reads an int8.
+func (decoder fixedWidthDecoder) ReadInt8() (int8, error) {
+	value, err := decoder.delegate.readSigned("ReadInt8", 1, nil, true)
+	return int8(value), err
+}
+
+// PeekAtNextInt8 does the same thing as ReadInt8, but does not advance the cursor.
+func (decoder fixedWidthDecoder) PeekAtNextInt8() (int8, error) {
+	value, err := decoder.delegate.readSigned("PeekAtNextInt8", 1, nil, false)
+	return int8(value), err
+}
+
+// ReadInt16 reads an int16 in the provided byte order.
+func (decoder fixedWidthDecoder) ReadInt16(order binary.ByteOrder) (int16, error) {
+	value, err := decoder.delegate.readSigned("ReadInt16", 2, order, true)
+	return int16(value), err
+}
+
+// PeekAtNextInt16 does the same thing as ReadInt16, but does not advance the cursor.
+func (decoder fixedWidthDecoder) PeekAtNextInt16(order binary.ByteOrder) (int16, error) {
+	value, err := decoder.delegate.readSigned("PeekAtNextInt16", 2, order, false)
+	return int16(value), err
+}
+
+// ReadInt24 reads a 24-bit signed integer (as an int32) in the provided byte order.
+func (decoder fixedWidthDecoder) ReadInt24(order binary.ByteOrder) (int32, error) {
+	value, err := decoder.delegate.readSigned("ReadInt24", 3, order, true)
+	return int32(value), err
+}
+
+// PeekAtNextInt24 does the same thing as ReadInt24, but does not advance the cursor.
+func (decoder fixedWidthDecoder) PeekAtNextInt24(order binary.ByteOrder) (int32, error) {
+	value, err := decoder.delegate.readSigned("PeekAtNextInt24", 3, order, false)
+	return int32(value), err
+}
+
+// ReadInt32 reads an int32 in the provided byte order.
+func (decoder fixedWidthDecoder) ReadInt32(order binary.ByteOrder) (int32, error) {
+	value, err := decoder.delegate.readSigned("ReadInt32", 4, order, true)
+	return int32(value), err
+}
+
+// PeekAtNextInt32 does the same thing as ReadInt32, but does not advance the cursor.
+func (decoder fixedWidthDecoder) PeekAtNextInt32(order binary.ByteOrder) (int32, error) {
+	value, err := decoder.delegate.readSigned("PeekAtNextInt32", 4, order, false)
+	return int32(value), err
+}
+
+// ReadInt64 reads an int64 in the provided byte order.
+func (decoder fixedWidthDecoder) ReadInt64(order binary.ByteOrder) (int64, error) {
+	value, err := decoder.delegate.readSigned("ReadInt64", 8, order, true)
+	return value, err
+}
+
+// PeekAtNextInt64 does the same thing as ReadInt64, but does not advance the cursor.
+func (decoder fixedWidthDecoder) PeekAtNextInt64(order binary.ByteOrder) (int64, error) {
+	value, err := decoder.delegate.readSigned("PeekAtNextInt64", 8, order, false)
+	return value, err
+}
+
+// ReadFloat32 reads an IEEE 754 float32 in the provided byte order.
+func (decoder fixedWidthDecoder) ReadFloat32(order binary.ByteOrder) (float32, error) {
+	value, err := decoder.delegate.readUnsigned("ReadFloat32", 4, order, true)
+	return math.Float32frombits(uint32(value)), err
+}
+
+// PeekAtNextFloat32 does the same thing as ReadFloat32, but does not advance the cursor.
+func (decoder fixedWidthDecoder) PeekAtNextFloat32(order binary.ByteOrder) (float32, error) {
+	value, err := decoder.delegate.readUnsigned("PeekAtNextFloat32", 4, order, false)
+	return math.Float32frombits(uint32(value)), err
+}
+
+// ReadFloat64 reads an IEEE 754 float64 in the provided byte order.
+func (decoder fixedWidthDecoder) ReadFloat64(order binary.ByteOrder) (float64, error) {
+	value, err := decoder.delegate.readUnsigned("ReadFloat64", 8, order, true)
+	return math.Float64frombits(value), err
+}
+
+// PeekAtNextFloat64 does the same thing as ReadFloat64, but does not advance the cursor.
+func (decoder fixedWidthDecoder) PeekAtNextFloat64(order binary.ByteOrder) (float64, error) {
+	value, err := decoder.delegate.readUnsigned("PeekAtNextFloat64", 8, order, false)
+	return math.Float64frombits(value), err
+}
+
+// ReadUnsignedLEB128 reads an unsigned LEB128 variable-length integer, which is also the encoding of a protobuf
+// varint.  If the stream ends within the varint, io.ErrUnexpectedEOF is returned.  If the value does not fit in
+// 64 bits, ErrVarintOverflow is returned.  On either error, the cursor is left at the start of the varint.
+func (nibbler *ByteSliceNibbler) ReadUnsignedLEB128() (uint64, error) {
+	return nibbler.delegate.readUnsignedLEB128("ReadUnsignedLEB128")
+}
+
+// ReadSignedLEB128 reads a signed LEB128 variable-length integer.  Errors are reported in the same way as
+// for ReadUnsignedLEB128.
+func (nibbler *ByteSliceNibbler) ReadSignedLEB128() (int64, error) {
+	return nibbler.delegate.readSignedLEB128("ReadSignedLEB128")
+}
+
+// ReadZigZagVarint reads a protobuf varint that holds a zigzag encoded signed integer (the encoding of the
+// protobuf sint32 and sint64 types).  Errors are reported in the same way as for ReadUnsignedLEB128.
+func (nibbler *ByteSliceNibbler) ReadZigZagVarint() (int64, error) {
+	value, err := nibbler.delegate.readUnsignedLEB128("ReadZigZagVarint")
+	return int64(value>>1) ^ -int64(value&1), err
+}
+
+// ReadQUICVarint reads a QUIC variable-length integer, which is 1, 2, 4 or 8 bytes long.  If the stream ends
+// within the varint, a *PartialReadError is returned and the cursor is not moved.
+func (nibbler *ByteSliceNibbler) ReadQUICVarint() (uint64, error) {
+	return nibbler.delegate.readQUICVarint("ReadQUICVarint", true)
+}
+
+// PeekAtNextQUICVarint returns the next QUIC variable-length integer without advancing the cursor.
+func (nibbler *ByteSliceNibbler) PeekAtNextQUICVarint() (uint64, error) {
+	return nibbler.delegate.readQUICVarint("PeekAtNextQUICVarint", false)
+}
+
+// ReadUnsignedLEB128 reads an unsigned LEB128 variable-length integer, which is also the encoding of a protobuf
+// varint.  If the stream ends within the varint, io.ErrUnexpectedEOF is returned.  If the value does not fit in
+// 64 bits, ErrVarintOverflow is returned.  On either error, the cursor is left at the start of the varint.
+func (nibbler *ByteReaderNibbler) ReadUnsignedLEB128() (uint64, error) {
+	return nibbler.delegate.readUnsignedLEB128("ReadUnsignedLEB128")
+}
+
+// ReadSignedLEB128 reads a signed LEB128 variable-length integer.  Errors are reported in the same way as
+// for ReadUnsignedLEB128.
+func (nibbler *ByteReaderNibbler) ReadSignedLEB128() (int64, error) {
+	return nibbler.delegate.readSignedLEB128("ReadSignedLEB128")
+}
+
+// ReadZigZagVarint reads a protobuf varint that holds a zigzag encoded signed integer (the encoding of the
+// protobuf sint32 and sint64 types).  Errors are reported in the same way as for ReadUnsignedLEB128.
+func (nibbler *ByteReaderNibbler) ReadZigZagVarint() (int64, error) {
+	value, err := nibbler.delegate.readUnsignedLEB128("ReadZigZagVarint")
+	return int64(value>>1) ^ -int64(value&1), err
+}
+
+// ReadQUICVarint reads a QUIC variable-length integer, which is 1, 2, 4 or 8 bytes long.  If the stream ends
+// within the varint, a *PartialReadError is returned and the cursor is not moved.
+func (nibbler *ByteReaderNibbler) ReadQUICVarint() (uint64, error) {
+	return nibbler.delegate.readQUICVarint("ReadQUICVarint", true)
+}
+
+// PeekAtNextQUICVarint returns the next QUIC variable-length integer without advancing the cursor.
+func (nibbler *ByteReaderNibbler) PeekAtNextQUICVarint() (uint64, error) {
+	return nibbler.delegate.readQUICVarint("PeekAtNextQUICVarint", false)
+}
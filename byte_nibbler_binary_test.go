@@ -0,0 +1,190 @@
+package nibblers_test
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"testing"
+
+	nibblers "github.com/blorticus-go/nibblers"
+	mock "github.com/blorticus/go-test-mocks"
+)
+
+func testAnyByteNibblerForBinaryReads(nibbler nibblers.ByteNibbler, baseTestName string, t *testing.T) {
+	expectValue := func(stepName string, expected interface{}, got interface{}, err error) {
+		if err != nil {
+			t.Errorf("(%s) (%s) expected no error, got error = (%s)", baseTestName, stepName, err.Error())
+		} else if expected != got {
+			t.Errorf("(%s) (%s) expected (%v), got (%v)", baseTestName, stepName, expected, got)
+		}
+	}
+
+	u8, err := nibbler.PeekAtNextUint8()
+	expectValue("PeekAtNextUint8", uint8(0xfe), u8, err)
+	i8, err := nibbler.ReadInt8()
+	expectValue("ReadInt8", int8(-2), i8, err)
+
+	u16, err := nibbler.PeekAtNextUint16(binary.BigEndian)
+	expectValue("PeekAtNextUint16 big-endian", uint16(0x0102), u16, err)
+	u16, err = nibbler.ReadUint16(binary.LittleEndian)
+	expectValue("ReadUint16 little-endian", uint16(0x0201), u16, err)
+
+	u24, err := nibbler.PeekAtNextUint24(binary.BigEndian)
+	expectValue("PeekAtNextUint24 big-endian", uint32(0x030405), u24, err)
+	u24, err = nibbler.PeekAtNextUint24(binary.LittleEndian)
+	expectValue("PeekAtNextUint24 little-endian", uint32(0x050403), u24, err)
+	i24, err := nibbler.ReadInt24(binary.BigEndian)
+	expectValue("ReadInt24 big-endian", int32(0x030405), i24, err)
+
+	i24, err = nibbler.ReadInt24(binary.LittleEndian)
+	expectValue("ReadInt24 little-endian negative", int32(-2), i24, err)
+
+	i32, err := nibbler.ReadInt32(binary.BigEndian)
+	expectValue("ReadInt32 big-endian", int32(-0x7ffffffe), i32, err)
+
+	u64, err := nibbler.PeekAtNextUint64(binary.LittleEndian)
+	expectValue("PeekAtNextUint64 little-endian", uint64(0x0807060504030201), u64, err)
+	i64, err := nibbler.ReadInt64(binary.BigEndian)
+	expectValue("ReadInt64 big-endian", int64(0x0102030405060708), i64, err)
+
+	f32, err := nibbler.ReadFloat32(binary.BigEndian)
+	expectValue("ReadFloat32 big-endian", float32(1.5), f32, err)
+	f64, err := nibbler.ReadFloat64(binary.LittleEndian)
+	expectValue("ReadFloat64 little-endian", math.Pi, f64, err)
+
+	var partialReadError *nibblers.PartialReadError
+	if _, err := nibbler.ReadUint32(binary.BigEndian); !errors.As(err, &partialReadError) {
+		t.Errorf("(%s) (ReadUint32 with 3 bytes remaining) expected PartialReadError, got (%v)", baseTestName, err)
+	} else if partialReadError.RequiredBytes != 4 || partialReadError.AvailableBytes != 3 {
+		t.Errorf("(%s) (ReadUint32 with 3 bytes remaining) expected 4 required and 3 available, got (%d) and (%d)", baseTestName, partialReadError.RequiredBytes, partialReadError.AvailableBytes)
+	} else if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("(%s) (ReadUint32 with 3 bytes remaining) expected error to be io.ErrUnexpectedEOF", baseTestName)
+	}
+
+	u24, err = nibbler.ReadUint24(binary.BigEndian)
+	expectValue("ReadUint24 after partial read", uint32(0xaabbcc), u24, err)
+
+	if _, err := nibbler.ReadUint16(binary.BigEndian); err != io.EOF {
+		t.Errorf("(%s) (ReadUint16 at end of stream) expected io.EOF, got (%v)", baseTestName, err)
+	}
+}
+
+func TestByteNibblerBinaryReads(t *testing.T) {
+	stream := []byte{
+		0xfe,
+		0x01, 0x02,
+		0x03, 0x04, 0x05,
+		0xfe, 0xff, 0xff,
+		0x80, 0x00, 0x00, 0x02,
+		0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08,
+	}
+
+	encodedFloats := make([]byte, 12)
+	binary.BigEndian.PutUint32(encodedFloats[0:4], math.Float32bits(1.5))
+	binary.LittleEndian.PutUint64(encodedFloats[4:12], math.Float64bits(math.Pi))
+	stream = append(stream, encodedFloats...)
+	stream = append(stream, 0xaa, 0xbb, 0xcc)
+
+	testAnyByteNibblerForBinaryReads(nibblers.NewByteSliceNibbler(stream), "TestByteNibblerBinaryReads ByteSliceNibbler", t)
+
+	reader := mock.NewReader()
+	for i := 0; i < len(stream); i += 5 {
+		end := i + 5
+		if end > len(stream) {
+			end = len(stream)
+		}
+		reader.AddGoodRead(stream[i:end])
+	}
+
+	readerNibbler := nibblers.NewByteReaderNibbler(reader.AddEOF())
+	readerNibbler.SetMaximumUnreadDepth(0)
+	testAnyByteNibblerForBinaryReads(readerNibbler, "TestByteNibblerBinaryReads ByteReaderNibbler", t)
+}
+
+type varintTestCase struct {
+	encoding        string // "uleb128", "sleb128", "zigzag" or "quic"
+	encodedBytes    []byte
+	expectedValue   interface{}
+	expectedError   error
+	expectedNextPos int // bytes consumed after the read
+}
+
+func (testCase *varintTestCase) runTestCaseAgainst(nibbler nibblers.ByteNibbler) error {
+	var value interface{}
+	var err error
+
+	switch testCase.encoding {
+	case "uleb128":
+		value, err = nibbler.ReadUnsignedLEB128()
+	case "sleb128":
+		value, err = nibbler.ReadSignedLEB128()
+	case "zigzag":
+		value, err = nibbler.ReadZigZagVarint()
+	case "quic":
+		value, err = nibbler.ReadQUICVarint()
+	default:
+		return fmt.Errorf("invalid test case encoding (%s)", testCase.encoding)
+	}
+
+	if testCase.expectedError != nil {
+		if !errors.Is(err, testCase.expectedError) {
+			return fmt.Errorf("expected error (%v), got (%v)", testCase.expectedError, err)
+		}
+	} else if err != nil {
+		return fmt.Errorf("expected no error, got error = (%s)", err.Error())
+	} else if value != testCase.expectedValue {
+		return fmt.Errorf("expected value (%v), got (%v)", testCase.expectedValue, value)
+	}
+
+	remainingBytes, _ := nibbler.PeekAtNextBytes(uint(len(testCase.encodedBytes)))
+	if len(remainingBytes) != len(testCase.encodedBytes)-testCase.expectedNextPos {
+		return fmt.Errorf("expected (%d) bytes consumed, got (%d)", testCase.expectedNextPos, len(testCase.encodedBytes)-len(remainingBytes))
+	}
+
+	return nil
+}
+
+func TestByteNibblerVarints(t *testing.T) {
+	for testCaseIndex, testCase := range []*varintTestCase{
+		{encoding: "uleb128", encodedBytes: []byte{0x00}, expectedValue: uint64(0), expectedNextPos: 1},
+		{encoding: "uleb128", encodedBytes: []byte{0xe5, 0x8e, 0x26, 0xff}, expectedValue: uint64(624485), expectedNextPos: 3},
+		{encoding: "uleb128", encodedBytes: []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01}, expectedValue: uint64(math.MaxUint64), expectedNextPos: 10},
+		{encoding: "uleb128", encodedBytes: []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x02}, expectedError: nibblers.ErrVarintOverflow, expectedNextPos: 0},
+		{encoding: "uleb128", encodedBytes: []byte{0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x00}, expectedError: nibblers.ErrVarintOverflow, expectedNextPos: 0},
+		{encoding: "uleb128", encodedBytes: []byte{0xe5, 0x8e}, expectedError: io.ErrUnexpectedEOF, expectedNextPos: 0},
+		{encoding: "uleb128", encodedBytes: []byte{}, expectedError: io.EOF, expectedNextPos: 0},
+		{encoding: "sleb128", encodedBytes: []byte{0x02}, expectedValue: int64(2), expectedNextPos: 1},
+		{encoding: "sleb128", encodedBytes: []byte{0x7e}, expectedValue: int64(-2), expectedNextPos: 1},
+		{encoding: "sleb128", encodedBytes: []byte{0xc0, 0xbb, 0x78}, expectedValue: int64(-123456), expectedNextPos: 3},
+		{encoding: "sleb128", encodedBytes: []byte{0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x7f}, expectedValue: int64(math.MinInt64), expectedNextPos: 10},
+		{encoding: "sleb128", encodedBytes: []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x00}, expectedValue: int64(math.MaxInt64), expectedNextPos: 10},
+		{encoding: "sleb128", encodedBytes: []byte{0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x01}, expectedError: nibblers.ErrVarintOverflow, expectedNextPos: 0},
+		{encoding: "sleb128", encodedBytes: []byte{0xc0, 0xbb}, expectedError: io.ErrUnexpectedEOF, expectedNextPos: 0},
+		{encoding: "zigzag", encodedBytes: []byte{0x00}, expectedValue: int64(0), expectedNextPos: 1},
+		{encoding: "zigzag", encodedBytes: []byte{0x01}, expectedValue: int64(-1), expectedNextPos: 1},
+		{encoding: "zigzag", encodedBytes: []byte{0x04}, expectedValue: int64(2), expectedNextPos: 1},
+		{encoding: "zigzag", encodedBytes: []byte{0xff, 0xff, 0xff, 0xff, 0x0f}, expectedValue: int64(-2147483648), expectedNextPos: 5},
+		{encoding: "quic", encodedBytes: []byte{0x25}, expectedValue: uint64(37), expectedNextPos: 1},
+		{encoding: "quic", encodedBytes: []byte{0x7b, 0xbd}, expectedValue: uint64(15293), expectedNextPos: 2},
+		{encoding: "quic", encodedBytes: []byte{0x9d, 0x7f, 0x3e, 0x7d}, expectedValue: uint64(494878333), expectedNextPos: 4},
+		{encoding: "quic", encodedBytes: []byte{0xc2, 0x19, 0x7c, 0x5e, 0xff, 0x14, 0xe8, 0x8c}, expectedValue: uint64(151288809941952652), expectedNextPos: 8},
+		{encoding: "quic", encodedBytes: []byte{0x9d, 0x7f, 0x3e}, expectedError: io.ErrUnexpectedEOF, expectedNextPos: 0},
+	} {
+		if err := testCase.runTestCaseAgainst(nibblers.NewByteSliceNibbler(testCase.encodedBytes)); err != nil {
+			t.Errorf("(TestByteNibblerVarints) (ByteSliceNibbler) (test case %d) %s", testCaseIndex+1, err.Error())
+		}
+
+		reader := mock.NewReader()
+		for _, b := range testCase.encodedBytes {
+			reader.AddGoodRead([]byte{b})
+		}
+
+		readerNibbler := nibblers.NewByteReaderNibbler(reader.AddEOF())
+		readerNibbler.SetMaximumUnreadDepth(0)
+		if err := testCase.runTestCaseAgainst(readerNibbler); err != nil {
+			t.Errorf("(TestByteNibblerVarints) (ByteReaderNibbler) (test case %d) %s", testCaseIndex+1, err.Error())
+		}
+	}
+}
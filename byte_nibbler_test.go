@@ -2,8 +2,11 @@ package nibblers_test
 
 import (
 	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"testing"
 
 	mock "github.com/blorticus/go-test-mocks"
@@ -348,6 +351,26 @@ func TestByteSliceNibblerNamedSet(t *testing.T) {
 	testAnyByteNibblerForNamedSetMatchers(byteSliceNibbler, "TestByteSliceNibblerNamedSet", t)
 }
 
+func TestByteSliceNibblerNamedSetReturnsSubslice(t *testing.T) {
+	backingBuffer := []byte("abc123")
+	nibbler := nibblers.NewByteSliceNibbler(backingBuffer)
+	nibbler.AddNamedByteSetsMap(nibblers.NewNamedByteSetsMap())
+
+	letters, err := nibbler.ReadNextBytesMatchingSet(nibblers.ByteSetAlpha)
+	if err != nil {
+		t.Fatalf("(TestByteSliceNibblerNamedSetReturnsSubslice) expected no error, got error = (%s)", err.Error())
+	}
+
+	if &letters[0] != &backingBuffer[0] {
+		t.Errorf("(TestByteSliceNibblerNamedSetReturnsSubslice) expected returned slice to share the backing buffer")
+	}
+
+	_ = append(letters, 'x')
+	if string(backingBuffer) != "abc123" {
+		t.Errorf("(TestByteSliceNibblerNamedSetReturnsSubslice) append to returned slice altered backing buffer to (%s)", backingBuffer)
+	}
+}
+
 func TestByteReaderNibblerNamedSet(t *testing.T) {
 	completeStream := "abc \tD12\r21D "
 	reader := mock.NewReader().
@@ -363,7 +386,7 @@ func TestByteReaderNibblerNamedSet(t *testing.T) {
 
 func TestByteReaderNibblerNamedSetWithEmptyRead(t *testing.T) {
 	// When a ByteReaderNibbler attempts an underlying Read(), and that returns no error, no EOF but
-	// also no data, an error should be thrown.  Ensure that is is reflected here.
+	// also no data, the default EmptyReadPolicy returns ErrNoProgress.  Ensure that is is reflected here.
 	completeStream := "abc \tD12\r21D "
 
 	reader := mock.NewReader().
@@ -395,3 +418,706 @@ func TestByteReaderNibblerNamedSetWithEmptyRead(t *testing.T) {
 		}
 	}
 }
+
+func TestByteReaderNibblerRewindWindow(t *testing.T) {
+	completeStream := make([]byte, 30000)
+	for i := range completeStream {
+		completeStream[i] = byte(i % 251)
+	}
+
+	reader := mock.NewReader()
+	for i := 0; i < len(completeStream); i += 7000 {
+		end := i + 7000
+		if end > len(completeStream) {
+			end = len(completeStream)
+		}
+		reader.AddGoodRead(completeStream[i:end])
+	}
+	reader.AddEOF()
+
+	nibbler := nibblers.NewByteReaderNibbler(reader)
+	nibbler.SetMaximumUnreadDepth(10)
+
+	if err := nibbler.UnreadByte(); err == nil {
+		t.Errorf("(TestByteReaderNibblerRewindWindow) expected error on UnreadByte at start of stream, got none")
+	} else if errors.Is(err, nibblers.ErrUnreadLimitExceeded) {
+		t.Errorf("(TestByteReaderNibblerRewindWindow) expected start of stream error on UnreadByte at start of stream, got ErrUnreadLimitExceeded")
+	}
+
+	readBytes, err := nibbler.ReadFixedNumberOfBytes(20000)
+	if err != nil {
+		t.Fatalf("(TestByteReaderNibblerRewindWindow) expected no error on ReadFixedNumberOfBytes, got error = (%s)", err.Error())
+	}
+
+	if !bytes.Equal(readBytes, completeStream[:20000]) {
+		t.Fatalf("(TestByteReaderNibblerRewindWindow) bytes returned by ReadFixedNumberOfBytes do not match stream")
+	}
+
+	for i := 0; i < 10; i++ {
+		if err := nibbler.UnreadByte(); err != nil {
+			t.Fatalf("(TestByteReaderNibblerRewindWindow) on UnreadByte (%d) expected no error, got error = (%s)", i+1, err.Error())
+		}
+	}
+
+	if err := nibbler.UnreadByte(); err == nil {
+		t.Errorf("(TestByteReaderNibblerRewindWindow) expected ErrUnreadLimitExceeded on UnreadByte past window, got no error")
+	} else if !errors.Is(err, nibblers.ErrUnreadLimitExceeded) {
+		t.Errorf("(TestByteReaderNibblerRewindWindow) expected ErrUnreadLimitExceeded on UnreadByte past window, got error = (%s)", err.Error())
+	}
+
+	readBytes, err = nibbler.ReadFixedNumberOfBytes(10015)
+	if err != io.EOF {
+		t.Fatalf("(TestByteReaderNibblerRewindWindow) expected io.EOF on ReadFixedNumberOfBytes at end of stream, got (%v)", err)
+	}
+
+	if !bytes.Equal(readBytes, completeStream[19990:]) {
+		t.Errorf("(TestByteReaderNibblerRewindWindow) bytes returned after unread do not match stream")
+	}
+
+	nibbler = nibblers.NewByteReaderNibbler(mock.NewReader().AddGoodRead(completeStream[:7000]).AddGoodRead(completeStream[7000:14000]).AddEOF())
+	nibbler.SetMaximumUnreadDepth(nibblers.UnlimitedUnreadDepth)
+
+	if _, err := nibbler.ReadFixedNumberOfBytes(14000); err != nil {
+		t.Fatalf("(TestByteReaderNibblerRewindWindow) with unlimited depth, expected no error on ReadFixedNumberOfBytes, got error = (%s)", err.Error())
+	}
+
+	for i := 0; i < 14000; i++ {
+		if err := nibbler.UnreadByte(); err != nil {
+			t.Fatalf("(TestByteReaderNibblerRewindWindow) with unlimited depth, on UnreadByte (%d) expected no error, got error = (%s)", i+1, err.Error())
+		}
+	}
+
+	if b, err := nibbler.ReadByte(); err != nil || b != completeStream[0] {
+		t.Errorf("(TestByteReaderNibblerRewindWindow) with unlimited depth, expected first stream byte after unreads, got (%d, %v)", b, err)
+	}
+}
+
+type byteBookendTestStep struct {
+	operation     string // "Read", "Peek", "Start", "Checkpoint", "Stop"
+	count         int
+	expectedBytes []byte
+	expectAnError bool
+}
+
+func testAnyByteNibblerForBookends(nibbler nibblers.ByteNibbler, baseTestName string, t *testing.T) {
+	for stepIndex, step := range []*byteBookendTestStep{
+		{operation: "Stop", expectedBytes: nil},
+		{operation: "Checkpoint", expectedBytes: nil},
+		{operation: "Start"},
+		{operation: "Start", expectAnError: true},
+		{operation: "Stop", expectedBytes: []byte{}},
+		{operation: "Read", count: 3},
+		{operation: "Start"},
+		{operation: "Read", count: 4},
+		{operation: "Peek"},
+		{operation: "Checkpoint", expectedBytes: []byte("defg")},
+		{operation: "Checkpoint", expectedBytes: []byte{}},
+		{operation: "Read", count: 2},
+		{operation: "Checkpoint", expectedBytes: []byte("hi")},
+		{operation: "Read", count: 1},
+		{operation: "Stop", expectedBytes: []byte("defghij")},
+		{operation: "Checkpoint", expectedBytes: nil},
+		{operation: "Start"},
+		{operation: "Read", count: 16},
+		{operation: "Stop", expectedBytes: []byte("klmnopqrstuvwxyz")},
+	} {
+		var returnedBytes []byte
+		var err error
+
+		switch step.operation {
+		case "Read":
+			_, err = nibbler.ReadFixedNumberOfBytes(uint(step.count))
+		case "Peek":
+			_, err = nibbler.PeekAtNextByte()
+		case "Start":
+			err = nibbler.StartBookending()
+		case "Checkpoint":
+			returnedBytes = nibbler.BookendCheckpoint()
+		case "Stop":
+			returnedBytes = nibbler.StopBookending()
+		}
+
+		if err != nil {
+			if !step.expectAnError {
+				t.Errorf("(%s) (step %d, %s) expected no error, got error = (%s)", baseTestName, stepIndex+1, step.operation, err.Error())
+			}
+			continue
+		} else if step.expectAnError {
+			t.Errorf("(%s) (step %d, %s) expected an error, got no error", baseTestName, stepIndex+1, step.operation)
+			continue
+		}
+
+		if !bytes.Equal(step.expectedBytes, returnedBytes) {
+			t.Errorf("(%s) (step %d, %s) expected bytes (%s), got (%s)", baseTestName, stepIndex+1, step.operation, byteSliceToSanitizedString(step.expectedBytes), byteSliceToSanitizedString(returnedBytes))
+		}
+	}
+}
+
+func TestByteNibblerBookending(t *testing.T) {
+	completeStream := []byte("abcdefghijklmnopqrstuvwxyz")
+
+	testAnyByteNibblerForBookends(nibblers.NewByteSliceNibbler(completeStream), "TestByteNibblerBookending ByteSliceNibbler", t)
+
+	reader := mock.NewReader()
+	for i := 0; i < len(completeStream); i += 2 {
+		reader.AddGoodRead(completeStream[i : i+2])
+	}
+	reader.AddEOF()
+
+	readerNibbler := nibblers.NewByteReaderNibbler(reader)
+	readerNibbler.SetMaximumUnreadDepth(0)
+	testAnyByteNibblerForBookends(readerNibbler, "TestByteNibblerBookending ByteReaderNibbler", t)
+
+	sliceNibbler := nibblers.NewByteSliceNibbler(completeStream)
+	sliceNibbler.ReadFixedNumberOfBytes(26)
+	if err := sliceNibbler.StartBookending(); err != io.EOF {
+		t.Errorf("(TestByteNibblerBookending ByteSliceNibbler) expected io.EOF on StartBookending at end of slice, got (%v)", err)
+	}
+}
+
+func testAnyByteNibblerForMarks(nibbler nibblers.ByteNibbler, baseTestName string, t *testing.T) {
+	expectNextByte := func(stepName string, expectedByte byte) {
+		if b, err := nibbler.PeekAtNextByte(); err != nil {
+			t.Errorf("(%s) (%s) expected next byte (%c), got error = (%s)", baseTestName, stepName, expectedByte, err.Error())
+		} else if b != expectedByte {
+			t.Errorf("(%s) (%s) expected next byte (%c), got (%c)", baseTestName, stepName, expectedByte, b)
+		}
+	}
+
+	outerMark := nibbler.Mark()
+	nibbler.ReadFixedNumberOfBytes(5)
+	innerMark := nibbler.Mark()
+	nibbler.ReadFixedNumberOfBytes(10)
+
+	if err := nibbler.ResetTo(innerMark); err != nil {
+		t.Errorf("(%s) (ResetTo inner mark) expected no error, got error = (%s)", baseTestName, err.Error())
+	}
+	expectNextByte("after ResetTo inner mark", 'f')
+
+	nibbler.ReadFixedNumberOfBytes(3)
+	if err := nibbler.ResetTo(outerMark); err != nil {
+		t.Errorf("(%s) (ResetTo outer mark) expected no error, got error = (%s)", baseTestName, err.Error())
+	}
+	expectNextByte("after ResetTo outer mark", 'a')
+
+	nibbler.Release(innerMark)
+	if err := nibbler.ResetTo(innerMark); !errors.Is(err, nibblers.ErrInvalidMark) {
+		t.Errorf("(%s) (ResetTo released inner mark) expected ErrInvalidMark, got (%v)", baseTestName, err)
+	}
+
+	if readBytes, _ := nibbler.ReadFixedNumberOfBytes(26); !bytes.Equal(readBytes, []byte("abcdefghijklmnopqrstuvwxyz")) {
+		t.Errorf("(%s) (read after ResetTo outer mark) expected entire stream, got (%s)", baseTestName, byteSliceToSanitizedString(readBytes))
+	}
+
+	if err := nibbler.ResetTo(outerMark); err != nil {
+		t.Errorf("(%s) (ResetTo outer mark at end of stream) expected no error, got error = (%s)", baseTestName, err.Error())
+	}
+	expectNextByte("after second ResetTo outer mark", 'a')
+
+	nibbler.ReadFixedNumberOfBytes(20)
+	firstNestedMark := nibbler.Mark()
+	nibbler.ReadByte()
+	secondNestedMark := nibbler.Mark()
+	nibbler.Release(firstNestedMark)
+
+	if err := nibbler.ResetTo(secondNestedMark); !errors.Is(err, nibblers.ErrInvalidMark) {
+		t.Errorf("(%s) (ResetTo mark created after released mark) expected ErrInvalidMark, got (%v)", baseTestName, err)
+	}
+
+	if err := nibbler.ResetTo(outerMark); err != nil {
+		t.Errorf("(%s) (ResetTo outer mark after nested release) expected no error, got error = (%s)", baseTestName, err.Error())
+	}
+
+	nibbler.Release(outerMark)
+	if err := nibbler.ResetTo(outerMark); !errors.Is(err, nibblers.ErrInvalidMark) {
+		t.Errorf("(%s) (ResetTo released outer mark) expected ErrInvalidMark, got (%v)", baseTestName, err)
+	}
+}
+
+func TestByteNibblerMarks(t *testing.T) {
+	completeStream := []byte("abcdefghijklmnopqrstuvwxyz")
+
+	testAnyByteNibblerForMarks(nibblers.NewByteSliceNibbler(completeStream), "TestByteNibblerMarks ByteSliceNibbler", t)
+
+	reader := mock.NewReader()
+	for i := 0; i < len(completeStream); i += 2 {
+		reader.AddGoodRead(completeStream[i : i+2])
+	}
+	reader.AddEOF()
+
+	readerNibbler := nibblers.NewByteReaderNibbler(reader)
+	readerNibbler.SetMaximumUnreadDepth(0)
+	testAnyByteNibblerForMarks(readerNibbler, "TestByteNibblerMarks ByteReaderNibbler", t)
+}
+
+func TestByteReaderNibblerReadsAfterResetToReleasedMark(t *testing.T) {
+	completeStream := []byte("abcdefghijklmnopqrstuvwxyz")
+
+	reader := mock.NewReader()
+	for i := 0; i < len(completeStream); i += 4 {
+		end := i + 4
+		if end > len(completeStream) {
+			end = len(completeStream)
+		}
+		reader.AddGoodRead(completeStream[i:end])
+	}
+
+	nibbler := nibblers.NewByteReaderNibbler(reader.AddEOF())
+	nibbler.SetMaximumUnreadDepth(2)
+
+	// once the mark is released, the cursor is further back than the unread depth, and the bytes after it
+	// must not be discarded when more are read from the stream
+	mark := nibbler.Mark()
+	nibbler.ReadFixedNumberOfBytes(10)
+	nibbler.ResetTo(mark)
+	nibbler.Release(mark)
+
+	if nextBytes, err := nibbler.PeekAtNextBytes(14); err != nil || string(nextBytes) != "abcdefghijklmn" {
+		t.Errorf("(TestByteReaderNibblerReadsAfterResetToReleasedMark) on PeekAtNextBytes expected (abcdefghijklmn), got (%s) and (%v)", nextBytes, err)
+	}
+
+	if nextBytes, err := nibbler.ReadFixedNumberOfBytes(26); err != nil || string(nextBytes) != string(completeStream) {
+		t.Errorf("(TestByteReaderNibblerReadsAfterResetToReleasedMark) on ReadFixedNumberOfBytes expected the whole stream, got (%s) and (%v)", nextBytes, err)
+	}
+}
+
+type byteMatcherTestCase struct {
+	operation                 string // "Whitespace", "Word", "Matching", "NotMatching", "MatchingInto", "NotMatchingInto", "DiscardWhitespace", "DiscardNotMatching"
+	receiverLength            int
+	expectedBytes             []byte
+	expectedCountOfDiscarded  int
+	expectEOF                 bool
+	expectedNextByteAfterCall byte
+}
+
+func byteIsASCIIDigit(b byte) bool {
+	return b >= '0' && b <= '9'
+}
+
+func (testCase *byteMatcherTestCase) testAgainstMatcher(matcher *nibblers.ByteNibblerMatcher) error {
+	var returnedBytes []byte
+	var countOfDiscarded int
+	var err error
+
+	switch testCase.operation {
+	case "Whitespace":
+		returnedBytes, err = matcher.ReadConsecutiveWhitespace()
+	case "Word":
+		returnedBytes, err = matcher.ReadConsecutiveWordBytes()
+	case "Matching":
+		returnedBytes, err = matcher.ReadConsecutiveBytesMatching(byteIsASCIIDigit)
+	case "NotMatching":
+		returnedBytes, err = matcher.ReadConsecutiveBytesNotMatching(byteIsASCIIDigit)
+	case "MatchingInto", "NotMatchingInto":
+		receiver := make([]byte, testCase.receiverLength)
+		var countOfBytesInReceiver int
+		if testCase.operation == "MatchingInto" {
+			countOfBytesInReceiver, err = matcher.ReadConsecutiveBytesMatchingInto(byteIsASCIIDigit, receiver)
+		} else {
+			countOfBytesInReceiver, err = matcher.ReadConsecutiveBytesNotMatchingInto(byteIsASCIIDigit, receiver)
+		}
+		if countOfBytesInReceiver >= 0 {
+			returnedBytes = receiver[:countOfBytesInReceiver]
+		}
+	case "DiscardWhitespace":
+		countOfDiscarded, err = matcher.DiscardConsecutiveWhitespaceBytes()
+	case "DiscardNotMatching":
+		countOfDiscarded, err = matcher.DiscardConsecutiveBytesNotMatching(byteIsASCIIDigit)
+	default:
+		return fmt.Errorf("invalid test case operation (%s) provided", testCase.operation)
+	}
+
+	if testCase.expectEOF {
+		if err != io.EOF {
+			return fmt.Errorf("expected io.EOF, got (%v)", err)
+		}
+		return nil
+	}
+
+	if err != nil {
+		return fmt.Errorf("expected no error, got error = (%s)", err.Error())
+	}
+
+	if testCase.expectedBytes != nil && !bytes.Equal(testCase.expectedBytes, returnedBytes) {
+		return fmt.Errorf("expected bytes (%s), got (%s)", byteSliceToSanitizedString(testCase.expectedBytes), byteSliceToSanitizedString(returnedBytes))
+	}
+
+	if countOfDiscarded != testCase.expectedCountOfDiscarded {
+		return fmt.Errorf("expected (%d) discarded bytes, got (%d)", testCase.expectedCountOfDiscarded, countOfDiscarded)
+	}
+
+	nextByte, err := matcher.UnderlyingNibbler().PeekAtNextByte()
+	if testCase.expectedNextByteAfterCall == 0 {
+		if err != io.EOF {
+			return fmt.Errorf("expected io.EOF on peek after operation, got (%v)", err)
+		}
+	} else if err != nil {
+		return fmt.Errorf("expected no error on peek after operation, got error = (%s)", err.Error())
+	} else if nextByte != testCase.expectedNextByteAfterCall {
+		return fmt.Errorf("expected (%c) on peek after operation, got (%c)", testCase.expectedNextByteAfterCall, nextByte)
+	}
+
+	return nil
+}
+
+func testAnyByteNibblerForMatcher(nibbler nibblers.ByteNibbler, baseTestName string, t *testing.T) {
+	matcher := nibblers.NewByteNibblerMatcher(nibbler)
+
+	for testCaseIndex, testCase := range []*byteMatcherTestCase{
+		{operation: "Whitespace", expectedBytes: []byte{}, expectedNextByteAfterCall: 'G'},
+		{operation: "Word", expectedBytes: []byte("GET"), expectedNextByteAfterCall: ' '},
+		{operation: "DiscardWhitespace", expectedCountOfDiscarded: 1, expectedNextByteAfterCall: '/'},
+		{operation: "NotMatching", expectedBytes: []byte("/index"), expectedNextByteAfterCall: '4'},
+		{operation: "MatchingInto", receiverLength: 2, expectedBytes: []byte("42"), expectedNextByteAfterCall: '7'},
+		{operation: "Matching", expectedBytes: []byte("7"), expectedNextByteAfterCall: ' '},
+		{operation: "Whitespace", expectedBytes: []byte(" \t "), expectedNextByteAfterCall: 'H'},
+		{operation: "NotMatchingInto", receiverLength: 20, expectedBytes: []byte("HTTP/"), expectedNextByteAfterCall: '1'},
+		{operation: "DiscardNotMatching", expectedCountOfDiscarded: 0, expectedNextByteAfterCall: '1'},
+		{operation: "Word", expectedBytes: []byte("1.1"), expectedNextByteAfterCall: '\r'},
+		{operation: "DiscardWhitespace", expectedCountOfDiscarded: 2, expectedNextByteAfterCall: 0},
+		{operation: "Word", expectEOF: true},
+		{operation: "MatchingInto", receiverLength: 2, expectEOF: true},
+		{operation: "DiscardWhitespace", expectEOF: true},
+	} {
+		if err := testCase.testAgainstMatcher(matcher); err != nil {
+			t.Errorf("(%s) on test case %d (%s): %s", baseTestName, testCaseIndex+1, testCase.operation, err.Error())
+		}
+	}
+}
+
+func TestByteNibblerMatcher(t *testing.T) {
+	stream := []byte("GET /index427 \t HTTP/1.1\r\n")
+
+	testAnyByteNibblerForMatcher(nibblers.NewByteSliceNibbler(stream), "TestByteNibblerMatcher ByteSliceNibbler", t)
+
+	newReaderOfStream := func() *mock.Reader {
+		reader := mock.NewReader()
+		for i := 0; i < len(stream); i += 3 {
+			end := i + 3
+			if end > len(stream) {
+				end = len(stream)
+			}
+			reader.AddGoodRead(stream[i:end])
+		}
+
+		return reader.AddEOF()
+	}
+
+	testAnyByteNibblerForMatcher(nibblers.NewByteReaderNibbler(newReaderOfStream()), "TestByteNibblerMatcher ByteReaderNibbler", t)
+
+	// the matcher must not lose the byte that ends a run when the nibbler cannot unread
+	nibblerWithoutUnreadDepth := nibblers.NewByteReaderNibbler(newReaderOfStream())
+	nibblerWithoutUnreadDepth.SetMaximumUnreadDepth(0)
+	testAnyByteNibblerForMatcher(nibblerWithoutUnreadDepth, "TestByteNibblerMatcher ByteReaderNibbler with no unread depth", t)
+}
+
+// benchmarkStreamForNamedSets is a sequence of identifier-like words, separated by single spaces, so that
+// each ReadNextBytesMatchingSet() returns a multi-byte run.
+func benchmarkStreamForNamedSets() []byte {
+	return bytes.Repeat([]byte("request_identifier_0123456789 "), 1<<15)
+}
+
+func benchmarkNamedSetsMap() *nibblers.NamedByteSetsMap {
+	setsMap := nibblers.NewNamedByteSetsMap()
+	setsMap.AddNamedByteSetFromBracketExpression("identifier", "[[:alnum:]_]")
+	return setsMap
+}
+
+func benchmarkAlternatingNamedSetReads(nibbler nibblers.ByteNibbler) {
+	nibbler.AddNamedByteSetsMap(benchmarkNamedSetsMap())
+
+	for {
+		if _, err := nibbler.ReadNextBytesMatchingSet("identifier"); err != nil {
+			return
+		}
+
+		if _, err := nibbler.ReadNextBytesNotMatchingSet("identifier"); err != nil {
+			return
+		}
+	}
+}
+
+func BenchmarkByteSliceNibblerNamedSets(b *testing.B) {
+	stream := benchmarkStreamForNamedSets()
+	b.SetBytes(int64(len(stream)))
+
+	for i := 0; i < b.N; i++ {
+		benchmarkAlternatingNamedSetReads(nibblers.NewByteSliceNibbler(stream))
+	}
+}
+
+func BenchmarkByteReaderNibblerNamedSets(b *testing.B) {
+	stream := benchmarkStreamForNamedSets()
+	b.SetBytes(int64(len(stream)))
+
+	for i := 0; i < b.N; i++ {
+		benchmarkAlternatingNamedSetReads(nibblers.NewByteReaderNibbler(bytes.NewReader(stream)))
+	}
+}
+
+// benchmarkAlternatingByteAtATimeReads performs the same scan as benchmarkAlternatingNamedSetReads with
+// PeekAtNextByte() and ReadByte(), through a ByteNibblerMatcher, for comparison.
+func benchmarkAlternatingByteAtATimeReads(nibbler nibblers.ByteNibbler) {
+	matcher := nibblers.NewByteNibblerMatcher(nibbler)
+	isIdentifierByte := func(c byte) bool {
+		return c == '_' || (c >= '0' && c <= '9') || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+	}
+
+	for {
+		if _, err := matcher.ReadConsecutiveBytesMatching(isIdentifierByte); err != nil {
+			return
+		}
+
+		if _, err := matcher.ReadConsecutiveBytesNotMatching(isIdentifierByte); err != nil {
+			return
+		}
+	}
+}
+
+func BenchmarkByteSliceNibblerByteAtATime(b *testing.B) {
+	stream := benchmarkStreamForNamedSets()
+	b.SetBytes(int64(len(stream)))
+
+	for i := 0; i < b.N; i++ {
+		benchmarkAlternatingByteAtATimeReads(nibblers.NewByteSliceNibbler(stream))
+	}
+}
+
+func BenchmarkByteReaderNibblerByteAtATime(b *testing.B) {
+	stream := benchmarkStreamForNamedSets()
+	b.SetBytes(int64(len(stream)))
+
+	for i := 0; i < b.N; i++ {
+		benchmarkAlternatingByteAtATimeReads(nibblers.NewByteReaderNibbler(bytes.NewReader(stream)))
+	}
+}
+
+func testAnyByteNibblerForMultiBytePeeks(nibbler nibblers.ByteNibbler, baseTestName string, t *testing.T) {
+	for stepIndex, step := range []struct {
+		countOfBytesToRead  uint
+		countOfBytesToPeek  uint
+		expectedPeekedBytes string
+		expectEOF           bool
+	}{
+		{countOfBytesToRead: 0, countOfBytesToPeek: 0, expectedPeekedBytes: ""},
+		{countOfBytesToRead: 0, countOfBytesToPeek: 3, expectedPeekedBytes: "a>>"},
+		{countOfBytesToRead: 1, countOfBytesToPeek: 3, expectedPeekedBytes: ">>="},
+		{countOfBytesToRead: 0, countOfBytesToPeek: 7, expectedPeekedBytes: ">>= b <"},
+		{countOfBytesToRead: 6, countOfBytesToPeek: 4, expectedPeekedBytes: "<!--"},
+		{countOfBytesToRead: 0, countOfBytesToPeek: 10, expectedPeekedBytes: "<!--", expectEOF: true},
+		{countOfBytesToRead: 4, countOfBytesToPeek: 1, expectedPeekedBytes: "", expectEOF: true},
+	} {
+		nibbler.ReadFixedNumberOfBytes(step.countOfBytesToRead)
+
+		peekedBytes, err := nibbler.PeekAtNextBytes(step.countOfBytesToPeek)
+		if step.expectEOF {
+			if err != io.EOF {
+				t.Errorf("(%s) (step %d) expected io.EOF, got (%v)", baseTestName, stepIndex+1, err)
+			}
+		} else if err != nil {
+			t.Errorf("(%s) (step %d) expected no error, got error = (%s)", baseTestName, stepIndex+1, err.Error())
+		}
+
+		if string(peekedBytes) != step.expectedPeekedBytes {
+			t.Errorf("(%s) (step %d) expected peeked bytes (%s), got (%s)", baseTestName, stepIndex+1, step.expectedPeekedBytes, byteSliceToSanitizedString(peekedBytes))
+		}
+	}
+}
+
+func TestByteNibblerPeekAtNextBytes(t *testing.T) {
+	stream := []byte("a>>= b <!--")
+
+	testAnyByteNibblerForMultiBytePeeks(nibblers.NewByteSliceNibbler(stream), "TestByteNibblerPeekAtNextBytes ByteSliceNibbler", t)
+
+	reader := mock.NewReader()
+	for i := 0; i < len(stream); i += 2 {
+		end := i + 2
+		if end > len(stream) {
+			end = len(stream)
+		}
+		reader.AddGoodRead(stream[i:end])
+	}
+
+	readerNibbler := nibblers.NewByteReaderNibbler(reader.AddEOF())
+	readerNibbler.SetMaximumUnreadDepth(0)
+	testAnyByteNibblerForMultiBytePeeks(readerNibbler, "TestByteNibblerPeekAtNextBytes ByteReaderNibbler", t)
+}
+
+type byteSequenceTestCase struct {
+	operation                 string // "Read" or "Skip"
+	delimiter                 string
+	handling                  nibblers.DelimiterHandling
+	maximumLength             int
+	expectedBytes             string
+	expectedCountOfSkipped    int
+	expectedError             error
+	expectAnError             bool
+	expectedNextByteAfterCall byte // 0 means io.EOF is expected
+}
+
+func (testCase *byteSequenceTestCase) runTestCaseAgainst(nibbler nibblers.ByteNibbler) error {
+	var readBytes []byte
+	var countOfSkippedBytes int
+	var err error
+
+	if testCase.operation == "Read" {
+		readBytes, err = nibbler.ReadUntilSequence([]byte(testCase.delimiter), testCase.handling, testCase.maximumLength)
+	} else {
+		countOfSkippedBytes, err = nibbler.SkipUntilSequence([]byte(testCase.delimiter), testCase.handling, testCase.maximumLength)
+	}
+
+	if testCase.expectAnError {
+		if err == nil || err == io.EOF {
+			return fmt.Errorf("expected an error, got (%v)", err)
+		}
+	} else if testCase.expectedError == nil && err != nil {
+		return fmt.Errorf("expected no error, got error = (%s)", err.Error())
+	} else if testCase.expectedError != nil && !errors.Is(err, testCase.expectedError) {
+		return fmt.Errorf("expected error (%v), got (%v)", testCase.expectedError, err)
+	}
+
+	if string(readBytes) != testCase.expectedBytes {
+		return fmt.Errorf("expected bytes (%s), got (%s)", byteSliceToSanitizedString([]byte(testCase.expectedBytes)), byteSliceToSanitizedString(readBytes))
+	}
+
+	if countOfSkippedBytes != testCase.expectedCountOfSkipped {
+		return fmt.Errorf("expected (%d) skipped bytes, got (%d)", testCase.expectedCountOfSkipped, countOfSkippedBytes)
+	}
+
+	nextByte, err := nibbler.PeekAtNextByte()
+	if testCase.expectedNextByteAfterCall == 0 {
+		if err != io.EOF {
+			return fmt.Errorf("expected io.EOF on peek after operation, got (%v)", err)
+		}
+	} else if err != nil {
+		return fmt.Errorf("expected no error on peek after operation, got error = (%s)", err.Error())
+	} else if nextByte != testCase.expectedNextByteAfterCall {
+		return fmt.Errorf("expected (%c) on peek after operation, got (%c)", testCase.expectedNextByteAfterCall, nextByte)
+	}
+
+	return nil
+}
+
+func testAnyByteNibblerForSequences(nibbler nibblers.ByteNibbler, baseTestName string, t *testing.T) {
+	for testCaseIndex, testCase := range []*byteSequenceTestCase{
+		{operation: "Read", delimiter: "\r\n", handling: nibblers.ConsumeDelimiter, maximumLength: nibblers.UnlimitedSequenceLength, expectedBytes: "GET / HTTP/1.1", expectedNextByteAfterCall: 'H'},
+		{operation: "Read", delimiter: "\r\n\r\n", handling: nibblers.ExcludeDelimiter, maximumLength: 3, expectedError: nibblers.ErrSequenceLengthExceeded, expectedNextByteAfterCall: 'H'},
+		{operation: "Read", delimiter: "\r\n\r\n", handling: nibblers.IncludeDelimiter, maximumLength: 7, expectedBytes: "Host: x\r\n\r\n", expectedNextByteAfterCall: 'b'},
+		{operation: "Skip", delimiter: "*/", handling: nibblers.ExcludeDelimiter, maximumLength: nibblers.UnlimitedSequenceLength, expectedCountOfSkipped: 4, expectedNextByteAfterCall: '*'},
+		{operation: "Skip", delimiter: "*/", handling: nibblers.ConsumeDelimiter, maximumLength: 0, expectedCountOfSkipped: 2, expectedNextByteAfterCall: 't'},
+		{operation: "Read", delimiter: "zz", handling: nibblers.ExcludeDelimiter, maximumLength: 2, expectedError: nibblers.ErrSequenceLengthExceeded, expectedNextByteAfterCall: 't'},
+		{operation: "Read", delimiter: "", handling: nibblers.ExcludeDelimiter, maximumLength: nibblers.UnlimitedSequenceLength, expectAnError: true, expectedNextByteAfterCall: 't'},
+		{operation: "Read", delimiter: "zz", handling: nibblers.ExcludeDelimiter, maximumLength: nibblers.UnlimitedSequenceLength, expectedBytes: "tail", expectedError: io.EOF},
+		{operation: "Skip", delimiter: "zz", handling: nibblers.ExcludeDelimiter, maximumLength: nibblers.UnlimitedSequenceLength, expectedError: io.EOF},
+	} {
+		if err := testCase.runTestCaseAgainst(nibbler); err != nil {
+			t.Errorf("(%s) (test case %d) %s", baseTestName, testCaseIndex+1, err.Error())
+		}
+	}
+}
+
+func TestByteNibblerSequences(t *testing.T) {
+	stream := []byte("GET / HTTP/1.1\r\nHost: x\r\n\r\nbody*/tail")
+
+	testAnyByteNibblerForSequences(nibblers.NewByteSliceNibbler(stream), "TestByteNibblerSequences ByteSliceNibbler", t)
+
+	reader := mock.NewReader()
+	for i := 0; i < len(stream); i += 3 {
+		end := i + 3
+		if end > len(stream) {
+			end = len(stream)
+		}
+		reader.AddGoodRead(stream[i:end])
+	}
+
+	readerNibbler := nibblers.NewByteReaderNibbler(reader.AddEOF())
+	readerNibbler.SetMaximumUnreadDepth(0)
+	testAnyByteNibblerForSequences(readerNibbler, "TestByteNibblerSequences ByteReaderNibbler", t)
+}
+
+func testAnyByteNibblerForIOInterfaces(nibbler nibblers.ByteNibbler, baseTestName string, t *testing.T) {
+	var scanner io.ByteScanner = nibbler
+
+	if b, err := scanner.ReadByte(); err != nil || b != 'G' {
+		t.Errorf("(%s) on ReadByte expected ('G'), got (%q) and (%v)", baseTestName, b, err)
+	}
+
+	readBytes := make([]byte, 4)
+	if _, err := io.ReadFull(nibbler, readBytes); err != nil || string(readBytes) != "ET /" {
+		t.Errorf("(%s) on io.ReadFull expected (ET /), got (%s) and (%v)", baseTestName, readBytes, err)
+	}
+
+	if err := scanner.UnreadByte(); err != nil {
+		t.Errorf("(%s) on UnreadByte after Read expected no error, got (%s)", baseTestName, err.Error())
+	}
+
+	if b, err := scanner.ReadByte(); err != nil || b != '/' {
+		t.Errorf("(%s) on ReadByte after UnreadByte expected ('/'), got (%q) and (%v)", baseTestName, b, err)
+	}
+
+	var path string
+	var code int
+	if _, err := fmt.Fscan(nibbler, &path, &code); err != nil || path != "index" || code != 200 {
+		t.Errorf("(%s) on fmt.Fscan expected (index) and (200), got (%s) and (%d) and (%v)", baseTestName, path, code, err)
+	}
+
+	var receiver bytes.Buffer
+	if countOfWrittenBytes, err := nibbler.WriteTo(&receiver); err != nil || countOfWrittenBytes != 10 || receiver.String() != "tail-bytes" {
+		t.Errorf("(%s) on WriteTo expected (tail-bytes) and (10), got (%s) and (%d) and (%v)", baseTestName, receiver.String(), countOfWrittenBytes, err)
+	}
+
+	if countOfReadBytes, err := nibbler.Read(readBytes[:0]); err != nil || countOfReadBytes != 0 {
+		t.Errorf("(%s) on Read of empty slice at end of stream expected (0) and no error, got (%d) and (%v)", baseTestName, countOfReadBytes, err)
+	}
+
+	if countOfReadBytes, err := nibbler.Read(readBytes); err != io.EOF || countOfReadBytes != 0 {
+		t.Errorf("(%s) on Read at end of stream expected (0) and io.EOF, got (%d) and (%v)", baseTestName, countOfReadBytes, err)
+	}
+}
+
+func TestByteNibblerIOInterfaces(t *testing.T) {
+	stream := []byte("GET /index 200 tail-bytes")
+
+	testAnyByteNibblerForIOInterfaces(nibblers.NewByteSliceNibbler(stream), "TestByteNibblerIOInterfaces ByteSliceNibbler", t)
+
+	reader := mock.NewReader()
+	for i := 0; i < len(stream); i += 3 {
+		end := i + 3
+		if end > len(stream) {
+			end = len(stream)
+		}
+		reader.AddGoodRead(stream[i:end])
+	}
+
+	testAnyByteNibblerForIOInterfaces(nibblers.NewByteReaderNibbler(reader.AddEOF()), "TestByteNibblerIOInterfaces ByteReaderNibbler", t)
+}
+
+func TestByteReaderNibblerRemainder(t *testing.T) {
+	reader := mock.NewReader().AddGoodRead([]byte("Length: 9\r\n")).AddGoodRead([]byte("\r\n{\"a\":")).AddGoodRead([]byte("1}\n")).AddEOF()
+	nibbler := nibblers.NewByteReaderNibbler(reader)
+
+	if _, err := nibbler.SkipUntilSequence([]byte("\r\n\r\n"), nibblers.ConsumeDelimiter, nibblers.UnlimitedSequenceLength); err != nil {
+		t.Fatalf("(TestByteReaderNibblerRemainder) on SkipUntilSequence expected no error, got (%s)", err.Error())
+	}
+
+	var body map[string]int
+	if err := json.NewDecoder(nibbler.Remainder()).Decode(&body); err != nil || body["a"] != 1 {
+		t.Errorf("(TestByteReaderNibblerRemainder) on json Decode of Remainder expected map[a:1], got (%v) and (%v)", body, err)
+	}
+
+	if _, err := nibbler.ReadByte(); !errors.Is(err, nibblers.ErrDetached) {
+		t.Errorf("(TestByteReaderNibblerRemainder) on ReadByte after Remainder expected ErrDetached, got (%v)", err)
+	}
+
+	if err := nibbler.UnreadByte(); err != nil {
+		t.Errorf("(TestByteReaderNibblerRemainder) on UnreadByte after Remainder expected no error, got (%s)", err.Error())
+	}
+
+	if b, err := nibbler.ReadByte(); err != nil || b != '\n' {
+		t.Errorf("(TestByteReaderNibblerRemainder) on ReadByte after UnreadByte expected ('\\n'), got (%q) and (%v)", b, err)
+	}
+
+	if remainingBytes, err := ioutil.ReadAll(nibbler.Remainder()); err != nil || len(remainingBytes) != 0 {
+		t.Errorf("(TestByteReaderNibblerRemainder) on second Remainder expected no bytes, got (%q) and (%v)", remainingBytes, err)
+	}
+}
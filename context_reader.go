@@ -0,0 +1,285 @@
+package nibblers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math"
+	"time"
+)
+
+// UnlimitedRetries can be provided as the MaximumRetries of an EmptyReadPolicy to retry empty Reads until
+// the stream returns bytes or an error (or the nibbler's context is done).
+const UnlimitedRetries = -1
+
+// EmptyReadPolicy determines what a reader-backed nibbler does when a Read of its stream returns no bytes
+// and no error, which the io.Reader contract permits.  The zero value retries nothing, so an empty Read
+// returns ErrNoProgress.
+type EmptyReadPolicy struct {
+	// MaximumRetries is the number of times that the stream is read again after an empty Read before
+	// ErrNoProgress is returned.  If it is UnlimitedRetries (or any negative value), the stream is read
+	// until it returns bytes or an error.
+	MaximumRetries int
+
+	// Backoff is the delay before the first retry.  The delay doubles with each subsequent retry.  If
+	// Backoff is zero, retries are immediate.
+	Backoff time.Duration
+
+	// MaximumBackoff, if greater than zero, caps the delay between retries.
+	MaximumBackoff time.Duration
+}
+
+// delayBeforeRetry returns the delay before retry number retryIndex (counting from zero).
+func (policy EmptyReadPolicy) delayBeforeRetry(retryIndex int) time.Duration {
+	delay := policy.Backoff
+	for i := 0; i < retryIndex && delay > 0; i++ {
+		if (policy.MaximumBackoff > 0 && delay >= policy.MaximumBackoff) || delay > math.MaxInt64/2 {
+			break
+		}
+
+		delay *= 2
+	}
+
+	if policy.MaximumBackoff > 0 && delay > policy.MaximumBackoff {
+		return policy.MaximumBackoff
+	}
+
+	return delay
+}
+
+var errDeadlinesNotSupported = errors.New("stream does not support read deadlines")
+
+// readDeadlineSetter is implemented by streams, such as net.Conn and os.File, whose blocked Reads can be
+// interrupted by a deadline.
+type readDeadlineSetter interface {
+	SetReadDeadline(t time.Time) error
+}
+
+type streamReadResult struct {
+	readBytes []byte
+	err       error
+}
+
+// contextualStreamReader reads from the stream of a reader-backed nibbler, giving up on a blocked Read when
+// the nibbler's context is done.  If the stream supports read deadlines, the deadline is used to interrupt
+// the Read.  Otherwise, the Read is started in a goroutine, and if the context is done first, the Read is left
+// pending so that its result is returned by the next attempt rather than lost.
+//
+// Unlike a bare io.Reader, a contextualStreamReader never returns bytes together with an error (the error
+// is held back and returned by the next Read), and, until it is detached, never returns zero bytes without an
+// error (empty Reads are retried as directed by the EmptyReadPolicy).
+type contextualStreamReader struct {
+	source                 io.Reader
+	ctx                    context.Context
+	emptyReadPolicy        EmptyReadPolicy
+	pendingRead            chan streamReadResult // nil unless a Read started in a goroutine has not been collected
+	bytesLeftFromLastRead  []byte
+	errorHeldFromLastRead  error
+	sourceRejectsDeadlines bool
+	isDetached             bool
+}
+
+func newContextualStreamReader(source io.Reader) *contextualStreamReader {
+	return &contextualStreamReader{
+		source:                 source,
+		ctx:                    context.Background(),
+		emptyReadPolicy:        EmptyReadPolicy{},
+		pendingRead:            nil,
+		bytesLeftFromLastRead:  nil,
+		errorHeldFromLastRead:  nil,
+		sourceRejectsDeadlines: false,
+		isDetached:             false,
+	}
+}
+
+// setContext binds the reader to ctx.  An error from the previous context that is held back from the last
+// Read is discarded, so that the Read can be retried under ctx.
+func (reader *contextualStreamReader) setContext(ctx context.Context) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	if reader.errorHeldFromLastRead == context.Canceled || reader.errorHeldFromLastRead == context.DeadlineExceeded {
+		reader.errorHeldFromLastRead = nil
+	}
+
+	reader.ctx = ctx
+}
+
+func (reader *contextualStreamReader) setEmptyReadPolicy(policy EmptyReadPolicy) {
+	reader.emptyReadPolicy = policy
+}
+
+// detach returns the reader itself, no longer bound to a context, so that it can be handed off with a
+// pending Read intact.  The nibbler's EmptyReadPolicy no longer applies: an empty Read of the source is
+// returned as it is, as an io.Reader would return it.
+func (reader *contextualStreamReader) detach() io.Reader {
+	reader.ctx = context.Background()
+	reader.isDetached = true
+	return reader
+}
+
+func (reader *contextualStreamReader) Read(p []byte) (int, error) {
+	if len(reader.bytesLeftFromLastRead) > 0 {
+		countOfCopiedBytes := copy(p, reader.bytesLeftFromLastRead)
+		reader.bytesLeftFromLastRead = reader.bytesLeftFromLastRead[countOfCopiedBytes:]
+		return countOfCopiedBytes, nil
+	}
+
+	if reader.errorHeldFromLastRead != nil {
+		err := reader.errorHeldFromLastRead
+		reader.errorHeldFromLastRead = nil
+		return 0, err
+	}
+
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	for retryIndex := 0; ; retryIndex++ {
+		countOfReadBytes, err := reader.readOnce(p)
+
+		if countOfReadBytes > 0 && err != nil {
+			reader.errorHeldFromLastRead = err
+			return countOfReadBytes, nil
+		}
+
+		if countOfReadBytes > 0 || err != nil || reader.isDetached {
+			return countOfReadBytes, err
+		}
+
+		if reader.emptyReadPolicy.MaximumRetries >= 0 && retryIndex >= reader.emptyReadPolicy.MaximumRetries {
+			return 0, ErrNoProgress
+		}
+
+		if err := reader.waitBeforeRetry(retryIndex); err != nil {
+			return 0, err
+		}
+	}
+}
+
+// readOnce makes a single Read of the source, or collects the result of a pending Read.
+func (reader *contextualStreamReader) readOnce(p []byte) (int, error) {
+	switch {
+	case reader.pendingRead != nil:
+		return reader.collectPendingRead(p)
+
+	case reader.ctx.Done() == nil:
+		return reader.source.Read(p)
+
+	case reader.ctx.Err() != nil:
+		return 0, reader.ctx.Err()
+	}
+
+	countOfReadBytes, err := reader.readWithDeadline(p)
+	if err == errDeadlinesNotSupported {
+		reader.startPendingRead(len(p))
+		return reader.collectPendingRead(p)
+	}
+
+	return countOfReadBytes, err
+}
+
+// waitBeforeRetry waits for the EmptyReadPolicy backoff before retry number retryIndex.  It returns ctx.Err()
+// if the context is done first.
+func (reader *contextualStreamReader) waitBeforeRetry(retryIndex int) error {
+	delay := reader.emptyReadPolicy.delayBeforeRetry(retryIndex)
+	if delay <= 0 {
+		return reader.ctx.Err()
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-reader.ctx.Done():
+		return reader.ctx.Err()
+	}
+}
+
+// readWithDeadline reads from the source after setting its read deadline to the context deadline, and sets
+// the deadline to a time in the past if the context is cancelled first.  The deadline is cleared afterward,
+// since there is no way to recover a deadline that the caller set (see SetContext).  It is only used for a
+// context that can be done.  A timeout of the Read is returned as the context error only if the context is
+// done or its deadline has passed.  It returns errDeadlinesNotSupported without reading if the source cannot
+// set read deadlines.
+func (reader *contextualStreamReader) readWithDeadline(p []byte) (int, error) {
+	source, sourceCanSetDeadlines := reader.source.(readDeadlineSetter)
+	if !sourceCanSetDeadlines || reader.sourceRejectsDeadlines {
+		return 0, errDeadlinesNotSupported
+	}
+
+	deadline, contextHasDeadline := reader.ctx.Deadline()
+	if source.SetReadDeadline(deadline) != nil {
+		reader.sourceRejectsDeadlines = true
+		return 0, errDeadlinesNotSupported
+	}
+
+	stopWatchingContext := make(chan struct{})
+	watcherHasStopped := make(chan struct{})
+	go func() {
+		defer close(watcherHasStopped)
+		select {
+		case <-reader.ctx.Done():
+			source.SetReadDeadline(time.Unix(1, 0))
+		case <-stopWatchingContext:
+		}
+	}()
+
+	countOfReadBytes, err := reader.source.Read(p)
+
+	close(stopWatchingContext)
+	<-watcherHasStopped
+	source.SetReadDeadline(time.Time{})
+
+	if timeoutError, isTimeout := err.(interface{ Timeout() bool }); isTimeout && timeoutError.Timeout() {
+		if reader.ctx.Err() != nil {
+			return countOfReadBytes, reader.ctx.Err()
+		}
+
+		// the context deadline may pass a moment before the context notices, but a timeout before it is the
+		// source's own
+		if contextHasDeadline && !time.Now().Before(deadline) {
+			<-reader.ctx.Done()
+			return countOfReadBytes, reader.ctx.Err()
+		}
+	}
+
+	return countOfReadBytes, err
+}
+
+func (reader *contextualStreamReader) startPendingRead(bufferLength int) {
+	pendingRead := make(chan streamReadResult, 1)
+	reader.pendingRead = pendingRead
+
+	go func() {
+		readBuffer := make([]byte, bufferLength)
+		countOfReadBytes, err := reader.source.Read(readBuffer)
+		pendingRead <- streamReadResult{readBytes: readBuffer[:countOfReadBytes], err: err}
+	}()
+}
+
+// collectPendingRead waits for the pending Read to finish, or for the context to be done, in which case the
+// Read remains pending.
+func (reader *contextualStreamReader) collectPendingRead(p []byte) (int, error) {
+	var result streamReadResult
+
+	select {
+	case result = <-reader.pendingRead:
+	default:
+		select {
+		case result = <-reader.pendingRead:
+		case <-reader.ctx.Done():
+			return 0, reader.ctx.Err()
+		}
+	}
+
+	reader.pendingRead = nil
+
+	countOfCopiedBytes := copy(p, result.readBytes)
+	reader.bytesLeftFromLastRead = result.readBytes[countOfCopiedBytes:]
+
+	return countOfCopiedBytes, result.err
+}
@@ -0,0 +1,245 @@
+package nibblers_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"testing"
+	"time"
+
+	nibblers "github.com/blorticus-go/nibblers"
+	mock "github.com/blorticus/go-test-mocks"
+)
+
+// blockingReader is an io.Reader without a SetReadDeadline method, whose Read blocks until bytes are sent
+// on its channel.
+type blockingReader struct {
+	bytesToRead chan []byte
+}
+
+func (reader *blockingReader) Read(p []byte) (int, error) {
+	return copy(p, <-reader.bytesToRead), nil
+}
+
+// deadlineReader is an io.Reader with a SetReadDeadline method, whose Reads return the results queued in
+// readResults, after calling beforeRead if it is set.
+type deadlineReader struct {
+	readResults []streamReadResultForTest
+	beforeRead  func()
+}
+
+type streamReadResultForTest struct {
+	readBytes []byte
+	err       error
+}
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func (reader *deadlineReader) SetReadDeadline(deadline time.Time) error {
+	return nil
+}
+
+func (reader *deadlineReader) Read(p []byte) (int, error) {
+	if reader.beforeRead != nil {
+		reader.beforeRead()
+	}
+
+	if len(reader.readResults) == 0 {
+		return 0, io.EOF
+	}
+
+	result := reader.readResults[0]
+	reader.readResults = reader.readResults[1:]
+
+	return copy(p, result.readBytes), result.err
+}
+
+func TestByteReaderNibblerContextWithBlockingReader(t *testing.T) {
+	reader := &blockingReader{bytesToRead: make(chan []byte, 1)}
+	nibbler := nibblers.NewByteReaderNibbler(reader)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	nibbler.SetContext(ctx)
+
+	if _, err := nibbler.ReadByte(); err != context.DeadlineExceeded {
+		t.Errorf("(TestByteReaderNibblerContextWithBlockingReader) on ReadByte with expired context expected context.DeadlineExceeded, got (%v)", err)
+	}
+
+	if _, err := nibbler.PeekAtNextByte(); err != context.DeadlineExceeded {
+		t.Errorf("(TestByteReaderNibblerContextWithBlockingReader) on retry with expired context expected context.DeadlineExceeded, got (%v)", err)
+	}
+
+	reader.bytesToRead <- []byte("ab")
+	nibbler.SetContext(context.Background())
+
+	if readBytes, err := nibbler.ReadFixedNumberOfBytes(2); err != nil || string(readBytes) != "ab" {
+		t.Errorf("(TestByteReaderNibblerContextWithBlockingReader) on retry with new context expected (ab), got (%s) and (%v)", readBytes, err)
+	}
+}
+
+func TestByteReaderNibblerContextWithReadDeadlines(t *testing.T) {
+	nibblerSide, writerSide := net.Pipe()
+	defer nibblerSide.Close()
+	defer writerSide.Close()
+
+	nibbler := nibblers.NewByteReaderNibbler(nibblerSide)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	nibbler.SetContext(ctx)
+	time.AfterFunc(20*time.Millisecond, cancel)
+
+	if _, err := nibbler.ReadByte(); err != context.Canceled {
+		t.Errorf("(TestByteReaderNibblerContextWithReadDeadlines) on ReadByte with cancelled context expected context.Canceled, got (%v)", err)
+	}
+
+	go writerSide.Write([]byte("z"))
+	nibbler.SetContext(nil)
+
+	if b, err := nibbler.ReadByte(); err != nil || b != 'z' {
+		t.Errorf("(TestByteReaderNibblerContextWithReadDeadlines) on retry with new context expected ('z'), got (%q) and (%v)", b, err)
+	}
+
+	// without a context that can be done, a read deadline set by the caller is left in place
+	nibblerSide.SetReadDeadline(time.Now().Add(20 * time.Millisecond))
+
+	var netError net.Error
+	if _, err := nibbler.ReadByte(); !errors.As(err, &netError) || !netError.Timeout() {
+		t.Errorf("(TestByteReaderNibblerContextWithReadDeadlines) on ReadByte after caller set read deadline expected timeout, got (%v)", err)
+	}
+}
+
+func TestUTF8ReaderNibblerContextWithBlockingReader(t *testing.T) {
+	reader := &blockingReader{bytesToRead: make(chan []byte, 1)}
+	nibbler := nibblers.NewUTF8ReaderNibbler(reader)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	nibbler.SetContext(ctx)
+
+	if _, err := nibbler.ReadCharacter(); err != context.Canceled {
+		t.Errorf("(TestUTF8ReaderNibblerContextWithBlockingReader) on ReadCharacter with cancelled context expected context.Canceled, got (%v)", err)
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	nibbler.SetContext(ctx)
+
+	if _, err := nibbler.ReadCharacter(); err != context.DeadlineExceeded {
+		t.Errorf("(TestUTF8ReaderNibblerContextWithBlockingReader) on ReadCharacter with expired context expected context.DeadlineExceeded, got (%v)", err)
+	}
+
+	reader.bytesToRead <- []byte("é")
+	nibbler.SetContext(context.Background())
+
+	if r, err := nibbler.ReadCharacter(); err != nil || r != 'é' {
+		t.Errorf("(TestUTF8ReaderNibblerContextWithBlockingReader) on retry with new context expected ('é'), got (%q) and (%v)", r, err)
+	}
+}
+
+func TestReaderNibblersEmptyReadPolicy(t *testing.T) {
+	newReaderWithEmptyReads := func(countOfEmptyReads int) *mock.Reader {
+		reader := mock.NewReader().AddGoodRead([]byte("a"))
+		for i := 0; i < countOfEmptyReads; i++ {
+			reader.AddEmptyRead()
+		}
+		return reader.AddGoodRead([]byte("é")).AddEOF()
+	}
+
+	byteNibbler := nibblers.NewByteReaderNibbler(newReaderWithEmptyReads(2))
+	byteNibbler.ReadByte()
+
+	if _, err := byteNibbler.ReadByte(); !errors.Is(err, nibblers.ErrNoProgress) {
+		t.Errorf("(TestReaderNibblersEmptyReadPolicy) on ReadByte after empty Read with default policy expected ErrNoProgress, got (%v)", err)
+	}
+
+	byteNibbler.SetEmptyReadPolicy(nibblers.EmptyReadPolicy{MaximumRetries: 1, Backoff: time.Millisecond})
+	if b, err := byteNibbler.ReadByte(); err != nil || b != 0xc3 {
+		t.Errorf("(TestReaderNibblersEmptyReadPolicy) on ReadByte retry after empty Read expected (0xc3), got (0x%x) and (%v)", b, err)
+	}
+
+	utf8Nibbler := nibblers.NewUTF8ReaderNibbler(newReaderWithEmptyReads(5))
+	utf8Nibbler.SetEmptyReadPolicy(nibblers.EmptyReadPolicy{MaximumRetries: nibblers.UnlimitedRetries})
+	utf8Nibbler.ReadCharacter()
+
+	if r, err := utf8Nibbler.ReadCharacter(); err != nil || r != 'é' {
+		t.Errorf("(TestReaderNibblersEmptyReadPolicy) on ReadCharacter after empty Reads with unlimited retries expected ('é'), got (%q) and (%v)", r, err)
+	}
+
+	byteNibbler = nibblers.NewByteReaderNibbler(newReaderWithEmptyReads(2))
+	byteNibbler.ReadByte()
+
+	if remainingBytes, err := ioutil.ReadAll(byteNibbler.Remainder()); err != nil || string(remainingBytes) != "é" {
+		t.Errorf("(TestReaderNibblersEmptyReadPolicy) on ReadAll of ByteReaderNibbler Remainder with empty Reads expected (é), got (%q) and (%v)", remainingBytes, err)
+	}
+
+	utf8Nibbler = nibblers.NewUTF8ReaderNibbler(newReaderWithEmptyReads(2))
+	utf8Nibbler.ReadCharacter()
+
+	if remainingBytes, err := ioutil.ReadAll(utf8Nibbler.Remainder()); err != nil || string(remainingBytes) != "é" {
+		t.Errorf("(TestReaderNibblersEmptyReadPolicy) on ReadAll of UTF8ReaderNibbler Remainder with empty Reads expected (é), got (%q) and (%v)", remainingBytes, err)
+	}
+
+	utf8Nibbler = nibblers.NewUTF8ReaderNibbler(newReaderWithEmptyReads(1))
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	utf8Nibbler.SetContext(ctx)
+	utf8Nibbler.SetEmptyReadPolicy(nibblers.EmptyReadPolicy{MaximumRetries: 3, Backoff: time.Hour})
+
+	if _, err := utf8Nibbler.PeekAtNextCharacters(2); err != context.DeadlineExceeded {
+		t.Errorf("(TestReaderNibblersEmptyReadPolicy) on PeekAtNextCharacters with context expiring during backoff expected context.DeadlineExceeded, got (%v)", err)
+	}
+}
+
+func TestReaderNibblersContextErrorHeldWithBytes(t *testing.T) {
+	// the context is cancelled during a Read that returns a byte, so the context error is held back
+	ctx, cancel := context.WithCancel(context.Background())
+	reader := &deadlineReader{
+		readResults: []streamReadResultForTest{{readBytes: []byte("a"), err: timeoutError{}}, {readBytes: []byte("b")}},
+		beforeRead:  cancel,
+	}
+
+	nibbler := nibblers.NewByteReaderNibbler(reader)
+	nibbler.SetContext(ctx)
+
+	if b, err := nibbler.ReadByte(); err != nil || b != 'a' {
+		t.Errorf("(TestReaderNibblersContextErrorHeldWithBytes) on ReadByte expected ('a'), got (%q) and (%v)", b, err)
+	}
+
+	reader.beforeRead = nil
+	nibbler.SetContext(context.Background())
+
+	if b, err := nibbler.ReadByte(); err != nil || b != 'b' {
+		t.Errorf("(TestReaderNibblersContextErrorHeldWithBytes) on ReadByte after new context expected ('b'), got (%q) and (%v)", b, err)
+	}
+}
+
+func TestReaderNibblersTimeoutWithLiveContext(t *testing.T) {
+	// the source times out for its own reasons while the context can still be cancelled
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	nibbler := nibblers.NewUTF8ReaderNibbler(&deadlineReader{readResults: []streamReadResultForTest{{err: timeoutError{}}}})
+	nibbler.SetContext(ctx)
+
+	errorChannel := make(chan error, 1)
+	go func() {
+		_, err := nibbler.ReadCharacter()
+		errorChannel <- err
+	}()
+
+	select {
+	case err := <-errorChannel:
+		if !errors.As(err, &timeoutError{}) {
+			t.Errorf("(TestReaderNibblersTimeoutWithLiveContext) on ReadCharacter expected the timeout error of the source, got (%v)", err)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("(TestReaderNibblersTimeoutWithLiveContext) expected ReadCharacter to return the timeout error of the source, but it is still waiting")
+	}
+}
@@ -0,0 +1,68 @@
+package nibblers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ErrAtStartOfStream is returned when an unread is attempted while the cursor is at the start of the
+// stream.
+var ErrAtStartOfStream = errors.New("already at the start of the stream")
+
+// ErrUnreadLimitExceeded is returned when an unread would move the cursor further back than
+// the nibbler's rewind window allows.  This is distinct from ErrAtStartOfStream, which is returned
+// when the cursor is at the start of the stream.
+var ErrUnreadLimitExceeded = errors.New("unread would exceed the rewind window")
+
+// ErrUnknownSet is returned when a named byte set is requested but no set with that name is defined.
+var ErrUnknownSet = errors.New("no byte set with that name is defined")
+
+// ErrInvalidUTF8 is returned by a UTF8Nibbler when the bytes at the cursor are not a valid UTF8 encoding.
+var ErrInvalidUTF8 = errors.New("invalid UTF-8 encoding")
+
+// ErrTruncatedUTF8 is returned by a reader-backed UTF8Nibbler when the stream ends part way through the
+// UTF8 encoding of a character.
+var ErrTruncatedUTF8 = errors.New("stream ends within a UTF-8 encoding")
+
+// ErrBookendAlreadyActive is returned by StartBookending when a bookend is already active.
+var ErrBookendAlreadyActive = errors.New("a bookend is already active")
+
+// ErrNoProgress is returned by a reader-backed nibbler when a Read of its stream returns no bytes and no
+// error, and the nibbler's EmptyReadPolicy permits no further retries.  This is the same value as
+// io.ErrNoProgress.  No bytes from the stream are lost, so the operation can be retried later, which permits
+// a nibbler to treat an empty Read from a non-blocking stream as "would block".
+var ErrNoProgress = io.ErrNoProgress
+
+// NibbleError records the nibbler operation that failed and the byte offset in the stream at which it
+// failed.  The sentinel errors of this package (and a *PartialReadError) are returned by nibblers wrapped in a
+// *NibbleError, so they should be tested with errors.Is or errors.As, and the offset can be retrieved with
+// errors.As.  io.EOF is never wrapped, and an error returned by the stream or a context is returned as it is.
+type NibbleError struct {
+	// Op is the name of the nibbler method that failed, or "Read" for a failed Read of the stream.
+	Op string
+
+	// Offset is the byte offset in the stream of the cursor or, for ErrInvalidUTF8 and ErrTruncatedUTF8,
+	// of the invalid encoding.  For a failed Read of the stream, it is the offset of the first byte that was to be read.
+	Offset int64
+
+	// Err is the underlying error.
+	Err error
+}
+
+func newNibbleError(op string, offset int64, err error) *NibbleError {
+	return &NibbleError{
+		Op:     op,
+		Offset: offset,
+		Err:    err,
+	}
+}
+
+func (e *NibbleError) Error() string {
+	return fmt.Sprintf("%s at byte offset %d: %s", e.Op, e.Offset, e.Err.Error())
+}
+
+// Unwrap returns the underlying error.
+func (e *NibbleError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,245 @@
+package nibblers_test
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+
+	nibblers "github.com/blorticus-go/nibblers"
+	mock "github.com/blorticus/go-test-mocks"
+)
+
+type nibbleErrorTestCase struct {
+	description    string
+	operation      func() error
+	expectedError  error
+	expectedOp     string
+	expectedOffset int64
+}
+
+func (testCase *nibbleErrorTestCase) runTestCase() error {
+	err := testCase.operation()
+
+	if !errors.Is(err, testCase.expectedError) {
+		return fmt.Errorf("expected error wrapping (%v), got (%v)", testCase.expectedError, err)
+	}
+
+	var nibbleError *nibblers.NibbleError
+	if !errors.As(err, &nibbleError) {
+		return fmt.Errorf("expected a *NibbleError, got (%T)", err)
+	}
+
+	if nibbleError.Op != testCase.expectedOp {
+		return fmt.Errorf("expected Op (%s), got (%s)", testCase.expectedOp, nibbleError.Op)
+	}
+
+	if nibbleError.Offset != testCase.expectedOffset {
+		return fmt.Errorf("expected Offset (%d), got (%d)", testCase.expectedOffset, nibbleError.Offset)
+	}
+
+	return nil
+}
+
+func TestNibbleErrors(t *testing.T) {
+	byteSliceNibbler := nibblers.NewByteSliceNibbler([]byte("abc"))
+
+	byteReaderNibbler := nibblers.NewByteReaderNibbler(mock.NewReader().AddGoodRead([]byte("abcdef")).AddEOF())
+	byteReaderNibbler.SetMaximumUnreadDepth(1)
+	byteReaderNibbler.AddNamedByteSetsMap(nibblers.NewNamedByteSetsMap())
+
+	utf8StringNibbler := nibblers.NewUTF8StringNibbler("añ\xffb")
+	utf8RuneSliceNibbler := nibblers.NewUTF8RuneSliceNibbler([]rune("a"))
+
+	utf8ReaderNibbler := nibblers.NewUTF8ReaderNibbler(mock.NewReader().AddGoodRead([]byte("añ")).AddGoodRead([]byte("\xff\xffb")).AddGoodRead([]byte("cdefgh")).AddEOF())
+	utf8Matcher := nibblers.NewUTF8NibblerMatcher(utf8ReaderNibbler)
+
+	for testCaseIndex, testCase := range []*nibbleErrorTestCase{
+		{
+			description:   "ByteSliceNibbler UnreadByte at start",
+			operation:     byteSliceNibbler.UnreadByte,
+			expectedError: nibblers.ErrAtStartOfStream, expectedOp: "UnreadByte", expectedOffset: 0,
+		},
+		{
+			description: "ByteSliceNibbler ReadNextBytesMatchingSet without sets map",
+			operation: func() error {
+				byteSliceNibbler.ReadByte()
+				_, err := byteSliceNibbler.ReadNextBytesMatchingSet(nibblers.ByteSetDigit)
+				return err
+			},
+			expectedError: nibblers.ErrUnknownSet, expectedOp: "ReadNextBytesMatchingSet", expectedOffset: 1,
+		},
+		{
+			description: "ByteReaderNibbler ReadNextBytesNotMatchingSet with unknown set",
+			operation: func() error {
+				byteReaderNibbler.ReadFixedNumberOfBytes(3)
+				_, err := byteReaderNibbler.ReadNextBytesNotMatchingSet("no-such-set")
+				return err
+			},
+			expectedError: nibblers.ErrUnknownSet, expectedOp: "ReadNextBytesNotMatchingSet", expectedOffset: 3,
+		},
+		{
+			description: "ByteReaderNibbler UnreadByte past rewind window",
+			operation: func() error {
+				byteReaderNibbler.UnreadByte()
+				return byteReaderNibbler.UnreadByte()
+			},
+			expectedError: nibblers.ErrUnreadLimitExceeded, expectedOp: "UnreadByte", expectedOffset: 2,
+		},
+		{
+			description: "UTF8StringNibbler ReadCharacter of invalid encoding",
+			operation: func() error {
+				utf8StringNibbler.ReadCharacter()
+				utf8StringNibbler.ReadCharacter()
+				_, err := utf8StringNibbler.ReadCharacter()
+				return err
+			},
+			expectedError: nibblers.ErrInvalidUTF8, expectedOp: "ReadCharacter", expectedOffset: 3,
+		},
+		{
+			description:   "UTF8RuneSliceNibbler UnreadCharacter at start",
+			operation:     utf8RuneSliceNibbler.UnreadCharacter,
+			expectedError: nibblers.ErrAtStartOfStream, expectedOp: "UnreadCharacter", expectedOffset: 0,
+		},
+		{
+			description: "UTF8NibblerMatcher over UTF8ReaderNibbler with invalid encoding",
+			operation: func() error {
+				_, err := utf8Matcher.ReadConsecutiveWordCharacters()
+				return err
+			},
+			expectedError: nibblers.ErrInvalidUTF8, expectedOp: "ReadCharacter", expectedOffset: 3,
+		},
+		{
+			description: "ByteSliceNibbler StartBookending while a bookend is active",
+			operation: func() error {
+				nibbler := nibblers.NewByteSliceNibbler([]byte("abc"))
+				nibbler.ReadByte()
+				nibbler.StartBookending()
+				return nibbler.StartBookending()
+			},
+			expectedError: nibblers.ErrBookendAlreadyActive, expectedOp: "StartBookending", expectedOffset: 1,
+		},
+		{
+			description: "ByteSliceNibbler ReadUntilSequence past maximum length",
+			operation: func() error {
+				nibbler := nibblers.NewByteSliceNibbler([]byte("abcdef"))
+				nibbler.ReadByte()
+				_, err := nibbler.ReadUntilSequence([]byte("z"), nibblers.ExcludeDelimiter, 2)
+				return err
+			},
+			expectedError: nibblers.ErrSequenceLengthExceeded, expectedOp: "ReadUntilSequence", expectedOffset: 1,
+		},
+		{
+			description: "ByteSliceNibbler ReadUnsignedLEB128 overflow",
+			operation: func() error {
+				nibbler := nibblers.NewByteSliceNibbler([]byte{0x61, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x02})
+				nibbler.ReadByte()
+				_, err := nibbler.ReadUnsignedLEB128()
+				return err
+			},
+			expectedError: nibblers.ErrVarintOverflow, expectedOp: "ReadUnsignedLEB128", expectedOffset: 1,
+		},
+		{
+			description: "ByteReaderNibbler ReadUint32 with three bytes remaining",
+			operation: func() error {
+				nibbler := nibblers.NewByteReaderNibbler(mock.NewReader().AddGoodRead([]byte("abcd")).AddEOF())
+				nibbler.ReadByte()
+				_, err := nibbler.ReadUint32(binary.BigEndian)
+				return err
+			},
+			expectedError: io.ErrUnexpectedEOF, expectedOp: "ReadUint32", expectedOffset: 1,
+		},
+		{
+			description: "ByteReaderNibbler SkipUntilSequence past maximum length",
+			operation: func() error {
+				nibbler := nibblers.NewByteReaderNibbler(mock.NewReader().AddGoodRead([]byte("abcdef")).AddEOF())
+				nibbler.ReadByte()
+				_, err := nibbler.SkipUntilSequence([]byte("z"), nibblers.ExcludeDelimiter, 2)
+				return err
+			},
+			expectedError: nibblers.ErrSequenceLengthExceeded, expectedOp: "SkipUntilSequence", expectedOffset: 1,
+		},
+		{
+			description: "ByteReaderNibbler ResetTo released Mark",
+			operation: func() error {
+				nibbler := nibblers.NewByteReaderNibbler(mock.NewReader().AddGoodRead([]byte("abcdef")).AddEOF())
+				nibbler.ReadByte()
+				mark := nibbler.Mark()
+				nibbler.Release(mark)
+				nibbler.ReadByte()
+				return nibbler.ResetTo(mark)
+			},
+			expectedError: nibblers.ErrInvalidMark, expectedOp: "ResetTo", expectedOffset: 2,
+		},
+		{
+			description: "ByteReaderNibbler ReadByte after Remainder",
+			operation: func() error {
+				nibbler := nibblers.NewByteReaderNibbler(mock.NewReader().AddGoodRead([]byte("abc")).AddEOF())
+				nibbler.ReadFixedNumberOfBytes(3)
+				nibbler.Remainder()
+				_, err := nibbler.ReadByte()
+				return err
+			},
+			expectedError: nibblers.ErrDetached, expectedOp: "Read", expectedOffset: 3,
+		},
+		{
+			description: "UTF8StringNibbler StartBookending while a bookend is active",
+			operation: func() error {
+				nibbler := nibblers.NewUTF8StringNibbler("ñbc")
+				nibbler.ReadCharacter()
+				nibbler.StartBookending()
+				return nibbler.StartBookending()
+			},
+			expectedError: nibblers.ErrBookendAlreadyActive, expectedOp: "StartBookending", expectedOffset: 2,
+		},
+		{
+			description: "UTF8RuneSliceNibbler ResetTo released Mark",
+			operation: func() error {
+				nibbler := nibblers.NewUTF8RuneSliceNibbler([]rune("ñbc"))
+				nibbler.ReadCharacter()
+				mark := nibbler.Mark()
+				nibbler.Release(mark)
+				return nibbler.ResetTo(mark)
+			},
+			expectedError: nibblers.ErrInvalidMark, expectedOp: "ResetTo", expectedOffset: 2,
+		},
+		{
+			description: "UTF8ReaderNibbler ReadCharacter past maximum bookend length",
+			operation: func() error {
+				nibbler := nibblers.NewUTF8ReaderNibbler(mock.NewReader().AddGoodRead([]byte("añbc")).AddEOF())
+				nibbler.SetMaximumBookendLength(3)
+				nibbler.ReadCharacter()
+				nibbler.StartBookending()
+				nibbler.ReadCharacter()
+				nibbler.ReadCharacter()
+				_, err := nibbler.ReadCharacter()
+				return err
+			},
+			expectedError: nibblers.ErrBookendLimitExceeded, expectedOp: "ReadCharacter", expectedOffset: 4,
+		},
+		{
+			description: "UTF8NibblerMatcher ReadUntilSequence past maximum length",
+			operation: func() error {
+				nibbler := nibblers.NewUTF8StringNibbler("ñbcdef")
+				nibbler.ReadCharacter()
+				_, err := nibblers.NewUTF8NibblerMatcher(nibbler).ReadUntilSequence([]rune("z"), nibblers.ExcludeDelimiter, 2)
+				return err
+			},
+			expectedError: nibblers.ErrSequenceLengthExceeded, expectedOp: "ReadUntilSequence", expectedOffset: 2,
+		},
+	} {
+		if err := testCase.runTestCase(); err != nil {
+			t.Errorf("(TestNibbleErrors) (test case %d: %s) %s", testCaseIndex+1, testCase.description, err.Error())
+		}
+	}
+
+	var partialReadError *nibblers.PartialReadError
+	if _, err := nibblers.NewByteSliceNibbler([]byte("abc")).ReadUint32(binary.BigEndian); !errors.As(err, &partialReadError) {
+		t.Errorf("(TestNibbleErrors) expected a *PartialReadError from ReadUint32 with three bytes, got (%v)", err)
+	}
+
+	if _, err := nibblers.NewByteSliceNibbler(nil).ReadByte(); err != io.EOF {
+		t.Errorf("(TestNibbleErrors) expected bare io.EOF from ReadByte at end of stream, got (%v)", err)
+	}
+}
@@ -0,0 +1,320 @@
+package nibblers
+
+import (
+	"errors"
+	"io"
+	"regexp"
+)
+
+// DefaultLexerMode is the mode in which a Lexer starts.  The rules provided to NewLexer and AddRule belong
+// to this mode.
+const DefaultLexerMode = "default"
+
+// ErrUnknownLexerMode is returned by a Lexer when a rule (or PushMode) enters a mode that has no rules.
+var ErrUnknownLexerMode = errors.New("lexer mode has no rules")
+
+// ErrNoLexerModeToPop is returned by a Lexer when a rule (or PopMode) leaves a mode while the Lexer is in the
+// DefaultLexerMode that it started in.
+var ErrNoLexerModeToPop = errors.New("no lexer mode to pop")
+
+// TokenKind identifies the kind of a Token.  The kinds are chosen by the caller when rules are created,
+// except for UnknownCharacterToken.
+type TokenKind string
+
+// UnknownCharacterToken is the kind of the Token returned by a Lexer for a character at which no rule
+// matches.  The Token holds just that character, and the Lexer continues from the character after it.
+const UnknownCharacterToken TokenKind = "UnknownCharacter"
+
+// Token is a unit of text recognized by a Lexer.  StartPos is the position of the first character of Text, and
+// EndPos is the position of the character after it.
+type Token struct {
+	Kind     TokenKind
+	Text     string
+	StartPos Position
+	EndPos   Position
+}
+
+// LexerRuleFunction is used by a custom LexerRule.  It reads the characters of a token from nibbler and
+// returns true, or returns false if there is no token at the cursor.  It need not restore the cursor, and
+// the characters that it reads beyond the token (for example, to find the end of the token) are ignored if
+// it moves the cursor back with UnreadCharacter or ResetTo.  Reaching io.EOF is the same as returning false.
+type LexerRuleFunction func(nibbler UTF8Nibbler) (tokenIsMatched bool, err error)
+
+// LexerRule recognizes one kind of token.  A LexerRule is created by NewLiteralRule, NewMatchingRule,
+// NewRegexpRule or NewCustomRule, and is a value, so the modifying methods return a modified copy.
+type LexerRule struct {
+	kind          TokenKind
+	isSkipped     bool
+	modeToPush    string // empty if the rule does not push a mode
+	popsMode      bool
+	matchAtCursor func(matcher *UTF8NibblerMatcher) (bool, error)
+}
+
+// NewLiteralRule returns a LexerRule that matches exactly the characters of literal.
+func NewLiteralRule(kind TokenKind, literal string) LexerRule {
+	literalCharacters := []rune(literal)
+
+	return LexerRule{
+		kind: kind,
+		matchAtCursor: func(matcher *UTF8NibblerMatcher) (bool, error) {
+			for _, literalCharacter := range literalCharacters {
+				if nextCharacter, err := matcher.nibbler.ReadCharacter(); err != nil || nextCharacter != literalCharacter {
+					return false, err
+				}
+			}
+
+			return len(literalCharacters) > 0, nil
+		},
+	}
+}
+
+// NewMatchingRule returns a LexerRule that matches one or more consecutive characters for which
+// matchFunction returns true.
+func NewMatchingRule(kind TokenKind, matchFunction CharacterMatchingFunction) LexerRule {
+	return LexerRule{
+		kind: kind,
+		matchAtCursor: func(matcher *UTF8NibblerMatcher) (bool, error) {
+			matchingCharacters, err := matcher.ReadConsecutiveCharactersMatching(matchFunction)
+			return len(matchingCharacters) > 0, err
+		},
+	}
+}
+
+// NewRegexpRule returns a LexerRule that matches re at the cursor, as UTF8NibblerMatcher.ReadMatchingRegexp
+// does.
+func NewRegexpRule(kind TokenKind, re *regexp.Regexp) LexerRule {
+	return NewRegexpRuleWithSyntax(kind, re, PerlRegexpSyntax)
+}
+
+// NewRegexpRuleWithSyntax returns a LexerRule that matches re, which was compiled with syntaxOfRegexp, at the
+// cursor, as UTF8NibblerMatcher.ReadMatchingRegexpWithSyntax does.
+func NewRegexpRuleWithSyntax(kind TokenKind, re *regexp.Regexp, syntaxOfRegexp RegexpSyntax) LexerRule {
+	anchored := anchoredRegexp(re, syntaxOfRegexp)
+
+	return LexerRule{
+		kind: kind,
+		matchAtCursor: func(matcher *UTF8NibblerMatcher) (bool, error) {
+			submatches, err := matcher.readMatchingAnchoredRegexp(anchored)
+			return submatches != nil, err
+		},
+	}
+}
+
+// NewCustomRule returns a LexerRule that matches the characters read by ruleFunction.
+func NewCustomRule(kind TokenKind, ruleFunction LexerRuleFunction) LexerRule {
+	return LexerRule{
+		kind: kind,
+		matchAtCursor: func(matcher *UTF8NibblerMatcher) (bool, error) {
+			return ruleFunction(matcher.nibbler)
+		},
+	}
+}
+
+// Skipped returns a copy of the rule whose tokens are discarded by the Lexer rather than returned.  This is
+// typically used for whitespace and comments.
+func (rule LexerRule) Skipped() LexerRule {
+	rule.isSkipped = true
+	return rule
+}
+
+// PushingMode returns a copy of the rule that, after each token it matches, pushes mode onto the Lexer mode
+// stack, so that the following tokens are matched by the rules of mode.
+func (rule LexerRule) PushingMode(mode string) LexerRule {
+	rule.modeToPush = mode
+	return rule
+}
+
+// PoppingMode returns a copy of the rule that, after each token it matches, pops the current mode from the
+// Lexer mode stack, returning to the mode that was current before it was pushed.
+func (rule LexerRule) PoppingMode() LexerRule {
+	rule.popsMode = true
+	return rule
+}
+
+// Kind returns the TokenKind of the tokens matched by the rule.
+func (rule LexerRule) Kind() TokenKind {
+	return rule.kind
+}
+
+// Lexer splits the characters from a UTF8Nibbler into Tokens, using an ordered set of LexerRules.  For
+// embedded sub-languages (such as the expressions in an interpolated string), a Lexer can have more than one
+// mode, each with its own rules.  Modes are kept on a stack: a rule can push a mode, which is then current
+// until a rule pops it.  At each token, every rule of the current mode is tried at the cursor, and the rule
+// that matches the most characters wins; if rules tie, the earliest rule wins.  A rule that matches no
+// characters is treated as not matching.  Because every rule is tried from the same point, the nibbler is
+// marked at the start of each token, so a reader-backed nibbler retains the characters read ahead by the
+// rules until the token is complete.  Matching rules unread the character that ends a match, as the
+// UTF8NibblerMatcher does, so the nibbler must permit at least one character to be unread.
+type Lexer struct {
+	nibbler      UTF8Nibbler
+	matcher      *UTF8NibblerMatcher
+	rulesForMode map[string][]LexerRule
+	modeStack    []string
+}
+
+// NewLexer returns a new Lexer reading from nibbler, starting at its cursor, in the DefaultLexerMode, which
+// uses the provided rules, in order of precedence.
+func NewLexer(nibbler UTF8Nibbler, rules ...LexerRule) *Lexer {
+	return &Lexer{
+		nibbler:      nibbler,
+		matcher:      NewUTF8NibblerMatcher(nibbler),
+		rulesForMode: map[string][]LexerRule{DefaultLexerMode: rules},
+		modeStack:    []string{DefaultLexerMode},
+	}
+}
+
+// AddRule appends rule to the rules of the DefaultLexerMode, with a lower precedence than the rules already
+// added.
+func (lexer *Lexer) AddRule(rule LexerRule) *Lexer {
+	return lexer.AddModeRule(DefaultLexerMode, rule)
+}
+
+// AddModeRule appends rule to the rules of mode, with a lower precedence than the rules already added to
+// mode.  A mode exists once it has a rule.
+func (lexer *Lexer) AddModeRule(mode string, rule LexerRule) *Lexer {
+	lexer.rulesForMode[mode] = append(lexer.rulesForMode[mode], rule)
+	return lexer
+}
+
+// Mode returns the current mode, which is the top of the mode stack.
+func (lexer *Lexer) Mode() string {
+	return lexer.modeStack[len(lexer.modeStack)-1]
+}
+
+// PushMode makes mode the current mode, as a rule created with PushingMode does.  Return
+// ErrUnknownLexerMode if mode has no rules.
+func (lexer *Lexer) PushMode(mode string) error {
+	if len(lexer.rulesForMode[mode]) == 0 {
+		return ErrUnknownLexerMode
+	}
+
+	lexer.modeStack = append(lexer.modeStack, mode)
+
+	return nil
+}
+
+// PopMode returns to the mode that was current before the current mode was pushed, as a rule created with
+// PoppingMode does.  Return ErrNoLexerModeToPop if the current mode is the DefaultLexerMode that the Lexer
+// started in.
+func (lexer *Lexer) PopMode() error {
+	if len(lexer.modeStack) == 1 {
+		return ErrNoLexerModeToPop
+	}
+
+	lexer.modeStack = lexer.modeStack[:len(lexer.modeStack)-1]
+
+	return nil
+}
+
+// NextToken returns the next Token that is not matched by a skipped rule, using the rules of the current
+// mode, and then makes the mode change, if any, of the matching rule.  The mode changes of skipped rules are
+// also made.  If no rule matches at the cursor, a Token of kind UnknownCharacterToken is returned for the
+// next character.  At the end of the stream, io.EOF is returned.  If the nibbler returns any other error, or
+// the matching rule cannot change the mode (ErrUnknownLexerMode or ErrNoLexerModeToPop), the error is
+// returned, and the cursor is left at the start of the token that was being read.
+func (lexer *Lexer) NextToken() (Token, error) {
+	for {
+		rule, countOfCharactersInToken, err := lexer.findLongestMatch(lexer.rulesForMode[lexer.Mode()])
+		if err != nil {
+			return Token{}, err
+		}
+
+		kind := UnknownCharacterToken
+		if rule != nil {
+			if err := lexer.checkModeChange(rule); err != nil {
+				return Token{}, err
+			}
+
+			kind = rule.kind
+		}
+
+		token, err := lexer.readToken(kind, countOfCharactersInToken)
+		if err != nil {
+			return Token{}, err
+		}
+
+		if rule == nil {
+			return token, nil
+		}
+
+		if rule.popsMode {
+			lexer.PopMode()
+		}
+
+		if rule.modeToPush != "" {
+			lexer.PushMode(rule.modeToPush)
+		}
+
+		if !rule.isSkipped {
+			return token, nil
+		}
+	}
+}
+
+// checkModeChange returns the error that the mode change of rule would produce, if any.  A rule may both pop
+// and push a mode, which replaces the current mode.
+func (lexer *Lexer) checkModeChange(rule *LexerRule) error {
+	if rule.popsMode && len(lexer.modeStack) == 1 {
+		return ErrNoLexerModeToPop
+	}
+
+	if rule.modeToPush != "" && len(lexer.rulesForMode[rule.modeToPush]) == 0 {
+		return ErrUnknownLexerMode
+	}
+
+	return nil
+}
+
+// findLongestMatch returns the rule with the longest match at the cursor, and the number of characters that
+// it matches, without moving the cursor.  If no rule matches, it returns a nil rule and a count of one, for
+// an UnknownCharacterToken.
+func (lexer *Lexer) findLongestMatch(rules []LexerRule) (*LexerRule, int64, error) {
+	runeOffsetAtStart := lexer.nibbler.Position().RuneOffset
+	mark := lexer.nibbler.Mark()
+	defer lexer.nibbler.Release(mark)
+
+	var longestMatchingRule *LexerRule
+	countOfCharactersInLongestMatch := int64(1)
+
+	for i := range rules {
+		tokenIsMatched, err := rules[i].matchAtCursor(lexer.matcher)
+		countOfCharactersMatched := lexer.nibbler.Position().RuneOffset - runeOffsetAtStart
+
+		if resetErr := lexer.nibbler.ResetTo(mark); resetErr != nil {
+			return nil, 0, resetErr
+		}
+
+		if err != nil && err != io.EOF {
+			return nil, 0, err
+		}
+
+		if tokenIsMatched && err == nil && countOfCharactersMatched > 0 &&
+			(longestMatchingRule == nil || countOfCharactersMatched > countOfCharactersInLongestMatch) {
+			longestMatchingRule = &rules[i]
+			countOfCharactersInLongestMatch = countOfCharactersMatched
+		}
+	}
+
+	return longestMatchingRule, countOfCharactersInLongestMatch, nil
+}
+
+// readToken reads the next countOfCharacters characters as a Token of the provided kind.  On error, the
+// cursor is returned to the start of the token.
+func (lexer *Lexer) readToken(kind TokenKind, countOfCharacters int64) (Token, error) {
+	startPosition := lexer.nibbler.Position()
+	mark := lexer.nibbler.Mark()
+	defer lexer.nibbler.Release(mark)
+
+	tokenCharacters := make([]rune, 0, countOfCharacters)
+	for ; countOfCharacters > 0; countOfCharacters-- {
+		nextCharacter, err := lexer.nibbler.ReadCharacter()
+		if err != nil {
+			lexer.nibbler.ResetTo(mark)
+			return Token{}, err
+		}
+
+		tokenCharacters = append(tokenCharacters, nextCharacter)
+	}
+
+	return Token{Kind: kind, Text: string(tokenCharacters), StartPos: startPosition, EndPos: lexer.nibbler.Position()}, nil
+}
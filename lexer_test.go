@@ -0,0 +1,298 @@
+package nibblers_test
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"testing"
+	"unicode"
+
+	nibblers "github.com/blorticus-go/nibblers"
+	mock "github.com/blorticus/go-test-mocks"
+)
+
+type expectedToken struct {
+	kind      nibblers.TokenKind
+	text      string
+	startLine int
+	startCol  int
+}
+
+func compareTokens(expected []expectedToken, got []nibblers.Token) error {
+	if len(expected) != len(got) {
+		return fmt.Errorf("expected (%d) tokens, got (%d): (%v)", len(expected), len(got), got)
+	}
+
+	for i := range expected {
+		if got[i].Kind != expected[i].kind || got[i].Text != expected[i].text {
+			return fmt.Errorf("on token %d expected (%s: %q), got (%s: %q)", i+1, expected[i].kind, expected[i].text, got[i].Kind, got[i].Text)
+		}
+
+		if got[i].StartPos.Line != expected[i].startLine || got[i].StartPos.Column != expected[i].startCol {
+			return fmt.Errorf("on token %d (%q) expected start at line (%d) column (%d), got line (%d) column (%d)", i+1, got[i].Text, expected[i].startLine, expected[i].startCol, got[i].StartPos.Line, got[i].StartPos.Column)
+		}
+
+		if got[i].EndPos.RuneOffset-got[i].StartPos.RuneOffset != int64(len([]rune(got[i].Text))) {
+			return fmt.Errorf("on token %d (%q) expected end position after text, got (%v) to (%v)", i+1, got[i].Text, got[i].StartPos, got[i].EndPos)
+		}
+	}
+
+	return nil
+}
+
+func readAllTokens(lexer *nibblers.Lexer) ([]nibblers.Token, error) {
+	tokens := make([]nibblers.Token, 0, 10)
+
+	for {
+		token, err := lexer.NextToken()
+		if err != nil {
+			if err == io.EOF {
+				return tokens, nil
+			}
+
+			return tokens, err
+		}
+
+		tokens = append(tokens, token)
+	}
+}
+
+// readQuotedString is a custom rule that reads a double-quoted string with backslash escapes.
+func readQuotedString(nibbler nibblers.UTF8Nibbler) (bool, error) {
+	if r, err := nibbler.ReadCharacter(); err != nil || r != '"' {
+		return false, err
+	}
+
+	for {
+		r, err := nibbler.ReadCharacter()
+		if err != nil {
+			return false, err
+		}
+
+		switch r {
+		case '"':
+			return true, nil
+		case '\\':
+			if _, err := nibbler.ReadCharacter(); err != nil {
+				return false, err
+			}
+		}
+	}
+}
+
+func TestLexer(t *testing.T) {
+	s := "let x ≔ 3.25e1 -- note\n  x->y <= \"a \\\"b\\\"\" ¤ if\n\"open"
+
+	rules := []nibblers.LexerRule{
+		nibblers.NewMatchingRule("space", unicode.IsSpace).Skipped(),
+		nibblers.NewRegexpRule("comment", regexp.MustCompile(`--[^\n]*`)).Skipped(),
+		nibblers.NewLiteralRule("let", "let"),
+		nibblers.NewLiteralRule("if", "if"),
+		nibblers.NewMatchingRule("identifier", unicode.IsLetter),
+		nibblers.NewRegexpRule("number", regexp.MustCompile(`[0-9]+(\.[0-9]+)?([eE][+-]?[0-9]+)?`)),
+		nibblers.NewLiteralRule("minus", "-"),
+		nibblers.NewLiteralRule("arrow", "->"),
+		nibblers.NewLiteralRule("less", "<"),
+		nibblers.NewLiteralRule("lessOrEqual", "<="),
+		nibblers.NewLiteralRule("define", "≔"),
+		nibblers.NewCustomRule("string", readQuotedString),
+	}
+
+	expectedTokens := []expectedToken{
+		{kind: "let", text: "let", startLine: 1, startCol: 1},
+		{kind: "identifier", text: "x", startLine: 1, startCol: 5},
+		{kind: "define", text: "≔", startLine: 1, startCol: 7},
+		{kind: "number", text: "3.25e1", startLine: 1, startCol: 9},
+		{kind: "identifier", text: "x", startLine: 2, startCol: 3},
+		{kind: "arrow", text: "->", startLine: 2, startCol: 4},
+		{kind: "identifier", text: "y", startLine: 2, startCol: 6},
+		{kind: "lessOrEqual", text: "<=", startLine: 2, startCol: 8},
+		{kind: "string", text: "\"a \\\"b\\\"\"", startLine: 2, startCol: 11},
+		{kind: nibblers.UnknownCharacterToken, text: "¤", startLine: 2, startCol: 21},
+		{kind: "if", text: "if", startLine: 2, startCol: 23},
+		{kind: nibblers.UnknownCharacterToken, text: "\"", startLine: 3, startCol: 1},
+		{kind: "identifier", text: "open", startLine: 3, startCol: 2},
+	}
+
+	for _, typeOfNibbler := range []string{"String", "Reader"} {
+		var nibbler nibblers.UTF8Nibbler = nibblers.NewUTF8StringNibbler(s)
+		if typeOfNibbler == "Reader" {
+			reader := mock.NewReader()
+			for i := 0; i < len(s); i += 4 {
+				end := i + 4
+				if end > len(s) {
+					end = len(s)
+				}
+				reader.AddGoodRead([]byte(s[i:end]))
+			}
+			readerNibbler := nibblers.NewUTF8ReaderNibbler(reader.AddEOF())
+			readerNibbler.SetMaximumUnreadDepth(4)
+			nibbler = readerNibbler
+		}
+
+		tokens, err := readAllTokens(nibblers.NewLexer(nibbler, rules...))
+		if err != nil {
+			t.Errorf("(TestLexer) (%s) expected no error, got (%s)", typeOfNibbler, err.Error())
+		}
+
+		if err := compareTokens(expectedTokens, tokens); err != nil {
+			t.Errorf("(TestLexer) (%s) %s", typeOfNibbler, err.Error())
+		}
+	}
+}
+
+func TestLexerReturnsNibblerErrors(t *testing.T) {
+	nibbler := nibblers.NewUTF8StringNibbler("ab c\xffd")
+	lexer := nibblers.NewLexer(nibbler).AddRule(nibblers.NewMatchingRule("space", unicode.IsSpace).Skipped()).AddRule(nibblers.NewMatchingRule("letters", unicode.IsLetter))
+
+	if token, err := lexer.NextToken(); err != nil || token.Text != "ab" {
+		t.Errorf("(TestLexerReturnsNibblerErrors) expected token (ab), got (%q) and (%v)", token.Text, err)
+	}
+
+	for attempt := 1; attempt <= 2; attempt++ {
+		if _, err := lexer.NextToken(); !errors.Is(err, nibblers.ErrInvalidUTF8) {
+			t.Errorf("(TestLexerReturnsNibblerErrors) (attempt %d) expected ErrInvalidUTF8, got (%v)", attempt, err)
+		}
+
+		if position := nibbler.Position(); position.ByteOffset != 3 {
+			t.Errorf("(TestLexerReturnsNibblerErrors) (attempt %d) expected cursor at start of token (3), got (%d)", attempt, position.ByteOffset)
+		}
+	}
+}
+
+func TestLexerModes(t *testing.T) {
+	s := `x = "a ${b + "c${d}"} e" $`
+
+	newInterpolationLexer := func(nibbler nibblers.UTF8Nibbler) *nibblers.Lexer {
+		return nibblers.NewLexer(nibbler,
+			nibblers.NewMatchingRule("space", unicode.IsSpace).Skipped(),
+			nibblers.NewMatchingRule("identifier", unicode.IsLetter),
+			nibblers.NewLiteralRule("assign", "="),
+			nibblers.NewLiteralRule("openQuote", `"`).PushingMode("string")).
+			AddModeRule("string", nibblers.NewRegexpRule("text", regexp.MustCompile(`(?:[^"$]|\$[^{"])+`))).
+			AddModeRule("string", nibblers.NewLiteralRule("openInterpolation", "${").PushingMode("interpolation")).
+			AddModeRule("string", nibblers.NewLiteralRule("closeQuote", `"`).PoppingMode()).
+			AddModeRule("interpolation", nibblers.NewMatchingRule("space", unicode.IsSpace).Skipped()).
+			AddModeRule("interpolation", nibblers.NewMatchingRule("identifier", unicode.IsLetter)).
+			AddModeRule("interpolation", nibblers.NewLiteralRule("plus", "+")).
+			AddModeRule("interpolation", nibblers.NewLiteralRule("openQuote", `"`).PushingMode("string")).
+			AddModeRule("interpolation", nibblers.NewLiteralRule("closeInterpolation", "}").PoppingMode())
+	}
+
+	expectedTokens := []expectedToken{
+		{kind: "identifier", text: "x", startLine: 1, startCol: 1},
+		{kind: "assign", text: "=", startLine: 1, startCol: 3},
+		{kind: "openQuote", text: `"`, startLine: 1, startCol: 5},
+		{kind: "text", text: "a ", startLine: 1, startCol: 6},
+		{kind: "openInterpolation", text: "${", startLine: 1, startCol: 8},
+		{kind: "identifier", text: "b", startLine: 1, startCol: 10},
+		{kind: "plus", text: "+", startLine: 1, startCol: 12},
+		{kind: "openQuote", text: `"`, startLine: 1, startCol: 14},
+		{kind: "text", text: "c", startLine: 1, startCol: 15},
+		{kind: "openInterpolation", text: "${", startLine: 1, startCol: 16},
+		{kind: "identifier", text: "d", startLine: 1, startCol: 18},
+		{kind: "closeInterpolation", text: "}", startLine: 1, startCol: 19},
+		{kind: "closeQuote", text: `"`, startLine: 1, startCol: 20},
+		{kind: "closeInterpolation", text: "}", startLine: 1, startCol: 21},
+		{kind: "text", text: " e", startLine: 1, startCol: 22},
+		{kind: "closeQuote", text: `"`, startLine: 1, startCol: 24},
+		{kind: nibblers.UnknownCharacterToken, text: "$", startLine: 1, startCol: 26},
+	}
+
+	expectedModesAfterTokens := []string{"default", "default", "string", "string", "interpolation", "interpolation", "interpolation", "string", "string", "interpolation", "interpolation", "string", "interpolation", "string", "string", "default", "default"}
+
+	for _, typeOfNibbler := range []string{"String", "Reader"} {
+		var nibbler nibblers.UTF8Nibbler = nibblers.NewUTF8StringNibbler(s)
+		if typeOfNibbler == "Reader" {
+			reader := mock.NewReader()
+			for i := 0; i < len(s); i += 3 {
+				end := i + 3
+				if end > len(s) {
+					end = len(s)
+				}
+				reader.AddGoodRead([]byte(s[i:end]))
+			}
+			readerNibbler := nibblers.NewUTF8ReaderNibbler(reader.AddEOF())
+			readerNibbler.SetMaximumUnreadDepth(4)
+			nibbler = readerNibbler
+		}
+
+		lexer := newInterpolationLexer(nibbler)
+		tokens := make([]nibblers.Token, 0, len(expectedTokens))
+		var stringLiteral []rune
+
+		for tokenIndex := 0; ; tokenIndex++ {
+			token, err := lexer.NextToken()
+			if err != nil {
+				if err != io.EOF {
+					t.Errorf("(TestLexerModes) (%s) expected no error, got (%s)", typeOfNibbler, err.Error())
+				}
+				break
+			}
+
+			tokens = append(tokens, token)
+
+			if tokenIndex < len(expectedModesAfterTokens) && lexer.Mode() != expectedModesAfterTokens[tokenIndex] {
+				t.Errorf("(TestLexerModes) (%s) after token %d (%q) expected mode (%s), got (%s)", typeOfNibbler, tokenIndex+1, token.Text, expectedModesAfterTokens[tokenIndex], lexer.Mode())
+			}
+
+			// a bookend started in one mode and stopped in another spans the whole string literal
+			switch {
+			case token.Kind == "openQuote" && lexer.Mode() == "string" && stringLiteral == nil:
+				nibbler.StartBookending()
+			case token.Kind == "closeQuote" && lexer.Mode() == "default":
+				stringLiteral = nibbler.StopBookending()
+			}
+		}
+
+		if err := compareTokens(expectedTokens, tokens); err != nil {
+			t.Errorf("(TestLexerModes) (%s) %s", typeOfNibbler, err.Error())
+		}
+
+		if string(stringLiteral) != `a ${b + "c${d}"} e"` {
+			t.Errorf("(TestLexerModes) (%s) expected bookended string literal (a ${b + \"c${d}\"} e\"), got (%s)", typeOfNibbler, string(stringLiteral))
+		}
+	}
+}
+
+func TestLexerModeErrors(t *testing.T) {
+	nibbler := nibblers.NewUTF8StringNibbler("(])")
+	lexer := nibblers.NewLexer(nibbler,
+		nibblers.NewLiteralRule("open", "(").PushingMode("parenthesized"),
+		nibblers.NewLiteralRule("close", ")").PoppingMode()).
+		AddModeRule("parenthesized", nibblers.NewLiteralRule("close", ")").PoppingMode()).
+		AddModeRule("parenthesized", nibblers.NewLiteralRule("closeBracket", "]").PoppingMode())
+
+	for stepIndex, step := range []struct {
+		expectedKind       nibblers.TokenKind
+		expectedError      error
+		expectedByteOffset int64
+		expectedMode       string
+	}{
+		{expectedKind: "open", expectedByteOffset: 1, expectedMode: "parenthesized"},
+		{expectedKind: "closeBracket", expectedByteOffset: 2, expectedMode: nibblers.DefaultLexerMode},
+		{expectedError: nibblers.ErrNoLexerModeToPop, expectedByteOffset: 2, expectedMode: nibblers.DefaultLexerMode},
+	} {
+		token, err := lexer.NextToken()
+		if err != step.expectedError {
+			t.Errorf("(TestLexerModeErrors) (step %d) expected error (%v), got (%v)", stepIndex+1, step.expectedError, err)
+		}
+
+		if token.Kind != step.expectedKind {
+			t.Errorf("(TestLexerModeErrors) (step %d) expected token kind (%s), got (%s)", stepIndex+1, step.expectedKind, token.Kind)
+		}
+
+		if offset := nibbler.Position().ByteOffset; offset != step.expectedByteOffset {
+			t.Errorf("(TestLexerModeErrors) (step %d) expected cursor at (%d), got (%d)", stepIndex+1, step.expectedByteOffset, offset)
+		}
+
+		if lexer.Mode() != step.expectedMode {
+			t.Errorf("(TestLexerModeErrors) (step %d) expected mode (%s), got (%s)", stepIndex+1, step.expectedMode, lexer.Mode())
+		}
+	}
+
+	if err := lexer.PushMode("bracketed"); err != nibblers.ErrUnknownLexerMode {
+		t.Errorf("(TestLexerModeErrors) on PushMode of mode without rules expected ErrUnknownLexerMode, got (%v)", err)
+	}
+}
@@ -0,0 +1,81 @@
+package nibblers
+
+import "errors"
+
+// ErrInvalidMark is returned by ResetTo when the provided Mark was not created by the nibbler or
+// has already been released.
+var ErrInvalidMark = errors.New("mark is not live for this nibbler")
+
+// Mark is a savepoint in a nibbler stream, returned by a nibbler's Mark() method.  The nibbler cursor
+// can be returned to a Mark with ResetTo() any number of times until the Mark is released with Release().
+// A Mark is only meaningful to the nibbler that created it.
+type Mark struct {
+	id         uint64
+	byteOffset int64
+	runeOffset int64
+}
+
+// markRegistry tracks the live Marks for a nibbler.  Marks are kept in the order of creation, so
+// releasing a mark also releases every mark created after it.
+type markRegistry struct {
+	idOfNextMark uint64
+	liveMarks    []Mark
+}
+
+func newMarkRegistry() *markRegistry {
+	return &markRegistry{
+		idOfNextMark: 1,
+		liveMarks:    make([]Mark, 0, 4),
+	}
+}
+
+func (registry *markRegistry) add(byteOffset int64, runeOffset int64) Mark {
+	mark := Mark{
+		id:         registry.idOfNextMark,
+		byteOffset: byteOffset,
+		runeOffset: runeOffset,
+	}
+
+	registry.idOfNextMark++
+	registry.liveMarks = append(registry.liveMarks, mark)
+
+	return mark
+}
+
+func (registry *markRegistry) indexOf(mark Mark) int {
+	for i := len(registry.liveMarks) - 1; i >= 0; i-- {
+		if registry.liveMarks[i] == mark {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func (registry *markRegistry) isLive(mark Mark) bool {
+	return registry.indexOf(mark) >= 0
+}
+
+// release removes the mark and all marks created after it.  It does nothing if the mark is not live.
+func (registry *markRegistry) release(mark Mark) {
+	if i := registry.indexOf(mark); i >= 0 {
+		registry.liveMarks = registry.liveMarks[:i]
+	}
+}
+
+// oldestByteOffset returns the smallest byte offset of any live mark.  The boolean is false if there
+// are no live marks.
+func (registry *markRegistry) oldestByteOffset() (int64, bool) {
+	if len(registry.liveMarks) == 0 {
+		return 0, false
+	}
+
+	oldest := registry.liveMarks[0].byteOffset
+	for _, mark := range registry.liveMarks[1:] {
+		if mark.byteOffset < oldest {
+			oldest = mark.byteOffset
+		}
+	}
+
+	return oldest, true
+}
@@ -0,0 +1,353 @@
+package nibblers
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Names under which the built-in byte sets are registered in every NamedByteSetsMap.  Each set contains
+// only ASCII bytes.  A built-in set can be replaced by adding a set with the same name.
+const (
+	ByteSetDigit     = "digit"     // 0-9
+	ByteSetHexDigit  = "hexdigit"  // 0-9, a-f and A-F
+	ByteSetAlpha     = "alpha"     // a-z and A-Z
+	ByteSetAlnum     = "alnum"     // 0-9, a-z and A-Z
+	ByteSetSpace     = "space"     // space, \t, \n, \v, \f and \r
+	ByteSetPrintable = "printable" // 0x20 through 0x7e
+	ByteSetControl   = "control"   // 0x00 through 0x1f, and 0x7f
+)
+
+// byteSet is a set of byte values, stored as a 256-bit bitmap.
+type byteSet [4]uint64
+
+func (set *byteSet) add(b byte) {
+	set[b>>6] |= 1 << (b & 63)
+}
+
+func (set *byteSet) contains(b byte) bool {
+	return set[b>>6]&(1<<(b&63)) != 0
+}
+
+// NamedByteSetsMap stores sets of bytes, associated with a name.  These can
+// be provided to ByteNibblers when reading a string of characters from the input stream to
+// determine which characters are allowed as part of the read.
+type NamedByteSetsMap struct {
+	mapOfSetsByName map[string]*byteSet
+}
+
+// NewNamedByteSetsMap creates a new map containing only the built-in sets (ByteSetDigit, ByteSetHexDigit and so forth).
+func NewNamedByteSetsMap() *NamedByteSetsMap {
+	setsMap := &NamedByteSetsMap{
+		mapOfSetsByName: make(map[string]*byteSet),
+	}
+
+	setsMap.mapOfSetsByName[ByteSetDigit] = byteSetFromRange('0', '9')
+	setsMap.mapOfSetsByName[ByteSetHexDigit] = unionOfByteSets(byteSetFromRange('0', '9'), byteSetFromRange('a', 'f'), byteSetFromRange('A', 'F'))
+	setsMap.mapOfSetsByName[ByteSetAlpha] = unionOfByteSets(byteSetFromRange('a', 'z'), byteSetFromRange('A', 'Z'))
+	setsMap.mapOfSetsByName[ByteSetAlnum] = unionOfByteSets(byteSetFromRange('0', '9'), byteSetFromRange('a', 'z'), byteSetFromRange('A', 'Z'))
+	setsMap.mapOfSetsByName[ByteSetSpace] = byteSetFromByteArray([]byte(" \t\n\v\f\r"))
+	setsMap.mapOfSetsByName[ByteSetPrintable] = byteSetFromRange(0x20, 0x7e)
+	setsMap.mapOfSetsByName[ByteSetControl] = unionOfByteSets(byteSetFromRange(0x00, 0x1f), byteSetFromByteArray([]byte{0x7f}))
+
+	return setsMap
+}
+
+// AddNamedByteSetFromString treats stringOfBytes as a series of bytes.  The set is added to the SetsMap with the
+// provided name.
+func (setsMap *NamedByteSetsMap) AddNamedByteSetFromString(nameOfSet string, stringOfBytes string) *NamedByteSetsMap {
+	return setsMap.AddNamedByteSetFromByteArray(nameOfSet, []byte(stringOfBytes))
+}
+
+// AddNamedByteSetFromByteArray adds the bytes in byteArray as a byte set with the provided name.
+func (setsMap *NamedByteSetsMap) AddNamedByteSetFromByteArray(nameOfSet string, byteArray []byte) *NamedByteSetsMap {
+	setsMap.mapOfSetsByName[nameOfSet] = byteSetFromByteArray(byteArray)
+	return setsMap
+}
+
+// AddNamedByteSetFromRange adds the bytes from first through last (inclusive) as a byte set with the provided
+// name.  If last is less than first, the set is empty.
+func (setsMap *NamedByteSetsMap) AddNamedByteSetFromRange(nameOfSet string, first byte, last byte) *NamedByteSetsMap {
+	setsMap.mapOfSetsByName[nameOfSet] = byteSetFromRange(first, last)
+	return setsMap
+}
+
+// AddNamedByteSetFromUnion adds a byte set with the provided name that contains every byte in any of the
+// named sets.  Returns an error if any of the named sets is not in the map.
+func (setsMap *NamedByteSetsMap) AddNamedByteSetFromUnion(nameOfSet string, namesOfSetsToCombine ...string) error {
+	setsToCombine, err := setsMap.retrieveNamedCharacterSets(namesOfSetsToCombine)
+	if err != nil {
+		return err
+	}
+
+	setsMap.mapOfSetsByName[nameOfSet] = unionOfByteSets(setsToCombine...)
+	return nil
+}
+
+// AddNamedByteSetFromIntersection adds a byte set with the provided name that contains only the bytes that
+// are in every one of the named sets.  Returns an error if any of the named sets is not in the map.
+func (setsMap *NamedByteSetsMap) AddNamedByteSetFromIntersection(nameOfSet string, namesOfSetsToIntersect ...string) error {
+	setsToIntersect, err := setsMap.retrieveNamedCharacterSets(namesOfSetsToIntersect)
+	if err != nil {
+		return err
+	}
+
+	intersection := &byteSet{}
+	if len(setsToIntersect) > 0 {
+		*intersection = *setsToIntersect[0]
+		for _, set := range setsToIntersect[1:] {
+			for i := range intersection {
+				intersection[i] &= set[i]
+			}
+		}
+	}
+
+	setsMap.mapOfSetsByName[nameOfSet] = intersection
+	return nil
+}
+
+// AddNamedByteSetFromDifference adds a byte set with the provided name that contains the bytes in the set named
+// nameOfSetToSubtractFrom that are not in any of the sets named in namesOfSetsToSubtract.  Returns an error if any
+// of the named sets is not in the map.
+func (setsMap *NamedByteSetsMap) AddNamedByteSetFromDifference(nameOfSet string, nameOfSetToSubtractFrom string, namesOfSetsToSubtract ...string) error {
+	setToSubtractFrom, err := setsMap.retrieveNamedCharacterSets([]string{nameOfSetToSubtractFrom})
+	if err != nil {
+		return err
+	}
+
+	setsToSubtract, err := setsMap.retrieveNamedCharacterSets(namesOfSetsToSubtract)
+	if err != nil {
+		return err
+	}
+
+	difference := &byteSet{}
+	*difference = *setToSubtractFrom[0]
+	for _, set := range setsToSubtract {
+		for i := range difference {
+			difference[i] &^= set[i]
+		}
+	}
+
+	setsMap.mapOfSetsByName[nameOfSet] = difference
+	return nil
+}
+
+// AddNamedByteSetFromComplement adds a byte set with the provided name that contains every byte value that is
+// not in the set named nameOfSetToComplement.  Returns an error if that set is not in the map.
+func (setsMap *NamedByteSetsMap) AddNamedByteSetFromComplement(nameOfSet string, nameOfSetToComplement string) error {
+	setToComplement, err := setsMap.retrieveNamedCharacterSets([]string{nameOfSetToComplement})
+	if err != nil {
+		return err
+	}
+
+	setsMap.mapOfSetsByName[nameOfSet] = complementOfByteSet(setToComplement[0])
+	return nil
+}
+
+// AddNamedByteSetFromBracketExpression adds a byte set with the provided name from a bracket expression, in
+// the style of a regular expression character class.  The expression must start with '[' and end with ']'.
+// Between the brackets, it may contain:
+//   - single bytes (e.g., "[abc]");
+//   - ranges of bytes (e.g., "[a-z0-9]");
+//   - a '^' immediately after the opening bracket, which complements the set (e.g., "[^0-9]");
+//   - a ']' immediately after the opening bracket (or the '^'), which is treated as a literal ']';
+//   - a '-' at the start or end, which is treated as a literal '-' (e.g., "[A-Za-z0-9_-]");
+//   - a backslash escape, either \n, \r, \t, \f, \v, \xHH (a hexadecimal byte value) or a backslash
+//     followed by any other byte, which is treated as that literal byte (e.g., "[\]\\]");
+//   - a reference to another set in the map, as [:name:] (e.g., "[[:hexdigit:]_]").
+//
+// Returns an error if the expression is malformed or references a set that is not in the map.
+func (setsMap *NamedByteSetsMap) AddNamedByteSetFromBracketExpression(nameOfSet string, expression string) error {
+	set, err := setsMap.parseBracketExpression(expression)
+	if err != nil {
+		return err
+	}
+
+	setsMap.mapOfSetsByName[nameOfSet] = set
+	return nil
+}
+
+func (setsMap *NamedByteSetsMap) retrieveNamedCharacterSet(nameOfSet string) *byteSet {
+	return setsMap.mapOfSetsByName[nameOfSet]
+}
+
+func (setsMap *NamedByteSetsMap) retrieveNamedCharacterSets(namesOfSets []string) ([]*byteSet, error) {
+	sets := make([]*byteSet, len(namesOfSets))
+
+	for i, nameOfSet := range namesOfSets {
+		if sets[i] = setsMap.retrieveNamedCharacterSet(nameOfSet); sets[i] == nil {
+			return nil, fmt.Errorf("%w (%s)", ErrUnknownSet, nameOfSet)
+		}
+	}
+
+	return sets, nil
+}
+
+func (setsMap *NamedByteSetsMap) parseBracketExpression(expression string) (*byteSet, error) {
+	if len(expression) < 2 || expression[0] != '[' || expression[len(expression)-1] != ']' {
+		return nil, fmt.Errorf("bracket expression must start with '[' and end with ']'")
+	}
+
+	set := &byteSet{}
+	indexOfLastByte := len(expression) - 1
+	i := 1
+
+	expressionIsNegated := false
+	if i < indexOfLastByte && expression[i] == '^' {
+		expressionIsNegated = true
+		i++
+	}
+
+	if i == indexOfLastByte {
+		return nil, fmt.Errorf("bracket expression is empty")
+	}
+
+	indexOfFirstMember := i
+	for i < indexOfLastByte {
+		if expression[i] == '[' && i+1 < indexOfLastByte && expression[i+1] == ':' {
+			lengthOfName := indexOfClassTerminator(expression[i+2 : indexOfLastByte])
+			if lengthOfName < 0 {
+				return nil, fmt.Errorf("unterminated [: at offset %d in bracket expression", i)
+			}
+
+			nameOfReferencedSet := expression[i+2 : i+2+lengthOfName]
+			referencedSet := setsMap.retrieveNamedCharacterSet(nameOfReferencedSet)
+			if referencedSet == nil {
+				return nil, fmt.Errorf("%w (%s)", ErrUnknownSet, nameOfReferencedSet)
+			}
+
+			*set = *unionOfByteSets(set, referencedSet)
+
+			i += 2 + lengthOfName + 2
+			continue
+		}
+
+		first, lengthOfFirst, err := decodeBracketExpressionByte(expression, i, i == indexOfFirstMember)
+		if err != nil {
+			return nil, err
+		}
+		i += lengthOfFirst
+
+		if i+1 < indexOfLastByte && expression[i] == '-' {
+			last, lengthOfLast, err := decodeBracketExpressionByte(expression, i+1, false)
+			if err != nil {
+				return nil, err
+			}
+
+			if last < first {
+				return nil, fmt.Errorf("range end before range start at offset %d in bracket expression", i)
+			}
+
+			*set = *unionOfByteSets(set, byteSetFromRange(first, last))
+
+			i += 1 + lengthOfLast
+			continue
+		}
+
+		set.add(first)
+	}
+
+	if expressionIsNegated {
+		return complementOfByteSet(set), nil
+	}
+
+	return set, nil
+}
+
+// decodeBracketExpressionByte returns the byte starting at expression[i] and the number of bytes in the
+// expression that encode it.  An unescaped ']' is only permitted if it is the first member in the expression.
+func decodeBracketExpressionByte(expression string, i int, isFirstMember bool) (byte, int, error) {
+	indexOfLastByte := len(expression) - 1
+
+	switch expression[i] {
+	case ']':
+		if !isFirstMember {
+			return 0, 0, fmt.Errorf("unescaped ']' at offset %d in bracket expression", i)
+		}
+		return ']', 1, nil
+
+	case '\\':
+		if i+1 >= indexOfLastByte {
+			return 0, 0, fmt.Errorf("incomplete escape at offset %d in bracket expression", i)
+		}
+
+		switch expression[i+1] {
+		case 'n':
+			return '\n', 2, nil
+		case 'r':
+			return '\r', 2, nil
+		case 't':
+			return '\t', 2, nil
+		case 'f':
+			return '\f', 2, nil
+		case 'v':
+			return '\v', 2, nil
+		case 'x':
+			if i+3 >= indexOfLastByte {
+				return 0, 0, fmt.Errorf("incomplete \\x escape at offset %d in bracket expression", i)
+			}
+
+			value, err := strconv.ParseUint(expression[i+2:i+4], 16, 8)
+			if err != nil {
+				return 0, 0, fmt.Errorf("invalid \\x escape at offset %d in bracket expression", i)
+			}
+
+			return byte(value), 4, nil
+		default:
+			return expression[i+1], 2, nil
+		}
+	}
+
+	return expression[i], 1, nil
+}
+
+func indexOfClassTerminator(s string) int {
+	for i := 0; i+1 < len(s); i++ {
+		if s[i] == ':' && s[i+1] == ']' {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func byteSetFromByteArray(byteArray []byte) *byteSet {
+	set := &byteSet{}
+
+	for _, nextByte := range byteArray {
+		set.add(nextByte)
+	}
+
+	return set
+}
+
+func byteSetFromRange(first byte, last byte) *byteSet {
+	set := &byteSet{}
+
+	for b := int(first); b <= int(last); b++ {
+		set.add(byte(b))
+	}
+
+	return set
+}
+
+func unionOfByteSets(sets ...*byteSet) *byteSet {
+	union := &byteSet{}
+
+	for _, set := range sets {
+		for i := range union {
+			union[i] |= set[i]
+		}
+	}
+
+	return union
+}
+
+func complementOfByteSet(set *byteSet) *byteSet {
+	complement := &byteSet{}
+
+	for i := range complement {
+		complement[i] = ^set[i]
+	}
+
+	return complement
+}
@@ -0,0 +1,241 @@
+package nibblers_test
+
+import (
+	"fmt"
+	"testing"
+
+	nibblers "github.com/blorticus-go/nibblers"
+)
+
+type namedByteSetTestCase struct {
+	testname            string
+	buildSet            func(setsMap *nibblers.NamedByteSetsMap) error
+	expectError         bool
+	expectedMemberBytes string
+}
+
+// setMembersFromMap returns, in byte order, every byte value that is in the named set, using a ByteSliceNibbler
+// to test membership.
+func setMembersFromMap(setsMap *nibblers.NamedByteSetsMap, nameOfSet string) (string, error) {
+	allBytes := make([]byte, 256)
+	for i := range allBytes {
+		allBytes[i] = byte(i)
+	}
+
+	nibbler := nibblers.NewByteSliceNibbler(allBytes)
+	nibbler.AddNamedByteSetsMap(setsMap)
+
+	members := make([]byte, 0, 256)
+	for {
+		matchingBytes, err := nibbler.ReadNextBytesMatchingSet(nameOfSet)
+		members = append(members, matchingBytes...)
+		if err != nil {
+			return string(members), nil
+		}
+
+		if _, err := nibbler.ReadNextBytesNotMatchingSet(nameOfSet); err != nil {
+			return string(members), nil
+		}
+	}
+}
+
+func (testCase *namedByteSetTestCase) runTestCase() error {
+	setsMap := nibblers.NewNamedByteSetsMap().
+		AddNamedByteSetFromString("vowels", "aeiou").
+		AddNamedByteSetFromRange("a-f", 'a', 'f')
+
+	err := testCase.buildSet(setsMap)
+	if testCase.expectError {
+		if err == nil {
+			return fmt.Errorf("expected error, got no error")
+		}
+		return nil
+	}
+
+	if err != nil {
+		return fmt.Errorf("expected no error, got error = (%s)", err.Error())
+	}
+
+	members, err := setMembersFromMap(setsMap, "result")
+	if err != nil {
+		return err
+	}
+
+	if members != testCase.expectedMemberBytes {
+		return fmt.Errorf("expected set members (%q), got (%q)", testCase.expectedMemberBytes, members)
+	}
+
+	return nil
+}
+
+func TestNamedByteSetsMap(t *testing.T) {
+	var allBytesExceptDigits string
+	for b := 0; b < 256; b++ {
+		if b < '0' || b > '9' {
+			allBytesExceptDigits += string([]byte{byte(b)})
+		}
+	}
+
+	for _, testCase := range []*namedByteSetTestCase{
+		{
+			testname:            "range",
+			buildSet:            func(m *nibblers.NamedByteSetsMap) error { m.AddNamedByteSetFromRange("result", 'w', 'z'); return nil },
+			expectedMemberBytes: "wxyz",
+		},
+		{
+			testname:            "reversed range",
+			buildSet:            func(m *nibblers.NamedByteSetsMap) error { m.AddNamedByteSetFromRange("result", 'z', 'w'); return nil },
+			expectedMemberBytes: "",
+		},
+		{
+			testname: "union",
+			buildSet: func(m *nibblers.NamedByteSetsMap) error {
+				return m.AddNamedByteSetFromUnion("result", "vowels", "a-f", "digit")
+			},
+			expectedMemberBytes: "0123456789abcdefiou",
+		},
+		{
+			testname: "intersection",
+			buildSet: func(m *nibblers.NamedByteSetsMap) error {
+				return m.AddNamedByteSetFromIntersection("result", "vowels", "a-f")
+			},
+			expectedMemberBytes: "ae",
+		},
+		{
+			testname: "difference",
+			buildSet: func(m *nibblers.NamedByteSetsMap) error {
+				return m.AddNamedByteSetFromDifference("result", "hexdigit", "vowels", "digit")
+			},
+			expectedMemberBytes: "ABCDEFbcdf",
+		},
+		{
+			testname:            "complement",
+			buildSet:            func(m *nibblers.NamedByteSetsMap) error { return m.AddNamedByteSetFromComplement("result", "digit") },
+			expectedMemberBytes: allBytesExceptDigits,
+		},
+		{
+			testname: "union with unknown set",
+			buildSet: func(m *nibblers.NamedByteSetsMap) error {
+				return m.AddNamedByteSetFromUnion("result", "vowels", "nope")
+			},
+			expectError: true,
+		},
+		{
+			testname: "difference from unknown set",
+			buildSet: func(m *nibblers.NamedByteSetsMap) error {
+				return m.AddNamedByteSetFromDifference("result", "nope", "vowels")
+			},
+			expectError: true,
+		},
+		{
+			testname: "builtin alnum",
+			buildSet: func(m *nibblers.NamedByteSetsMap) error {
+				return m.AddNamedByteSetFromUnion("result", nibblers.ByteSetAlnum)
+			},
+			expectedMemberBytes: "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz",
+		},
+		{
+			testname: "builtin space",
+			buildSet: func(m *nibblers.NamedByteSetsMap) error {
+				return m.AddNamedByteSetFromUnion("result", nibblers.ByteSetSpace)
+			},
+			expectedMemberBytes: "\t\n\v\f\r ",
+		},
+		{
+			testname: "builtin printable and control are complementary in ASCII",
+			buildSet: func(m *nibblers.NamedByteSetsMap) error {
+				m.AddNamedByteSetFromRange("ascii", 0, 0x7f)
+				return m.AddNamedByteSetFromDifference("result", "ascii", nibblers.ByteSetPrintable, nibblers.ByteSetControl)
+			},
+			expectedMemberBytes: "",
+		},
+		{
+			testname: "bracket expression with ranges and trailing dash",
+			buildSet: func(m *nibblers.NamedByteSetsMap) error {
+				return m.AddNamedByteSetFromBracketExpression("result", "[A-Ca-c0-2_-]")
+			},
+			expectedMemberBytes: "-012ABC_abc",
+		},
+		{
+			testname: "bracket expression with leading bracket and dash",
+			buildSet: func(m *nibblers.NamedByteSetsMap) error {
+				return m.AddNamedByteSetFromBracketExpression("result", "[]a-]")
+			},
+			expectedMemberBytes: "-]a",
+		},
+		{
+			testname: "bracket expression with escapes",
+			buildSet: func(m *nibblers.NamedByteSetsMap) error {
+				return m.AddNamedByteSetFromBracketExpression("result", `[\t\x41\]\\\-]`)
+			},
+			expectedMemberBytes: "\t-A\\]",
+		},
+		{
+			testname: "bracket expression with set reference",
+			buildSet: func(m *nibblers.NamedByteSetsMap) error {
+				return m.AddNamedByteSetFromBracketExpression("result", "[[:vowels:]x-z]")
+			},
+			expectedMemberBytes: "aeiouxyz",
+		},
+		{
+			testname: "negated bracket expression",
+			buildSet: func(m *nibblers.NamedByteSetsMap) error {
+				return m.AddNamedByteSetFromBracketExpression("result", "[^[:digit:]]")
+			},
+			expectedMemberBytes: allBytesExceptDigits,
+		},
+		{
+			testname: "bracket expression without brackets",
+			buildSet: func(m *nibblers.NamedByteSetsMap) error {
+				return m.AddNamedByteSetFromBracketExpression("result", "a-z")
+			},
+			expectError: true,
+		},
+		{
+			testname: "empty bracket expression",
+			buildSet: func(m *nibblers.NamedByteSetsMap) error {
+				return m.AddNamedByteSetFromBracketExpression("result", "[^]")
+			},
+			expectError: true,
+		},
+		{
+			testname: "bracket expression with reversed range",
+			buildSet: func(m *nibblers.NamedByteSetsMap) error {
+				return m.AddNamedByteSetFromBracketExpression("result", "[z-a]")
+			},
+			expectError: true,
+		},
+		{
+			testname: "bracket expression with unknown set reference",
+			buildSet: func(m *nibblers.NamedByteSetsMap) error {
+				return m.AddNamedByteSetFromBracketExpression("result", "[[:nope:]]")
+			},
+			expectError: true,
+		},
+		{
+			testname: "bracket expression with unterminated set reference",
+			buildSet: func(m *nibblers.NamedByteSetsMap) error {
+				return m.AddNamedByteSetFromBracketExpression("result", "[[:digit]")
+			},
+			expectError: true,
+		},
+		{
+			testname: "bracket expression with unescaped closing bracket",
+			buildSet: func(m *nibblers.NamedByteSetsMap) error {
+				return m.AddNamedByteSetFromBracketExpression("result", "[a]b]")
+			},
+			expectError: true,
+		},
+		{
+			testname: "bracket expression with bad hex escape",
+			buildSet: func(m *nibblers.NamedByteSetsMap) error {
+				return m.AddNamedByteSetFromBracketExpression("result", `[\xZZ]`)
+			},
+			expectError: true,
+		},
+	} {
+		if err := testCase.runTestCase(); err != nil {
+			t.Errorf("(TestNamedByteSetsMap) (%s) %s", testCase.testname, err.Error())
+		}
+	}
+}
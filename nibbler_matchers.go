@@ -9,7 +9,8 @@ import (
 // each character against a CharacterMatchingFunction. Contigiuous matching or non-matching characters (depending
 // on the method) are either placed in a buffer or discarded (depdending on the method).
 type UTF8NibblerMatcher struct {
-	nibbler UTF8Nibbler
+	nibbler         UTF8Nibbler
+	anchoredRegexps regexpCache
 }
 
 // CharacterMatchingFunction is a function that is used by *Matching and *MatchingInto methods. It accepts a rune
@@ -19,7 +20,8 @@ type CharacterMatchingFunction func(r rune) (runeMatches bool)
 // NewUTF8NibblerMatcher creates a new UTF8Matcher using the provided Nibbler as the Read source.
 func NewUTF8NibblerMatcher(nibbler UTF8Nibbler) *UTF8NibblerMatcher {
 	return &UTF8NibblerMatcher{
-		nibbler: nibbler,
+		nibbler:         nibbler,
+		anchoredRegexps: make(regexpCache),
 	}
 }
 
@@ -66,8 +68,11 @@ func (matcher *UTF8NibblerMatcher) ReadConsecutiveCharactersNotMatching(matchFun
 				if len(nonMatchingRunes) == 0 {
 					return nil, io.EOF
 				}
+
+				return nonMatchingRunes, nil
 			}
-			return nonMatchingRunes, nil
+
+			return nonMatchingRunes, err
 		}
 
 		if !matchFunction(nextRune) {
@@ -229,7 +234,369 @@ func (matcher *UTF8NibblerMatcher) DiscardConsecutiveWordCharacters() (int, erro
 	return matcher.DiscardConsecutiveCharactersNotMatching(runeIsWhitespace)
 }
 
+// ReadUntilSequence reads characters up to the delimiter sequence, which is handled as directed by handling.
+// If the delimiter does not start within maximumLength characters of the cursor (unless maximumLength is
+// UnlimitedSequenceLength), ErrSequenceLengthExceeded is returned and the cursor is not moved.  If the Nibbler
+// reaches EOF before the delimiter, the remaining characters are read and returned with io.EOF.  If any other
+// error occurs, it is returned and the cursor is not moved.
+func (matcher *UTF8NibblerMatcher) ReadUntilSequence(delimiter []rune, handling DelimiterHandling, maximumLength int) ([]rune, error) {
+	readCharacters := make([]rune, 0, 10)
+
+	_, err := matcher.readOrSkipUntilSequence("ReadUntilSequence", delimiter, handling, maximumLength, &readCharacters)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	return readCharacters, err
+}
+
+// SkipUntilSequence does the same thing as ReadUntilSequence, but discards the characters rather than returning
+// them.  It returns the number of characters by which the cursor moved.  If maximumLength is UnlimitedSequenceLength,
+// the cursor may have moved when an error other than io.EOF is returned.
+func (matcher *UTF8NibblerMatcher) SkipUntilSequence(delimiter []rune, handling DelimiterHandling, maximumLength int) (int, error) {
+	return matcher.readOrSkipUntilSequence("SkipUntilSequence", delimiter, handling, maximumLength, nil)
+}
+
+// readOrSkipUntilSequence advances the cursor to the delimiter, appending the characters that are passed to
+// receiver unless it is nil.  The cursor is marked so that it can be restored if the maximum length is
+// exceeded or an error occurs, except when skipping without a maximum length, so that the Nibbler need not
+// retain skipped characters.  The delimiter is sought with a peek, so the cursor never has to be unread
+// from past the delimiter.  Errors other than those of the Nibbler are wrapped in a NibbleError for the
+// operation op.
+func (matcher *UTF8NibblerMatcher) readOrSkipUntilSequence(op string, delimiter []rune, handling DelimiterHandling, maximumLength int, receiver *[]rune) (int, error) {
+	if len(delimiter) == 0 {
+		return 0, newNibbleError(op, matcher.nibbler.Position().ByteOffset, errEmptyDelimiter)
+	}
+
+	var mark Mark
+	cursorIsMarked := receiver != nil || maximumLength >= 0
+	if cursorIsMarked {
+		mark = matcher.nibbler.Mark()
+		defer matcher.nibbler.Release(mark)
+	}
+
+	restoreCursorAndReturn := func(err error) (int, error) {
+		if receiver != nil {
+			*receiver = (*receiver)[:0]
+		}
+
+		matcher.nibbler.ResetTo(mark)
+		return 0, err
+	}
+
+	countOfCharactersPassed := 0
+	stopOn := func(err error) (int, error) {
+		if cursorIsMarked {
+			return restoreCursorAndReturn(err)
+		}
+
+		return countOfCharactersPassed, err
+	}
+
+	for {
+		if maximumLength >= 0 && countOfCharactersPassed > maximumLength {
+			restoreCursorAndReturn(nil)
+			return 0, newNibbleError(op, matcher.nibbler.Position().ByteOffset, ErrSequenceLengthExceeded)
+		}
+
+		nextRune, err := matcher.nibbler.PeekAtNextCharacter()
+		if err != nil {
+			if err == io.EOF {
+				return countOfCharactersPassed, io.EOF
+			}
+
+			return stopOn(err)
+		}
+
+		if nextRune == delimiter[0] {
+			if nextRunes, err := matcher.nibbler.PeekAtNextCharacters(uint(len(delimiter))); err == nil && runeSlicesAreEqual(nextRunes, delimiter) {
+				break
+			}
+		}
+
+		// the read can fail where the peek did not, for example when it would exceed a bookend limit
+		if _, err := matcher.nibbler.ReadCharacter(); err != nil {
+			return stopOn(err)
+		}
+
+		countOfCharactersPassed++
+
+		if receiver != nil {
+			*receiver = append(*receiver, nextRune)
+		}
+	}
+
+	if handling == ExcludeDelimiter {
+		return countOfCharactersPassed, nil
+	}
+
+	for range delimiter {
+		if _, err := matcher.nibbler.ReadCharacter(); err != nil {
+			return stopOn(err)
+		}
+	}
+
+	if handling == IncludeDelimiter && receiver != nil {
+		*receiver = append(*receiver, delimiter...)
+	}
+
+	return countOfCharactersPassed + len(delimiter), nil
+}
+
+func runeSlicesAreEqual(a []rune, b []rune) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
 // UnderlyingNibbler returns the UTF8Nibbler used by the matcher.
 func (matcher *UTF8NibblerMatcher) UnderlyingNibbler() UTF8Nibbler {
 	return matcher.nibbler
 }
+
+// ByteNibblerMatcher is a wrapper around a ByteNibbler that performs successive byte reads, comparing each
+// byte against a ByteMatchingFunction.  Contiguous matching or non-matching bytes (depending on the method) are
+// either placed in a buffer or discarded (depending on the method).  Each byte is peeked at before it is read,
+// so the matcher never needs to unread a byte, and a ByteReaderNibbler with no unread depth can be used.
+type ByteNibblerMatcher struct {
+	nibbler         ByteNibbler
+	anchoredRegexps regexpCache
+}
+
+// ByteMatchingFunction is a function that is used by *Matching and *MatchingInto methods.  It accepts a byte
+// and performs some sort of match against it.  It returns true if the byte matches and false otherwise.
+type ByteMatchingFunction func(b byte) (byteMatches bool)
+
+// NewByteNibblerMatcher creates a new ByteNibblerMatcher using the provided Nibbler as the Read source.
+func NewByteNibblerMatcher(nibbler ByteNibbler) *ByteNibblerMatcher {
+	return &ByteNibblerMatcher{
+		nibbler:         nibbler,
+		anchoredRegexps: make(regexpCache),
+	}
+}
+
+// ReadConsecutiveBytesMatching reads bytes from the underlying Nibbler.  It returns a slice containing the
+// consecutive bytes from the current Read cursor for which the ByteMatchingFunction returns true.  Returns an
+// error if one occurs.  If the Nibbler returns EOF on a Read and there were any matching bytes, returns nil for
+// the error.  Otherwise, if the cursor was already at EOF, returns an empty slice and io.EOF.
+func (matcher *ByteNibblerMatcher) ReadConsecutiveBytesMatching(matchFunction ByteMatchingFunction) ([]byte, error) {
+	matchingBytes := make([]byte, 0, 20)
+
+	for {
+		nextByte, err := matcher.nibbler.PeekAtNextByte()
+		if err != nil {
+			if err == io.EOF {
+				if len(matchingBytes) == 0 {
+					return nil, io.EOF
+				}
+
+				return matchingBytes, nil
+			}
+
+			return matchingBytes, err
+		}
+
+		if matchFunction(nextByte) {
+			matcher.nibbler.ReadByte()
+			matchingBytes = append(matchingBytes, nextByte)
+		} else {
+			return matchingBytes, nil
+		}
+	}
+}
+
+// ReadConsecutiveBytesNotMatching does the same thing as ReadConsecutiveBytesMatching but returns consecutive
+// bytes for which the ByteMatchingFunction returns false.
+func (matcher *ByteNibblerMatcher) ReadConsecutiveBytesNotMatching(matchFunction ByteMatchingFunction) ([]byte, error) {
+	nonMatchingBytes := make([]byte, 0, 20)
+
+	for {
+		nextByte, err := matcher.nibbler.PeekAtNextByte()
+		if err != nil {
+			if err == io.EOF {
+				if len(nonMatchingBytes) == 0 {
+					return nil, io.EOF
+				}
+
+				return nonMatchingBytes, nil
+			}
+
+			return nonMatchingBytes, err
+		}
+
+		if !matchFunction(nextByte) {
+			matcher.nibbler.ReadByte()
+			nonMatchingBytes = append(nonMatchingBytes, nextByte)
+		} else {
+			return nonMatchingBytes, nil
+		}
+	}
+}
+
+// ReadConsecutiveBytesMatchingInto does the same thing as ReadConsecutiveBytesMatching, but places matching bytes
+// into receiver.  If there are more consecutive bytes than the length of receiver, only len(receiver) bytes are
+// returned, and the Nibbler pointer will be at the next byte (even if it also matches).  Return the number of
+// consecutive matching bytes.  Return io.EOF only if the Nibbler cursor was already at io.EOF.
+func (matcher *ByteNibblerMatcher) ReadConsecutiveBytesMatchingInto(matchFunction ByteMatchingFunction, receiver []byte) (int, error) {
+	for i := 0; i < len(receiver); i++ {
+		nextByte, err := matcher.nibbler.PeekAtNextByte()
+		if err != nil {
+			if err == io.EOF {
+				if i == 0 {
+					return 0, io.EOF
+				}
+
+				return i, nil
+			}
+
+			return -1, err
+		}
+
+		if matchFunction(nextByte) {
+			matcher.nibbler.ReadByte()
+			receiver[i] = nextByte
+		} else {
+			return i, nil
+		}
+	}
+
+	return len(receiver), nil
+}
+
+// ReadConsecutiveBytesNotMatchingInto does the same thing as ReadConsecutiveBytesMatchingInto, but adds bytes
+// for which the ByteMatchingFunction returns false.
+func (matcher *ByteNibblerMatcher) ReadConsecutiveBytesNotMatchingInto(matchFunction ByteMatchingFunction, receiver []byte) (int, error) {
+	for i := 0; i < len(receiver); i++ {
+		nextByte, err := matcher.nibbler.PeekAtNextByte()
+		if err != nil {
+			if err == io.EOF {
+				if i == 0 {
+					return 0, io.EOF
+				}
+
+				return i, nil
+			}
+
+			return -1, err
+		}
+
+		if !matchFunction(nextByte) {
+			matcher.nibbler.ReadByte()
+			receiver[i] = nextByte
+		} else {
+			return i, nil
+		}
+	}
+
+	return len(receiver), nil
+}
+
+func byteIsASCIIWhitespace(b byte) bool {
+	switch b {
+	case ' ', '\t', '\n', '\v', '\f', '\r':
+		return true
+	}
+
+	return false
+}
+
+// ReadConsecutiveWhitespace returns consecutive ASCII whitespace bytes.
+func (matcher *ByteNibblerMatcher) ReadConsecutiveWhitespace() ([]byte, error) {
+	return matcher.ReadConsecutiveBytesMatching(byteIsASCIIWhitespace)
+}
+
+// ReadConsecutiveWhitespaceInto returns consecutive ASCII whitespace bytes into the receiver.
+func (matcher *ByteNibblerMatcher) ReadConsecutiveWhitespaceInto(receiver []byte) (int, error) {
+	return matcher.ReadConsecutiveBytesMatchingInto(byteIsASCIIWhitespace, receiver)
+}
+
+// ReadConsecutiveWordBytes returns consecutive bytes that are not ASCII whitespace.
+func (matcher *ByteNibblerMatcher) ReadConsecutiveWordBytes() ([]byte, error) {
+	return matcher.ReadConsecutiveBytesNotMatching(byteIsASCIIWhitespace)
+}
+
+// ReadConsecutiveWordBytesInto returns consecutive bytes that are not ASCII whitespace into the receiver.
+func (matcher *ByteNibblerMatcher) ReadConsecutiveWordBytesInto(receiver []byte) (int, error) {
+	return matcher.ReadConsecutiveBytesNotMatchingInto(byteIsASCIIWhitespace, receiver)
+}
+
+// DiscardConsecutiveBytesMatching advances the cursor in the Nibbler until it reaches a byte that does not
+// match the ByteMatchingFunction.  Return the number of discarded bytes.
+func (matcher *ByteNibblerMatcher) DiscardConsecutiveBytesMatching(matchFunction ByteMatchingFunction) (int, error) {
+	discardedBytes := 0
+
+	for {
+		nextByte, err := matcher.nibbler.PeekAtNextByte()
+		if err != nil {
+			if err == io.EOF {
+				if discardedBytes == 0 {
+					return 0, io.EOF
+				}
+
+				return discardedBytes, nil
+			}
+
+			return discardedBytes, err
+		}
+
+		if matchFunction(nextByte) {
+			matcher.nibbler.ReadByte()
+			discardedBytes++
+		} else {
+			return discardedBytes, nil
+		}
+	}
+}
+
+// DiscardConsecutiveBytesNotMatching does the same thing as DiscardConsecutiveBytesMatching but advances the
+// cursor through bytes for which the ByteMatchingFunction returns false.
+func (matcher *ByteNibblerMatcher) DiscardConsecutiveBytesNotMatching(matchFunction ByteMatchingFunction) (int, error) {
+	discardedBytes := 0
+
+	for {
+		nextByte, err := matcher.nibbler.PeekAtNextByte()
+		if err != nil {
+			if err == io.EOF {
+				if discardedBytes == 0 {
+					return 0, io.EOF
+				}
+
+				return discardedBytes, nil
+			}
+
+			return discardedBytes, err
+		}
+
+		if !matchFunction(nextByte) {
+			matcher.nibbler.ReadByte()
+			discardedBytes++
+		} else {
+			return discardedBytes, nil
+		}
+	}
+}
+
+// DiscardConsecutiveWhitespaceBytes discards consecutive ASCII whitespace bytes, returning the number of
+// discarded bytes.
+func (matcher *ByteNibblerMatcher) DiscardConsecutiveWhitespaceBytes() (int, error) {
+	return matcher.DiscardConsecutiveBytesMatching(byteIsASCIIWhitespace)
+}
+
+// DiscardConsecutiveWordBytes discards consecutive bytes that are not ASCII whitespace, returning the number
+// of discarded bytes.
+func (matcher *ByteNibblerMatcher) DiscardConsecutiveWordBytes() (int, error) {
+	return matcher.DiscardConsecutiveBytesNotMatching(byteIsASCIIWhitespace)
+}
+
+// UnderlyingNibbler returns the ByteNibbler used by the matcher.
+func (matcher *ByteNibblerMatcher) UnderlyingNibbler() ByteNibbler {
+	return matcher.nibbler
+}
@@ -0,0 +1,516 @@
+package nibblers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ErrParseFailed is wrapped by every ParseError, so that a failed parse can be distinguished from an error
+// returned by the nibbler with errors.Is.
+var ErrParseFailed = errors.New("parse failed")
+
+// ErrParserInputType is returned by a parse that applies a Parser to the wrong kind of nibbler (for example,
+// a Character parser to a ByteNibbler).
+var ErrParserInputType = errors.New("parser does not support this kind of nibbler")
+
+// ParseError describes a failed parse.  Position is the furthest position in the stream at which a Parser
+// failed, which is usually the location of the mistake in the input.  Expected is the sorted set of
+// descriptions of what the Parsers that failed at that position expected to find there.  When parsing from a
+// ByteNibbler, only the ByteOffset of Position is set.
+type ParseError struct {
+	Position Position
+	Expected []string
+}
+
+func (parseError *ParseError) Error() string {
+	location := fmt.Sprintf("byte offset %d", parseError.Position.ByteOffset)
+	if parseError.Position.Line > 0 {
+		location = fmt.Sprintf("line %d, column %d", parseError.Position.Line, parseError.Position.Column)
+	}
+
+	if len(parseError.Expected) == 0 {
+		return fmt.Sprintf("%s at %s", ErrParseFailed, location)
+	}
+
+	return fmt.Sprintf("%s at %s: expected %s", ErrParseFailed, location, strings.Join(parseError.Expected, " or "))
+}
+
+// Unwrap returns ErrParseFailed.
+func (parseError *ParseError) Unwrap() error {
+	return ErrParseFailed
+}
+
+// ParseState is the state of a parse that is shared by every Parser: the nibbler being parsed, the furthest
+// failure so far, and any error returned by the nibbler.
+type ParseState struct {
+	utf8Nibbler               UTF8Nibbler // nil if parsing from a ByteNibbler
+	byteNibbler               ByteNibbler // nil if parsing from a UTF8Nibbler
+	utf8Matcher               *UTF8NibblerMatcher
+	byteMatcher               *ByteNibblerMatcher
+	positionOfFurthestFailure Position
+	expectedAtFurthestFailure map[string]bool
+	failuresAreIgnored        bool
+	err                       error
+}
+
+// Parser attempts to parse a value at the cursor of the nibbler in state.  If it succeeds, it returns the
+// value and true, with the cursor after the parsed input.  If it fails, it returns false, and the cursor must be
+// where it was when the Parser was called.  The Parsers in this package (and those composed from them) always
+// restore the cursor.  A custom Parser reports its failures with ParseState.Fail.
+type Parser func(state *ParseState) (value interface{}, parsed bool)
+
+// byteOffsetTracker is implemented by the ByteNibblers in this package, which track the stream offset of
+// their cursor.
+type byteOffsetTracker interface {
+	byteOffsetOfCursor() int64
+}
+
+func newParseState() *ParseState {
+	return &ParseState{
+		expectedAtFurthestFailure: make(map[string]bool),
+	}
+}
+
+// ParseUTF8 applies parser at the cursor of nibbler and returns the parsed value.  If parser succeeds, the
+// cursor is after the parsed input, which need not be the end of the stream (see EndOfInput).  If it fails,
+// the cursor is not moved, and a *ParseError is returned.  If the nibbler returns an error other than io.EOF,
+// that error is returned.
+func ParseUTF8(parser Parser, nibbler UTF8Nibbler) (interface{}, error) {
+	state := newParseState()
+	state.utf8Nibbler = nibbler
+	state.utf8Matcher = NewUTF8NibblerMatcher(nibbler)
+
+	return state.parse(parser)
+}
+
+// ParseBytes does the same thing as ParseUTF8, but applies parser to a ByteNibbler.
+func ParseBytes(parser Parser, nibbler ByteNibbler) (interface{}, error) {
+	state := newParseState()
+	state.byteNibbler = nibbler
+	state.byteMatcher = NewByteNibblerMatcher(nibbler)
+
+	return state.parse(parser)
+}
+
+func (state *ParseState) parse(parser Parser) (interface{}, error) {
+	state.positionOfFurthestFailure = state.Position()
+
+	value, parsed := parser(state)
+	if state.err != nil {
+		return nil, state.err
+	}
+
+	if !parsed {
+		expected := make([]string, 0, len(state.expectedAtFurthestFailure))
+		for description := range state.expectedAtFurthestFailure {
+			expected = append(expected, description)
+		}
+		sort.Strings(expected)
+
+		return nil, &ParseError{Position: state.positionOfFurthestFailure, Expected: expected}
+	}
+
+	return value, nil
+}
+
+// UTF8Nibbler returns the nibbler being parsed, or nil if the parse is of a ByteNibbler.
+func (state *ParseState) UTF8Nibbler() UTF8Nibbler {
+	return state.utf8Nibbler
+}
+
+// ByteNibbler returns the nibbler being parsed, or nil if the parse is of a UTF8Nibbler.
+func (state *ParseState) ByteNibbler() ByteNibbler {
+	return state.byteNibbler
+}
+
+// Position returns the position of the cursor.  When parsing from a ByteNibbler, only the ByteOffset is set.
+func (state *ParseState) Position() Position {
+	if state.utf8Nibbler != nil {
+		return state.utf8Nibbler.Position()
+	}
+
+	if tracker, isTracker := state.byteNibbler.(byteOffsetTracker); isTracker {
+		return Position{ByteOffset: tracker.byteOffsetOfCursor()}
+	}
+
+	// a ByteNibbler from outside this package can only produce a Mark by wrapping one of ours
+	mark := state.byteNibbler.Mark()
+	state.byteNibbler.Release(mark)
+
+	return Position{ByteOffset: mark.byteOffset}
+}
+
+// Fail records that a Parser expected the input described by expected at the cursor, and returns the values
+// that a failed Parser returns.  Only the failures at the furthest position are reported in a ParseError.
+func (state *ParseState) Fail(expected string) (interface{}, bool) {
+	if state.failuresAreIgnored {
+		return nil, false
+	}
+
+	position := state.Position()
+	if position.ByteOffset > state.positionOfFurthestFailure.ByteOffset {
+		state.positionOfFurthestFailure = position
+		state.expectedAtFurthestFailure = make(map[string]bool)
+	}
+
+	if position.ByteOffset == state.positionOfFurthestFailure.ByteOffset {
+		state.expectedAtFurthestFailure[expected] = true
+	}
+
+	return nil, false
+}
+
+// Abort stops the parse, which returns err, and returns the values that a failed Parser returns.  It is used
+// by a custom Parser when the nibbler returns an error other than io.EOF.
+func (state *ParseState) Abort(err error) (interface{}, bool) {
+	if state.err == nil {
+		state.err = err
+	}
+
+	return nil, false
+}
+
+// failOrAbort fails with expected if err is nil or io.EOF, and otherwise aborts with err.
+func (state *ParseState) failOrAbort(expected string, err error) (interface{}, bool) {
+	if err != nil && err != io.EOF {
+		return state.Abort(err)
+	}
+
+	return state.Fail(expected)
+}
+
+func (state *ParseState) mark() Mark {
+	if state.utf8Nibbler != nil {
+		return state.utf8Nibbler.Mark()
+	}
+
+	return state.byteNibbler.Mark()
+}
+
+func (state *ParseState) resetTo(mark Mark) {
+	if state.utf8Nibbler != nil {
+		state.utf8Nibbler.ResetTo(mark)
+	} else {
+		state.byteNibbler.ResetTo(mark)
+	}
+}
+
+func (state *ParseState) release(mark Mark) {
+	if state.utf8Nibbler != nil {
+		state.utf8Nibbler.Release(mark)
+	} else {
+		state.byteNibbler.Release(mark)
+	}
+}
+
+// Literal returns a Parser that matches exactly the characters (or, for a ByteNibbler, the bytes) of literal.
+// Its value is literal.
+func Literal(literal string) Parser {
+	expected := strconv.Quote(literal)
+
+	return func(state *ParseState) (interface{}, bool) {
+		var nextUnitsMatch bool
+		var err error
+
+		if state.utf8Nibbler != nil {
+			var nextCharacters []rune
+			nextCharacters, err = state.utf8Nibbler.PeekAtNextCharacters(uint(len([]rune(literal))))
+			nextUnitsMatch = err == nil && string(nextCharacters) == literal
+		} else {
+			var nextBytes []byte
+			nextBytes, err = state.byteNibbler.PeekAtNextBytes(uint(len(literal)))
+			nextUnitsMatch = err == nil && string(nextBytes) == literal
+		}
+
+		if !nextUnitsMatch {
+			return state.failOrAbort(expected, err)
+		}
+
+		// a read can fail where the peek did not, for example when it would exceed a bookend limit
+		mark := state.mark()
+		defer state.release(mark)
+
+		if state.utf8Nibbler != nil {
+			for range []rune(literal) {
+				if _, err = state.utf8Nibbler.ReadCharacter(); err != nil {
+					break
+				}
+			}
+		} else {
+			_, err = state.byteNibbler.ReadFixedNumberOfBytes(uint(len(literal)))
+		}
+
+		if err != nil {
+			state.resetTo(mark)
+			return state.failOrAbort(expected, err)
+		}
+
+		return literal, true
+	}
+}
+
+// Character returns a Parser that matches one character for which matchFunction returns true.  Its value is
+// the rune.  The description is reported in a ParseError if the Parser fails.  It only applies to a UTF8Nibbler.
+func Character(matchFunction CharacterMatchingFunction, description string) Parser {
+	return func(state *ParseState) (interface{}, bool) {
+		if state.utf8Nibbler == nil {
+			return state.Abort(ErrParserInputType)
+		}
+
+		nextCharacter, err := state.utf8Nibbler.PeekAtNextCharacter()
+		if err != nil || !matchFunction(nextCharacter) {
+			return state.failOrAbort(description, err)
+		}
+
+		if _, err := state.utf8Nibbler.ReadCharacter(); err != nil {
+			return state.failOrAbort(description, err)
+		}
+
+		return nextCharacter, true
+	}
+}
+
+// Byte returns a Parser that matches one byte for which matchFunction returns true.  Its value is the byte.
+// The description is reported in a ParseError if the Parser fails.  It only applies to a ByteNibbler.
+func Byte(matchFunction ByteMatchingFunction, description string) Parser {
+	return func(state *ParseState) (interface{}, bool) {
+		if state.byteNibbler == nil {
+			return state.Abort(ErrParserInputType)
+		}
+
+		nextByte, err := state.byteNibbler.PeekAtNextByte()
+		if err != nil || !matchFunction(nextByte) {
+			return state.failOrAbort(description, err)
+		}
+
+		if _, err := state.byteNibbler.ReadByte(); err != nil {
+			return state.failOrAbort(description, err)
+		}
+
+		return nextByte, true
+	}
+}
+
+// Regexp returns a Parser that matches re at the cursor, as ReadMatchingRegexp does.  Its value is the
+// matched text, as a string.
+func Regexp(re *regexp.Regexp) Parser {
+	return RegexpWithSyntax(re, PerlRegexpSyntax)
+}
+
+// RegexpWithSyntax returns a Parser that matches re, which was compiled with syntaxOfRegexp, at the cursor, as
+// ReadMatchingRegexpWithSyntax does.  Its value is the matched text, as a string.
+func RegexpWithSyntax(re *regexp.Regexp, syntaxOfRegexp RegexpSyntax) Parser {
+	expected := "/" + re.String() + "/"
+	anchored := anchoredRegexp(re, syntaxOfRegexp)
+
+	return func(state *ParseState) (interface{}, bool) {
+		if state.utf8Nibbler != nil {
+			submatches, err := state.utf8Matcher.readMatchingAnchoredRegexp(anchored)
+			if submatches == nil {
+				return state.failOrAbort(expected, err)
+			}
+
+			return submatches[0], true
+		}
+
+		submatches, err := state.byteMatcher.readMatchingAnchoredRegexp(anchored)
+		if submatches == nil {
+			return state.failOrAbort(expected, err)
+		}
+
+		return string(submatches[0]), true
+	}
+}
+
+// EndOfInput returns a Parser that matches only at the end of the stream.  Its value is nil.
+func EndOfInput() Parser {
+	return func(state *ParseState) (interface{}, bool) {
+		var err error
+		if state.utf8Nibbler != nil {
+			_, err = state.utf8Nibbler.PeekAtNextCharacter()
+		} else {
+			_, err = state.byteNibbler.PeekAtNextByte()
+		}
+
+		if err != io.EOF {
+			return state.failOrAbort("end of input", err)
+		}
+
+		return nil, true
+	}
+}
+
+// Sequence returns a Parser that applies each of parsers in turn, and succeeds if they all succeed.  Its
+// value is a []interface{} holding the value of each parser.  If any parser fails, the cursor is returned to
+// where the Sequence started.
+func Sequence(parsers ...Parser) Parser {
+	return func(state *ParseState) (interface{}, bool) {
+		mark := state.mark()
+		defer state.release(mark)
+
+		values := make([]interface{}, 0, len(parsers))
+		for _, parser := range parsers {
+			value, parsed := parser(state)
+			if !parsed {
+				state.resetTo(mark)
+				return nil, false
+			}
+
+			values = append(values, value)
+		}
+
+		return values, true
+	}
+}
+
+// Choice returns a Parser that applies each of parsers in turn until one succeeds, and has the value of
+// that parser.  As in a PEG, the first parser that succeeds is chosen, even if a later one would match more
+// input.
+func Choice(parsers ...Parser) Parser {
+	return func(state *ParseState) (interface{}, bool) {
+		for _, parser := range parsers {
+			if value, parsed := parser(state); parsed || state.err != nil {
+				return value, parsed
+			}
+		}
+
+		return nil, false
+	}
+}
+
+// Many returns a Parser that applies parser as many times as it succeeds (including zero times).  Its value is
+// a []interface{} holding the value of each success.  Many stops if parser succeeds without consuming input.
+func Many(parser Parser) Parser {
+	return func(state *ParseState) (interface{}, bool) {
+		values := make([]interface{}, 0, 4)
+
+		for {
+			byteOffsetBefore := state.Position().ByteOffset
+
+			value, parsed := parser(state)
+			if state.err != nil {
+				return nil, false
+			}
+
+			if !parsed {
+				return values, true
+			}
+
+			values = append(values, value)
+
+			if state.Position().ByteOffset == byteOffsetBefore {
+				return values, true
+			}
+		}
+	}
+}
+
+// Optional returns a Parser that applies parser and always succeeds.  Its value is the value of parser, or
+// nil if parser fails.
+func Optional(parser Parser) Parser {
+	return func(state *ParseState) (interface{}, bool) {
+		if value, parsed := parser(state); parsed || state.err != nil {
+			return value, parsed
+		}
+
+		return nil, true
+	}
+}
+
+// Map returns a Parser that applies parser and, if it succeeds, has the value returned by transform for
+// the value of parser.
+func Map(parser Parser, transform func(value interface{}) interface{}) Parser {
+	return func(state *ParseState) (interface{}, bool) {
+		value, parsed := parser(state)
+		if !parsed {
+			return nil, false
+		}
+
+		return transform(value), true
+	}
+}
+
+// Lookahead returns a Parser that succeeds if parser succeeds, but never consumes input.  Its value is the
+// value of parser.
+func Lookahead(parser Parser) Parser {
+	return func(state *ParseState) (interface{}, bool) {
+		mark := state.mark()
+		defer state.release(mark)
+
+		value, parsed := parser(state)
+		state.resetTo(mark)
+
+		return value, parsed
+	}
+}
+
+// Not returns a Parser that succeeds if parser fails, and never consumes input.  Its value is nil.  The
+// failures of parser are not reported in a ParseError, and Not itself reports none, so it should usually be
+// wrapped in Label.
+func Not(parser Parser) Parser {
+	return func(state *ParseState) (interface{}, bool) {
+		mark := state.mark()
+		defer state.release(mark)
+
+		failuresWereIgnored := state.failuresAreIgnored
+		state.failuresAreIgnored = true
+
+		_, parsed := parser(state)
+
+		state.failuresAreIgnored = failuresWereIgnored
+		state.resetTo(mark)
+
+		if state.err != nil {
+			return nil, false
+		}
+
+		return nil, !parsed
+	}
+}
+
+// Label returns a Parser that applies parser, but if parser fails without getting past the cursor, reports
+// name as what was expected in place of the expectations of parser.  For example, a ParseError can report
+// "number" rather than each of the characters that can start a number.
+func Label(name string, parser Parser) Parser {
+	return func(state *ParseState) (interface{}, bool) {
+		positionOfFurthestFailureBefore := state.positionOfFurthestFailure
+		expectedAtFurthestFailureBefore := state.expectedAtFurthestFailure
+		state.expectedAtFurthestFailure = make(map[string]bool)
+
+		value, parsed := parser(state)
+
+		if !parsed && state.err == nil && state.positionOfFurthestFailure.ByteOffset <= state.Position().ByteOffset {
+			state.positionOfFurthestFailure = positionOfFurthestFailureBefore
+			state.expectedAtFurthestFailure = expectedAtFurthestFailureBefore
+
+			return state.Fail(name)
+		}
+
+		if state.positionOfFurthestFailure.ByteOffset == positionOfFurthestFailureBefore.ByteOffset {
+			for description := range expectedAtFurthestFailureBefore {
+				state.expectedAtFurthestFailure[description] = true
+			}
+		}
+
+		return value, parsed
+	}
+}
+
+// Deferred returns a Parser that applies the Parser returned by makeParser, which is called the first time
+// that the Parser is applied.  This permits a recursive grammar to refer to a Parser before it is defined.
+func Deferred(makeParser func() Parser) Parser {
+	var parser Parser
+
+	return func(state *ParseState) (interface{}, bool) {
+		if parser == nil {
+			parser = makeParser()
+		}
+
+		return parser(state)
+	}
+}
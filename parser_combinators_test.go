@@ -0,0 +1,195 @@
+package nibblers_test
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"testing"
+	"unicode"
+
+	nibblers "github.com/blorticus-go/nibblers"
+	mock "github.com/blorticus/go-test-mocks"
+)
+
+// arithmeticGrammar returns a Parser for sums and differences of integers and parenthesized expressions,
+// whose value is the int result.
+func arithmeticGrammar() nibblers.Parser {
+	whitespace := nibblers.Regexp(regexp.MustCompile(`\s*`))
+	token := func(parser nibblers.Parser) nibblers.Parser {
+		return nibblers.Map(nibblers.Sequence(parser, whitespace), func(value interface{}) interface{} {
+			return value.([]interface{})[0]
+		})
+	}
+
+	var expression nibblers.Parser
+
+	number := nibblers.Label("number", token(nibblers.Map(nibblers.Regexp(regexp.MustCompile(`[0-9]+`)), func(value interface{}) interface{} {
+		n, _ := strconv.Atoi(value.(string))
+		return n
+	})))
+
+	parenthesized := nibblers.Map(nibblers.Sequence(token(nibblers.Literal("(")), nibblers.Deferred(func() nibblers.Parser { return expression }), token(nibblers.Literal(")"))), func(value interface{}) interface{} {
+		return value.([]interface{})[1]
+	})
+
+	term := nibblers.Choice(number, parenthesized)
+
+	expression = nibblers.Map(nibblers.Sequence(term, nibblers.Many(nibblers.Sequence(token(nibblers.Choice(nibblers.Literal("+"), nibblers.Literal("-"))), term))), func(value interface{}) interface{} {
+		sum := value.([]interface{})[0].(int)
+		for _, operation := range value.([]interface{})[1].([]interface{}) {
+			if operation.([]interface{})[0] == "+" {
+				sum += operation.([]interface{})[1].(int)
+			} else {
+				sum -= operation.([]interface{})[1].(int)
+			}
+		}
+		return sum
+	})
+
+	return nibblers.Map(nibblers.Sequence(whitespace, expression, nibblers.EndOfInput()), func(value interface{}) interface{} {
+		return value.([]interface{})[1]
+	})
+}
+
+func TestParserCombinators(t *testing.T) {
+	grammar := arithmeticGrammar()
+
+	for testCaseIndex, testCase := range []struct {
+		input            string
+		expectedValue    int
+		expectedColumn   int // of the ParseError, if one is expected
+		expectedExpected []string
+	}{
+		{input: "1 + 2 - (3 + 4)", expectedValue: -4},
+		{input: " ((10)-(2-1))+100 ", expectedValue: 109},
+		{input: "1 + * 2", expectedColumn: 5, expectedExpected: []string{`"("`, "number"}},
+		{input: "1 + (2", expectedColumn: 7, expectedExpected: []string{`")"`, `"+"`, `"-"`}},
+		{input: "1 2", expectedColumn: 3, expectedExpected: []string{`"+"`, `"-"`, "end of input"}},
+		{input: "", expectedColumn: 1, expectedExpected: []string{`"("`, "number"}},
+	} {
+		for _, typeOfNibbler := range []string{"String", "Reader"} {
+			var nibbler nibblers.UTF8Nibbler = nibblers.NewUTF8StringNibbler(testCase.input)
+			if typeOfNibbler == "Reader" {
+				reader := mock.NewReader()
+				for i := 0; i < len(testCase.input); i += 2 {
+					end := i + 2
+					if end > len(testCase.input) {
+						end = len(testCase.input)
+					}
+					reader.AddGoodRead([]byte(testCase.input[i:end]))
+				}
+				readerNibbler := nibblers.NewUTF8ReaderNibbler(reader.AddEOF())
+				readerNibbler.SetMaximumUnreadDepth(0)
+				nibbler = readerNibbler
+			}
+
+			value, err := nibblers.ParseUTF8(grammar, nibbler)
+
+			if testCase.expectedExpected == nil {
+				if err != nil {
+					t.Errorf("(TestParserCombinators) (%s) (test case %d) expected no error, got (%s)", typeOfNibbler, testCaseIndex+1, err.Error())
+				} else if value != testCase.expectedValue {
+					t.Errorf("(TestParserCombinators) (%s) (test case %d) expected value (%d), got (%v)", typeOfNibbler, testCaseIndex+1, testCase.expectedValue, value)
+				}
+				continue
+			}
+
+			var parseError *nibblers.ParseError
+			if !errors.As(err, &parseError) || !errors.Is(err, nibblers.ErrParseFailed) {
+				t.Errorf("(TestParserCombinators) (%s) (test case %d) expected a *ParseError, got (%v)", typeOfNibbler, testCaseIndex+1, err)
+				continue
+			}
+
+			if parseError.Position.Line != 1 || parseError.Position.Column != testCase.expectedColumn {
+				t.Errorf("(TestParserCombinators) (%s) (test case %d) expected error at column (%d), got (%s)", typeOfNibbler, testCaseIndex+1, testCase.expectedColumn, err.Error())
+			}
+
+			if err := compareSubmatches(testCase.expectedExpected, parseError.Expected); err != nil {
+				t.Errorf("(TestParserCombinators) (%s) (test case %d) on Expected %s", typeOfNibbler, testCaseIndex+1, err.Error())
+			}
+
+			if position := nibbler.Position(); position.ByteOffset != 0 {
+				t.Errorf("(TestParserCombinators) (%s) (test case %d) expected cursor at start after failed parse, got (%d)", typeOfNibbler, testCaseIndex+1, position.ByteOffset)
+			}
+		}
+	}
+}
+
+func TestParserCombinatorsLookaheadAndNot(t *testing.T) {
+	keyword := nibblers.Sequence(nibblers.Literal("if"), nibblers.Not(nibblers.Character(unicode.IsLetter, "letter")))
+	identifier := nibblers.Map(nibblers.Sequence(nibblers.Label("identifier", nibblers.Not(keyword)), nibblers.Regexp(regexp.MustCompile(`\pL+`))), func(value interface{}) interface{} {
+		return value.([]interface{})[1]
+	})
+
+	for _, testCase := range []struct {
+		input         string
+		expectedValue interface{}
+		expectFailure bool
+	}{
+		{input: "iffy", expectedValue: "iffy"},
+		{input: "if x", expectFailure: true},
+		{input: "x", expectedValue: "x"},
+	} {
+		value, err := nibblers.ParseUTF8(identifier, nibblers.NewUTF8StringNibbler(testCase.input))
+		if testCase.expectFailure {
+			var parseError *nibblers.ParseError
+			if !errors.As(err, &parseError) || len(parseError.Expected) != 1 || parseError.Expected[0] != "identifier" {
+				t.Errorf("(TestParserCombinatorsLookaheadAndNot) (%s) expected ParseError expecting (identifier), got (%v)", testCase.input, err)
+			}
+		} else if err != nil || value != testCase.expectedValue {
+			t.Errorf("(TestParserCombinatorsLookaheadAndNot) (%s) expected (%v), got (%v) and (%v)", testCase.input, testCase.expectedValue, value, err)
+		}
+	}
+
+	nibbler := nibblers.NewUTF8StringNibbler("ab")
+	value, err := nibblers.ParseUTF8(nibblers.Sequence(nibblers.Lookahead(nibblers.Literal("ab")), nibblers.Optional(nibblers.Literal("x")), nibblers.Literal("a")), nibbler)
+	if err != nil || len(value.([]interface{})) != 3 || value.([]interface{})[0] != "ab" || value.([]interface{})[1] != nil {
+		t.Errorf("(TestParserCombinatorsLookaheadAndNot) on Lookahead expected ([ab <nil> a]), got (%v) and (%v)", value, err)
+	}
+
+	if r, err := nibbler.ReadCharacter(); err != nil || r != 'b' {
+		t.Errorf("(TestParserCombinatorsLookaheadAndNot) after parse expected next character (b), got (%c) and (%v)", r, err)
+	}
+}
+
+func TestParserCombinatorsOverBytes(t *testing.T) {
+	digit := nibblers.Byte(func(b byte) bool { return b >= '0' && b <= '9' }, "digit")
+	pair := nibblers.Sequence(nibblers.Regexp(regexp.MustCompile(`[a-z]+`)), nibblers.Literal("="), digit, nibblers.Many(digit), nibblers.Literal(";"))
+
+	value, err := nibblers.ParseBytes(nibblers.Many(pair), nibblers.NewByteSliceNibbler([]byte("a=1;bc=23;")))
+	if err != nil || len(value.([]interface{})) != 2 {
+		t.Errorf("(TestParserCombinatorsOverBytes) expected two pairs, got (%v) and (%v)", value, err)
+	}
+
+	_, err = nibblers.ParseBytes(nibblers.Sequence(nibblers.Many(pair), nibblers.EndOfInput()), nibblers.NewByteSliceNibbler([]byte("a=1;bc=;")))
+	var parseError *nibblers.ParseError
+	if !errors.As(err, &parseError) || parseError.Position.ByteOffset != 7 || len(parseError.Expected) != 1 || parseError.Expected[0] != "digit" {
+		t.Errorf("(TestParserCombinatorsOverBytes) expected ParseError at byte offset (7) expecting (digit), got (%v)", err)
+	}
+
+	if _, err := nibblers.ParseBytes(nibblers.Character(unicode.IsLetter, "letter"), nibblers.NewByteSliceNibbler([]byte("a"))); err != nibblers.ErrParserInputType {
+		t.Errorf("(TestParserCombinatorsOverBytes) on Character parser expected ErrParserInputType, got (%v)", err)
+	}
+}
+
+func TestParserCombinatorsWithBookendLimit(t *testing.T) {
+	for _, testCase := range []struct {
+		name   string
+		parser nibblers.Parser
+	}{
+		{name: "Literal", parser: nibblers.Sequence(nibblers.Literal("ab"), nibblers.Literal("cd"))},
+		{name: "Character", parser: nibblers.Sequence(nibblers.Literal("abc"), nibblers.Character(unicode.IsLetter, "letter"))},
+	} {
+		nibbler := nibblers.NewUTF8ReaderNibbler(mock.NewReader().AddGoodRead([]byte("abcdef")).AddEOF())
+		nibbler.SetMaximumBookendLength(3)
+		nibbler.StartBookending()
+
+		if value, err := nibblers.ParseUTF8(testCase.parser, nibbler); !errors.Is(err, nibblers.ErrBookendLimitExceeded) {
+			t.Errorf("(TestParserCombinatorsWithBookendLimit) (%s) expected ErrBookendLimitExceeded, got (%v) and (%v)", testCase.name, value, err)
+		}
+
+		if position := nibbler.Position(); position.ByteOffset != 0 {
+			t.Errorf("(TestParserCombinatorsWithBookendLimit) (%s) expected cursor at start after failed parse, got (%d)", testCase.name, position.ByteOffset)
+		}
+	}
+}
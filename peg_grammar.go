@@ -0,0 +1,745 @@
+package nibblers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+// ErrUndefinedGrammarRule is returned by NewGrammar when an expression refers to a rule that the grammar does
+// not define, and by Grammar.ParseRule for an undefined rule name.
+var ErrUndefinedGrammarRule = errors.New("grammar rule is not defined")
+
+// ErrDuplicateGrammarRule is returned by NewGrammar when a rule is defined more than once.
+var ErrDuplicateGrammarRule = errors.New("grammar rule is defined more than once")
+
+// ErrLeftRecursiveGrammarRule is returned by NewGrammar when a rule can refer to itself without consuming
+// any input, which a PEG cannot parse.
+var ErrLeftRecursiveGrammarRule = errors.New("grammar rule is left recursive")
+
+// ParseTreeNode is a node of the tree produced by a Grammar.  Each node records a match of a rule: Rule is
+// the rule name, Text is the matched text, StartPos is the position of its first character and EndPos is the
+// position of the character after it.  Children holds the nodes for the rules referred to by the rule's
+// expression, in the order of their text.  Literals, classes and the other terminals do not produce nodes,
+// nor do the rules referred to inside a predicate.
+type ParseTreeNode struct {
+	Rule     string
+	Text     string
+	StartPos Position
+	EndPos   Position
+	Children []*ParseTreeNode
+}
+
+type pegExpressionKind int
+
+const (
+	pegLiteral pegExpressionKind = iota
+	pegClass
+	pegAnyCharacter
+	pegRuleReference
+	pegSequence
+	pegChoice
+	pegZeroOrMore
+	pegOneOrMore
+	pegOptional
+	pegAndPredicate
+	pegNotPredicate
+)
+
+type pegCharacterRange struct {
+	low  rune
+	high rune
+}
+
+// pegExpression is a node of a parsed grammar expression.  Only the fields for its kind are set.
+type pegExpression struct {
+	kind               pegExpressionKind
+	literalCharacters  []rune
+	classRanges        []pegCharacterRange
+	classIsNegated     bool
+	ruleName           string
+	indexOfRule        int
+	subexpressions     []*pegExpression
+	descriptionOfMatch string // reported in a ParseError, for terminals
+}
+
+type pegRule struct {
+	name       string
+	expression *pegExpression
+}
+
+// Grammar is a parsing expression grammar (PEG), loaded from text by NewGrammar, which parses any UTF8Nibbler
+// into a tree of ParseTreeNodes.  The result of each rule at each offset is memoized (that is, it is a
+// packrat parser), so a parse takes time linear in the length of the input.  A Grammar is not modified by a
+// parse, so it may be used for any number of parses, including concurrent ones.
+type Grammar struct {
+	rules        []*pegRule
+	indexForRule map[string]int
+}
+
+// NewGrammar parses grammarText as a PEG, in the notation of Ford's original paper:
+//
+//	# the first rule is the start rule
+//	Sum    <- Number (("+" / "-") Number)* !.
+//	Number <- [0-9]+
+//
+// A rule is defined by a name, "<-" and an expression.  In an expression, "/" separates ordered choices, and
+// items in sequence are separated by whitespace.  An item is a rule name, a literal in single or double
+// quotes, a character class in square brackets (which may begin with "^" to negate it and may contain
+// ranges, like "a-z"), "." for any character, or a parenthesized expression.  An item may be followed by "*",
+// "+" or "?", and preceded by "&" (the item must match, but is not consumed) or "!" (the item must not
+// match).  Literals and classes accept the escapes \n, \r, \t, \\, \', \", \[, \], \^ and \-.  A "#" starts
+// a comment that runs to the end of the line.  A range in a class may not be reversed (like "z-a").  If
+// grammarText is not a valid grammar, a *ParseError describing the mistake is returned (for a reversed range,
+// wrapped with the range), or ErrUndefinedGrammarRule, ErrDuplicateGrammarRule or ErrLeftRecursiveGrammarRule,
+// wrapped with the name of the rule.
+func NewGrammar(grammarText string) (*Grammar, error) {
+	parsedRules, err := ParseUTF8(newPEGGrammarTextParser(), NewUTF8StringNibbler(grammarText))
+	if err != nil {
+		return nil, err
+	}
+
+	grammar := &Grammar{
+		rules:        parsedRules.([]*pegRule),
+		indexForRule: make(map[string]int),
+	}
+
+	for i, rule := range grammar.rules {
+		if _, isDefined := grammar.indexForRule[rule.name]; isDefined {
+			return nil, fmt.Errorf("%w: %s", ErrDuplicateGrammarRule, rule.name)
+		}
+
+		grammar.indexForRule[rule.name] = i
+	}
+
+	for _, rule := range grammar.rules {
+		if err := grammar.resolveRuleReferences(rule.expression); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := grammar.checkForLeftRecursion(); err != nil {
+		return nil, err
+	}
+
+	return grammar, nil
+}
+
+// StartRule returns the name of the first rule in the grammar text, which is the rule used by Parse.
+func (grammar *Grammar) StartRule() string {
+	return grammar.rules[0].name
+}
+
+// Parse matches the start rule of the grammar at the cursor of nibbler, and returns the tree of the match,
+// whose root is the node for the start rule.  The match need not reach the end of the stream unless the
+// grammar requires it (for example, with !.).  On success, the cursor is moved past the matched text.
+// Otherwise, the cursor is not moved, and a *ParseError is returned, with the furthest position at which a
+// terminal failed to match and the terminals expected there, or the error returned by the nibbler if it is
+// not io.EOF.  The characters of the stream are retained by a Mark for the duration of the parse, because any
+// of them may be read again, so a reader-backed nibbler holds in memory everything from the cursor up to the
+// furthest character examined.
+func (grammar *Grammar) Parse(nibbler UTF8Nibbler) (*ParseTreeNode, error) {
+	return grammar.ParseRule(grammar.StartRule(), nibbler)
+}
+
+// ParseRule does the same thing as Parse, but matches the rule named ruleName rather than the start rule.
+// Return ErrUndefinedGrammarRule if the grammar has no such rule.
+func (grammar *Grammar) ParseRule(ruleName string, nibbler UTF8Nibbler) (*ParseTreeNode, error) {
+	indexOfRule, isDefined := grammar.indexForRule[ruleName]
+	if !isDefined {
+		return nil, fmt.Errorf("%w: %s", ErrUndefinedGrammarRule, ruleName)
+	}
+
+	mark := nibbler.Mark()
+	defer nibbler.Release(mark)
+
+	parse := &pegParse{
+		grammar:                   grammar,
+		nibbler:                   nibbler,
+		characters:                make([]rune, 0, 256),
+		positionBeforeCharacter:   []Position{nibbler.Position()},
+		memoizedResults:           make(map[pegMemoKey]*pegMemoizedResult),
+		expectedAtFurthestFailure: make(map[string]bool),
+	}
+
+	endOffset, nodes, isMatched := parse.matchRule(indexOfRule, 0)
+
+	if resetErr := nibbler.ResetTo(mark); resetErr != nil {
+		return nil, resetErr
+	}
+
+	if parse.errorReturnedByTheNibbler != nil {
+		return nil, parse.errorReturnedByTheNibbler
+	}
+
+	if !isMatched {
+		expected := make([]string, 0, len(parse.expectedAtFurthestFailure))
+		for description := range parse.expectedAtFurthestFailure {
+			expected = append(expected, description)
+		}
+		sort.Strings(expected)
+
+		return nil, &ParseError{Position: parse.positionBeforeCharacter[parse.offsetOfFurthestFailure], Expected: expected}
+	}
+
+	for i := 0; i < endOffset; i++ {
+		if _, err := nibbler.ReadCharacter(); err != nil {
+			nibbler.ResetTo(mark)
+			return nil, err
+		}
+	}
+
+	return nodes[0], nil
+}
+
+// resolveRuleReferences sets the indexOfRule of each rule reference in expression.
+func (grammar *Grammar) resolveRuleReferences(expression *pegExpression) error {
+	if expression.kind == pegRuleReference {
+		indexOfRule, isDefined := grammar.indexForRule[expression.ruleName]
+		if !isDefined {
+			return fmt.Errorf("%w: %s", ErrUndefinedGrammarRule, expression.ruleName)
+		}
+
+		expression.indexOfRule = indexOfRule
+	}
+
+	for _, subexpression := range expression.subexpressions {
+		if err := grammar.resolveRuleReferences(subexpression); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkForLeftRecursion returns ErrLeftRecursiveGrammarRule if any rule can refer to itself, directly or
+// through other rules, before consuming input.  Whether each rule can match without consuming input is found
+// first, because a rule referred to after such a rule in a sequence is also referred to before input is
+// consumed.
+func (grammar *Grammar) checkForLeftRecursion() error {
+	ruleMatchesEmpty := make([]bool, len(grammar.rules))
+	for changed := true; changed; {
+		changed = false
+		for i, rule := range grammar.rules {
+			if !ruleMatchesEmpty[i] && pegExpressionMatchesEmpty(rule.expression, ruleMatchesEmpty) {
+				ruleMatchesEmpty[i] = true
+				changed = true
+			}
+		}
+	}
+
+	leftmostRules := make([][]int, len(grammar.rules))
+	for i, rule := range grammar.rules {
+		leftmostRules[i] = pegLeftmostRuleReferences(rule.expression, ruleMatchesEmpty, nil)
+	}
+
+	const (
+		unvisited = iota
+		onPath
+		finished
+	)
+
+	visitState := make([]int, len(grammar.rules))
+
+	var visit func(indexOfRule int) error
+	visit = func(indexOfRule int) error {
+		visitState[indexOfRule] = onPath
+		for _, indexOfReferredRule := range leftmostRules[indexOfRule] {
+			switch visitState[indexOfReferredRule] {
+			case onPath:
+				return fmt.Errorf("%w: %s", ErrLeftRecursiveGrammarRule, grammar.rules[indexOfReferredRule].name)
+			case unvisited:
+				if err := visit(indexOfReferredRule); err != nil {
+					return err
+				}
+			}
+		}
+		visitState[indexOfRule] = finished
+
+		return nil
+	}
+
+	for i := range grammar.rules {
+		if visitState[i] == unvisited {
+			if err := visit(i); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// pegExpressionMatchesEmpty returns true if expression can match without consuming input, given whether each
+// rule can.
+func pegExpressionMatchesEmpty(expression *pegExpression, ruleMatchesEmpty []bool) bool {
+	switch expression.kind {
+	case pegLiteral:
+		return len(expression.literalCharacters) == 0
+	case pegClass, pegAnyCharacter:
+		return false
+	case pegRuleReference:
+		return ruleMatchesEmpty[expression.indexOfRule]
+	case pegSequence:
+		for _, subexpression := range expression.subexpressions {
+			if !pegExpressionMatchesEmpty(subexpression, ruleMatchesEmpty) {
+				return false
+			}
+		}
+		return true
+	case pegChoice:
+		for _, subexpression := range expression.subexpressions {
+			if pegExpressionMatchesEmpty(subexpression, ruleMatchesEmpty) {
+				return true
+			}
+		}
+		return false
+	case pegOneOrMore:
+		return pegExpressionMatchesEmpty(expression.subexpressions[0], ruleMatchesEmpty)
+	default:
+		return true
+	}
+}
+
+// pegLeftmostRuleReferences appends to indexesOfRules the indexes of the rules that expression can refer to
+// before consuming input.
+func pegLeftmostRuleReferences(expression *pegExpression, ruleMatchesEmpty []bool, indexesOfRules []int) []int {
+	switch expression.kind {
+	case pegRuleReference:
+		return append(indexesOfRules, expression.indexOfRule)
+	case pegSequence:
+		for _, subexpression := range expression.subexpressions {
+			indexesOfRules = pegLeftmostRuleReferences(subexpression, ruleMatchesEmpty, indexesOfRules)
+			if !pegExpressionMatchesEmpty(subexpression, ruleMatchesEmpty) {
+				break
+			}
+		}
+	default:
+		for _, subexpression := range expression.subexpressions {
+			indexesOfRules = pegLeftmostRuleReferences(subexpression, ruleMatchesEmpty, indexesOfRules)
+		}
+	}
+
+	return indexesOfRules
+}
+
+// pegMemoKey identifies a memoized result.  A rule matched inside a ! predicate records no failures, so its
+// result is memoized separately from a match of the same rule outside of one.
+type pegMemoKey struct {
+	indexOfRule        int
+	offset             int
+	failuresAreIgnored bool
+}
+
+type pegMemoizedResult struct {
+	endOffset int
+	node      *ParseTreeNode
+	isMatched bool
+}
+
+// pegParse is the state of one parse by a Grammar.  Offsets are counts of characters from the cursor at the
+// start of the parse.  The characters are read from the nibbler as they are first needed, and are kept in
+// characters, so the nibbler cursor is always at the end of characters during the parse.
+type pegParse struct {
+	grammar                   *Grammar
+	nibbler                   UTF8Nibbler
+	characters                []rune
+	positionBeforeCharacter   []Position // has one more entry than characters
+	memoizedResults           map[pegMemoKey]*pegMemoizedResult
+	expectedAtFurthestFailure map[string]bool
+	offsetOfFurthestFailure   int
+	failuresAreIgnored        bool
+	streamEndsAfterCharacters bool
+	errorReturnedByTheNibbler error
+}
+
+// characterAt returns the character at offset, reading it from the nibbler if necessary.  The boolean is
+// false at the end of the stream, or if the nibbler returns an error, which is then held in
+// errorReturnedByTheNibbler.
+func (parse *pegParse) characterAt(offset int) (rune, bool) {
+	for offset >= len(parse.characters) {
+		if parse.streamEndsAfterCharacters || parse.errorReturnedByTheNibbler != nil {
+			return 0, false
+		}
+
+		nextCharacter, err := parse.nibbler.ReadCharacter()
+		if err != nil {
+			if err == io.EOF {
+				parse.streamEndsAfterCharacters = true
+			} else {
+				parse.errorReturnedByTheNibbler = err
+			}
+
+			return 0, false
+		}
+
+		parse.characters = append(parse.characters, nextCharacter)
+		parse.positionBeforeCharacter = append(parse.positionBeforeCharacter, parse.nibbler.Position())
+	}
+
+	return parse.characters[offset], true
+}
+
+// fail records that the terminal expression was expected at offset, and returns the values for a failed match.
+func (parse *pegParse) fail(expression *pegExpression, offset int) (int, []*ParseTreeNode, bool) {
+	if !parse.failuresAreIgnored {
+		if offset > parse.offsetOfFurthestFailure {
+			parse.offsetOfFurthestFailure = offset
+			parse.expectedAtFurthestFailure = make(map[string]bool)
+		}
+
+		if offset == parse.offsetOfFurthestFailure {
+			parse.expectedAtFurthestFailure[expression.descriptionOfMatch] = true
+		}
+	}
+
+	return offset, nil, false
+}
+
+// matchRule matches the rule with index indexOfRule at offset, using the memoized result if there is one.
+// If the rule matches, it returns the offset after the match and a slice holding the node for the match.
+func (parse *pegParse) matchRule(indexOfRule int, offset int) (int, []*ParseTreeNode, bool) {
+	key := pegMemoKey{indexOfRule: indexOfRule, offset: offset, failuresAreIgnored: parse.failuresAreIgnored}
+
+	result, isMemoized := parse.memoizedResults[key]
+	if !isMemoized {
+		rule := parse.grammar.rules[indexOfRule]
+		endOffset, children, isMatched := parse.match(rule.expression, offset)
+
+		result = &pegMemoizedResult{endOffset: endOffset, isMatched: isMatched}
+		if isMatched {
+			result.node = &ParseTreeNode{
+				Rule:     rule.name,
+				Text:     string(parse.characters[offset:endOffset]),
+				StartPos: parse.positionBeforeCharacter[offset],
+				EndPos:   parse.positionBeforeCharacter[endOffset],
+				Children: children,
+			}
+		}
+
+		parse.memoizedResults[key] = result
+	}
+
+	if !result.isMatched {
+		return offset, nil, false
+	}
+
+	return result.endOffset, []*ParseTreeNode{result.node}, true
+}
+
+// match matches expression at offset.  If it matches, it returns the offset after the match and the nodes for
+// the rules that it refers to.
+func (parse *pegParse) match(expression *pegExpression, offset int) (int, []*ParseTreeNode, bool) {
+	if parse.errorReturnedByTheNibbler != nil {
+		return offset, nil, false
+	}
+
+	switch expression.kind {
+	case pegLiteral:
+		for i, literalCharacter := range expression.literalCharacters {
+			if nextCharacter, isRead := parse.characterAt(offset + i); !isRead || nextCharacter != literalCharacter {
+				return parse.fail(expression, offset)
+			}
+		}
+		return offset + len(expression.literalCharacters), nil, true
+
+	case pegClass:
+		nextCharacter, isRead := parse.characterAt(offset)
+		if !isRead || pegClassContains(expression, nextCharacter) == expression.classIsNegated {
+			return parse.fail(expression, offset)
+		}
+		return offset + 1, nil, true
+
+	case pegAnyCharacter:
+		if _, isRead := parse.characterAt(offset); !isRead {
+			return parse.fail(expression, offset)
+		}
+		return offset + 1, nil, true
+
+	case pegRuleReference:
+		return parse.matchRule(expression.indexOfRule, offset)
+
+	case pegSequence:
+		endOffset := offset
+		var nodes []*ParseTreeNode
+		for _, subexpression := range expression.subexpressions {
+			var subexpressionNodes []*ParseTreeNode
+			var isMatched bool
+			if endOffset, subexpressionNodes, isMatched = parse.match(subexpression, endOffset); !isMatched {
+				return offset, nil, false
+			}
+			nodes = append(nodes, subexpressionNodes...)
+		}
+		return endOffset, nodes, true
+
+	case pegChoice:
+		for _, subexpression := range expression.subexpressions {
+			if endOffset, nodes, isMatched := parse.match(subexpression, offset); isMatched || parse.errorReturnedByTheNibbler != nil {
+				return endOffset, nodes, isMatched
+			}
+		}
+		return offset, nil, false
+
+	case pegZeroOrMore, pegOneOrMore:
+		endOffset := offset
+		var nodes []*ParseTreeNode
+		for countOfMatches := 0; ; countOfMatches++ {
+			nextOffset, subexpressionNodes, isMatched := parse.match(expression.subexpressions[0], endOffset)
+			if !isMatched {
+				if countOfMatches == 0 && expression.kind == pegOneOrMore {
+					return offset, nil, false
+				}
+				return endOffset, nodes, parse.errorReturnedByTheNibbler == nil
+			}
+
+			nodes = append(nodes, subexpressionNodes...)
+			if nextOffset == endOffset {
+				return endOffset, nodes, true
+			}
+			endOffset = nextOffset
+		}
+
+	case pegOptional:
+		if endOffset, nodes, isMatched := parse.match(expression.subexpressions[0], offset); isMatched {
+			return endOffset, nodes, true
+		}
+		return offset, nil, parse.errorReturnedByTheNibbler == nil
+
+	case pegAndPredicate:
+		_, _, isMatched := parse.match(expression.subexpressions[0], offset)
+		return offset, nil, isMatched
+
+	default: // pegNotPredicate
+		failuresWereIgnored := parse.failuresAreIgnored
+		parse.failuresAreIgnored = true
+
+		_, _, isMatched := parse.match(expression.subexpressions[0], offset)
+
+		parse.failuresAreIgnored = failuresWereIgnored
+
+		if !isMatched && parse.errorReturnedByTheNibbler == nil {
+			return offset, nil, true
+		}
+
+		if isMatched {
+			return parse.fail(expression, offset)
+		}
+
+		return offset, nil, false
+	}
+}
+
+func pegClassContains(expression *pegExpression, character rune) bool {
+	for _, characterRange := range expression.classRanges {
+		if character >= characterRange.low && character <= characterRange.high {
+			return true
+		}
+	}
+
+	return false
+}
+
+// newPEGGrammarTextParser returns a Parser for the text of a grammar, whose value is a []*pegRule.  A new
+// Parser is made for each grammar because a Deferred Parser is not safe for concurrent use.
+func newPEGGrammarTextParser() Parser {
+	spacing := Regexp(regexp.MustCompile(`(?:\s|#[^\n]*)*`))
+	token := func(parser Parser) Parser {
+		return Map(Sequence(parser, spacing), func(value interface{}) interface{} {
+			return value.([]interface{})[0]
+		})
+	}
+
+	identifier := Label("rule name", token(Regexp(regexp.MustCompile(`[A-Za-z_][A-Za-z0-9_]*`))))
+	leftArrow := token(Literal("<-"))
+
+	literal := Label("literal", token(Map(Regexp(regexp.MustCompile(`'(?:[^'\\]|\\[nrt\\'"\[\]^-])*'|"(?:[^"\\]|\\[nrt\\'"\[\]^-])*"`)), func(value interface{}) interface{} {
+		text := value.(string)
+		return &pegExpression{
+			kind:               pegLiteral,
+			literalCharacters:  pegUnescape(text[1 : len(text)-1]),
+			descriptionOfMatch: strconv.Quote(string(pegUnescape(text[1 : len(text)-1]))),
+		}
+	})))
+
+	classText := Regexp(regexp.MustCompile(`\[(?:[^\]\\]|\\[nrt\\'"\[\]^-])*\]`))
+	class := Label("character class", token(func(state *ParseState) (interface{}, bool) {
+		positionOfClass := state.Position()
+
+		value, parsed := classText(state)
+		if !parsed {
+			return nil, false
+		}
+
+		expression, err := newPEGClassExpression(value.(string))
+		if err != nil {
+			return state.Abort(fmt.Errorf("%w: %s", &ParseError{Position: positionOfClass}, err.Error()))
+		}
+
+		return expression, true
+	}))
+
+	anyCharacter := token(Map(Literal("."), func(interface{}) interface{} {
+		return &pegExpression{kind: pegAnyCharacter, descriptionOfMatch: "any character"}
+	}))
+
+	var expression Parser
+
+	ruleReference := Map(Sequence(identifier, Not(leftArrow)), func(value interface{}) interface{} {
+		return &pegExpression{kind: pegRuleReference, ruleName: value.([]interface{})[0].(string)}
+	})
+
+	parenthesized := Map(Sequence(token(Literal("(")), Deferred(func() Parser { return expression }), token(Literal(")"))), func(value interface{}) interface{} {
+		return value.([]interface{})[1]
+	})
+
+	primary := Choice(ruleReference, parenthesized, literal, class, anyCharacter)
+
+	kindForSuffix := map[string]pegExpressionKind{"*": pegZeroOrMore, "+": pegOneOrMore, "?": pegOptional}
+	suffixed := Map(Sequence(primary, Optional(token(Choice(Literal("*"), Literal("+"), Literal("?"))))), func(value interface{}) interface{} {
+		item, suffix := value.([]interface{})[0].(*pegExpression), value.([]interface{})[1]
+		if suffix == nil {
+			return item
+		}
+		return &pegExpression{kind: kindForSuffix[suffix.(string)], subexpressions: []*pegExpression{item}}
+	})
+
+	prefixed := Map(Sequence(Optional(token(Choice(Literal("&"), Literal("!")))), suffixed), func(value interface{}) interface{} {
+		prefix, item := value.([]interface{})[0], value.([]interface{})[1].(*pegExpression)
+		switch prefix {
+		case "&":
+			return &pegExpression{kind: pegAndPredicate, subexpressions: []*pegExpression{item}}
+		case "!":
+			descriptionOfMatch := "not " + pegDescribe(item)
+			if item.kind == pegAnyCharacter {
+				descriptionOfMatch = "end of input"
+			}
+			return &pegExpression{kind: pegNotPredicate, subexpressions: []*pegExpression{item}, descriptionOfMatch: descriptionOfMatch}
+		}
+		return item
+	})
+
+	sequence := Map(Many(prefixed), func(value interface{}) interface{} {
+		return newPEGCompoundExpression(pegSequence, value.([]interface{}))
+	})
+
+	expression = Map(Sequence(sequence, Many(Map(Sequence(token(Literal("/")), sequence), func(value interface{}) interface{} {
+		return value.([]interface{})[1]
+	}))), func(value interface{}) interface{} {
+		return newPEGCompoundExpression(pegChoice, append([]interface{}{value.([]interface{})[0]}, value.([]interface{})[1].([]interface{})...))
+	})
+
+	definition := Map(Sequence(identifier, leftArrow, expression), func(value interface{}) interface{} {
+		return &pegRule{name: value.([]interface{})[0].(string), expression: value.([]interface{})[2].(*pegExpression)}
+	})
+
+	return Map(Sequence(spacing, definition, Many(definition), EndOfInput()), func(value interface{}) interface{} {
+		rules := []*pegRule{value.([]interface{})[1].(*pegRule)}
+		for _, rule := range value.([]interface{})[2].([]interface{}) {
+			rules = append(rules, rule.(*pegRule))
+		}
+		return rules
+	})
+}
+
+// newPEGCompoundExpression returns a sequence or choice of items, or the item itself if there is only one.
+func newPEGCompoundExpression(kind pegExpressionKind, items []interface{}) *pegExpression {
+	if len(items) == 1 {
+		return items[0].(*pegExpression)
+	}
+
+	expression := &pegExpression{kind: kind, subexpressions: make([]*pegExpression, len(items))}
+	for i, item := range items {
+		expression.subexpressions[i] = item.(*pegExpression)
+	}
+
+	return expression
+}
+
+// newPEGClassExpression returns the expression for the text of a character class, including its brackets, or
+// an error if a range in the class is reversed.
+func newPEGClassExpression(classText string) (*pegExpression, error) {
+	expression := &pegExpression{kind: pegClass, descriptionOfMatch: classText}
+
+	classCharacters := []rune(classText[1 : len(classText)-1])
+	if len(classCharacters) > 0 && classCharacters[0] == '^' {
+		expression.classIsNegated = true
+		classCharacters = classCharacters[1:]
+	}
+
+	// escapes are decoded one character at a time, so that an escaped '-' is not taken as a range
+	var members []rune
+	var memberIsRangeDash []bool
+	for i := 0; i < len(classCharacters); i++ {
+		if classCharacters[i] == '\\' {
+			i++
+			members = append(members, pegUnescapeCharacter(classCharacters[i]))
+			memberIsRangeDash = append(memberIsRangeDash, false)
+		} else {
+			members = append(members, classCharacters[i])
+			memberIsRangeDash = append(memberIsRangeDash, classCharacters[i] == '-')
+		}
+	}
+
+	for i := 0; i < len(members); i++ {
+		if i+2 < len(members) && memberIsRangeDash[i+1] {
+			if members[i] > members[i+2] {
+				return nil, fmt.Errorf("character class range %q-%q is reversed", members[i], members[i+2])
+			}
+
+			expression.classRanges = append(expression.classRanges, pegCharacterRange{low: members[i], high: members[i+2]})
+			i += 2
+		} else {
+			expression.classRanges = append(expression.classRanges, pegCharacterRange{low: members[i], high: members[i]})
+		}
+	}
+
+	return expression, nil
+}
+
+// pegUnescape decodes the escapes in the text of a literal.
+func pegUnescape(text string) []rune {
+	characters := make([]rune, 0, len(text))
+
+	escapeIsPending := false
+	for _, character := range text {
+		switch {
+		case escapeIsPending:
+			characters = append(characters, pegUnescapeCharacter(character))
+			escapeIsPending = false
+		case character == '\\':
+			escapeIsPending = true
+		default:
+			characters = append(characters, character)
+		}
+	}
+
+	return characters
+}
+
+func pegUnescapeCharacter(escapedCharacter rune) rune {
+	switch escapedCharacter {
+	case 'n':
+		return '\n'
+	case 'r':
+		return '\r'
+	case 't':
+		return '\t'
+	default:
+		return escapedCharacter
+	}
+}
+
+// pegDescribe returns a description of expression for a ParseError.
+func pegDescribe(expression *pegExpression) string {
+	switch expression.kind {
+	case pegLiteral, pegClass, pegAnyCharacter, pegNotPredicate:
+		return expression.descriptionOfMatch
+	case pegRuleReference:
+		return expression.ruleName
+	default:
+		return "expression"
+	}
+}
@@ -0,0 +1,274 @@
+package nibblers_test
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	nibblers "github.com/blorticus-go/nibblers"
+	mock "github.com/blorticus/go-test-mocks"
+)
+
+const arithmeticPEG = `
+# a sum of products, with the usual precedence
+Expression       <- Spacing Sum !.
+Sum              <- Product (AddOperator Product)*
+Product          <- Value (MultiplyOperator Value)*
+Value            <- Number / '(' Spacing Sum ')' Spacing
+Number           <- [0-9]+ Spacing
+AddOperator      <- [+\-] Spacing
+MultiplyOperator <- [*/] Spacing
+Spacing          <- [ \t\n]*
+`
+
+// renderParseTree renders node as Rule[children] or, for a node without children, as Rule:"text".  Spacing
+// nodes are omitted.
+func renderParseTree(node *nibblers.ParseTreeNode) string {
+	renderedChildren := make([]string, 0, len(node.Children))
+	for _, child := range node.Children {
+		if child.Rule != "Spacing" {
+			renderedChildren = append(renderedChildren, renderParseTree(child))
+		}
+	}
+
+	if len(renderedChildren) == 0 {
+		return fmt.Sprintf("%s:%q", node.Rule, node.Text)
+	}
+
+	return fmt.Sprintf("%s[%s]", node.Rule, strings.Join(renderedChildren, " "))
+}
+
+func newReaderNibblerInPieces(s string, sizeOfPiece int) *nibblers.UTF8ReaderNibbler {
+	reader := mock.NewReader()
+	for i := 0; i < len(s); i += sizeOfPiece {
+		end := i + sizeOfPiece
+		if end > len(s) {
+			end = len(s)
+		}
+		reader.AddGoodRead([]byte(s[i:end]))
+	}
+
+	nibbler := nibblers.NewUTF8ReaderNibbler(reader.AddEOF())
+	nibbler.SetMaximumUnreadDepth(0)
+
+	return nibbler
+}
+
+func TestGrammarParse(t *testing.T) {
+	grammar, err := nibblers.NewGrammar(arithmeticPEG)
+	if err != nil {
+		t.Fatalf("(TestGrammarParse) on NewGrammar expected no error, got (%s)", err.Error())
+	}
+
+	if grammar.StartRule() != "Expression" {
+		t.Errorf("(TestGrammarParse) expected start rule (Expression), got (%s)", grammar.StartRule())
+	}
+
+	for testCaseIndex, testCase := range []struct {
+		input            string
+		expectedTree     string
+		expectedLine     int // of the ParseError, if one is expected
+		expectedColumn   int
+		expectedExpected []string
+	}{
+		{
+			input:        "7",
+			expectedTree: `Expression[Sum[Product[Value[Number:"7"]]]]`,
+		},
+		{
+			input:        " 1 + 2*(3 - 4)\n",
+			expectedTree: `Expression[Sum[Product[Value[Number:"1 "]] AddOperator:"+ " Product[Value[Number:"2"] MultiplyOperator:"*" Value[Sum[Product[Value[Number:"3 "]] AddOperator:"- " Product[Value[Number:"4"]]]]]]]`,
+		},
+		{
+			input:            "1 +\n  * 2",
+			expectedLine:     2,
+			expectedColumn:   3,
+			expectedExpected: []string{`"("`, `[ \t\n]`, `[0-9]`},
+		},
+		{
+			input:            "(1",
+			expectedLine:     1,
+			expectedColumn:   3,
+			expectedExpected: []string{`")"`, `[ \t\n]`, `[*/]`, `[+\-]`, `[0-9]`},
+		},
+		{
+			input:            "1 2",
+			expectedLine:     1,
+			expectedColumn:   3,
+			expectedExpected: []string{`[ \t\n]`, `[*/]`, `[+\-]`, "end of input"},
+		},
+	} {
+		for _, typeOfNibbler := range []string{"String", "Reader"} {
+			var nibbler nibblers.UTF8Nibbler = nibblers.NewUTF8StringNibbler(testCase.input)
+			if typeOfNibbler == "Reader" {
+				nibbler = newReaderNibblerInPieces(testCase.input, 2)
+			}
+
+			tree, err := grammar.Parse(nibbler)
+
+			if testCase.expectedExpected == nil {
+				if err != nil {
+					t.Errorf("(TestGrammarParse) (%s) (test case %d) expected no error, got (%s)", typeOfNibbler, testCaseIndex+1, err.Error())
+				} else if renderedTree := renderParseTree(tree); renderedTree != testCase.expectedTree {
+					t.Errorf("(TestGrammarParse) (%s) (test case %d) expected tree (%s), got (%s)", typeOfNibbler, testCaseIndex+1, testCase.expectedTree, renderedTree)
+				} else if tree.Text != testCase.input || tree.EndPos.RuneOffset != int64(len(testCase.input)) {
+					t.Errorf("(TestGrammarParse) (%s) (test case %d) expected root to span the input, got (%q) ending at (%d)", typeOfNibbler, testCaseIndex+1, tree.Text, tree.EndPos.RuneOffset)
+				}
+				continue
+			}
+
+			var parseError *nibblers.ParseError
+			if !errors.As(err, &parseError) {
+				t.Errorf("(TestGrammarParse) (%s) (test case %d) expected a *ParseError, got (%v)", typeOfNibbler, testCaseIndex+1, err)
+				continue
+			}
+
+			if parseError.Position.Line != testCase.expectedLine || parseError.Position.Column != testCase.expectedColumn {
+				t.Errorf("(TestGrammarParse) (%s) (test case %d) expected error at line (%d) column (%d), got (%s)", typeOfNibbler, testCaseIndex+1, testCase.expectedLine, testCase.expectedColumn, err.Error())
+			}
+
+			if err := compareSubmatches(testCase.expectedExpected, parseError.Expected); err != nil {
+				t.Errorf("(TestGrammarParse) (%s) (test case %d) on Expected %s", typeOfNibbler, testCaseIndex+1, err.Error())
+			}
+
+			if position := nibbler.Position(); position.RuneOffset != 0 {
+				t.Errorf("(TestGrammarParse) (%s) (test case %d) expected cursor at start after failed parse, got (%d)", typeOfNibbler, testCaseIndex+1, position.RuneOffset)
+			}
+		}
+	}
+}
+
+func TestGrammarParseRuleLeavesRestOfStream(t *testing.T) {
+	grammar, _ := nibblers.NewGrammar(arithmeticPEG)
+
+	nibbler := newReaderNibblerInPieces("2 * 3; rest", 3)
+	tree, err := grammar.ParseRule("Product", nibbler)
+	if err != nil || tree.Text != "2 * 3" || tree.StartPos.Column != 1 || tree.EndPos.Column != 6 {
+		t.Fatalf("(TestGrammarParseRuleLeavesRestOfStream) expected Product (2 * 3) in columns (1) to (6), got (%v) and (%v)", tree, err)
+	}
+
+	if nextCharacter, err := nibbler.ReadCharacter(); err != nil || nextCharacter != ';' {
+		t.Errorf("(TestGrammarParseRuleLeavesRestOfStream) expected next character (;), got (%c) and (%v)", nextCharacter, err)
+	}
+
+	if _, err := grammar.ParseRule("Quotient", nibbler); !errors.Is(err, nibblers.ErrUndefinedGrammarRule) {
+		t.Errorf("(TestGrammarParseRuleLeavesRestOfStream) on undefined rule expected ErrUndefinedGrammarRule, got (%v)", err)
+	}
+}
+
+func TestGrammarPredicatesAndClasses(t *testing.T) {
+	grammar, err := nibblers.NewGrammar(`
+		Statement  <- Keyword / Identifier / Quoted
+		Keyword    <- "if" !Letter
+		Identifier <- !Keyword &Letter [^ \]"]+
+		Quoted     <- '"' (!'"' .)* '"'
+		Letter     <- [a-zA-Zé]
+	`)
+	if err != nil {
+		t.Fatalf("(TestGrammarPredicatesAndClasses) on NewGrammar expected no error, got (%s)", err.Error())
+	}
+
+	for _, testCase := range []struct {
+		input        string
+		expectedTree string
+	}{
+		{input: "if", expectedTree: `Statement[Keyword:"if"]`},
+		{input: "iffé]", expectedTree: `Statement[Identifier:"iffé"]`},
+		{input: `"a\b"`, expectedTree: `Statement[Quoted:"\"a\\b\""]`},
+		{input: "7", expectedTree: ""},
+	} {
+		tree, err := grammar.Parse(nibblers.NewUTF8StringNibbler(testCase.input))
+		if testCase.expectedTree == "" {
+			if !errors.Is(err, nibblers.ErrParseFailed) {
+				t.Errorf("(TestGrammarPredicatesAndClasses) (%s) expected ErrParseFailed, got (%v)", testCase.input, err)
+			}
+		} else if err != nil {
+			t.Errorf("(TestGrammarPredicatesAndClasses) (%s) expected no error, got (%s)", testCase.input, err.Error())
+		} else if renderedTree := renderParseTree(tree); renderedTree != testCase.expectedTree {
+			t.Errorf("(TestGrammarPredicatesAndClasses) (%s) expected tree (%s), got (%s)", testCase.input, testCase.expectedTree, renderedTree)
+		}
+	}
+}
+
+func TestGrammarIsMemoized(t *testing.T) {
+	// without memoization, each level of nesting triples the work
+	grammar, err := nibblers.NewGrammar(`
+		Nested <- '(' Nested ')' 'x' / '(' Nested ')' 'y' / '(' Nested ')' / 'a'
+	`)
+	if err != nil {
+		t.Fatalf("(TestGrammarIsMemoized) on NewGrammar expected no error, got (%s)", err.Error())
+	}
+
+	input := strings.Repeat("(", 40) + "a" + strings.Repeat(")", 40)
+	tree, err := grammar.Parse(newReaderNibblerInPieces(input, 5))
+	if err != nil || tree.Text != input {
+		t.Errorf("(TestGrammarIsMemoized) expected whole input to be matched, got (%v)", err)
+	}
+}
+
+func TestNewGrammarErrors(t *testing.T) {
+	for _, testCase := range []struct {
+		grammarText   string
+		expectedError error
+	}{
+		{grammarText: `A <- "a" B`, expectedError: nibblers.ErrUndefinedGrammarRule},
+		{grammarText: "A <- 'a'\nA <- 'b'", expectedError: nibblers.ErrDuplicateGrammarRule},
+		{grammarText: `A <- A 'a' / 'a'`, expectedError: nibblers.ErrLeftRecursiveGrammarRule},
+		{grammarText: "A <- B 'a'\nB <- 'b'* A", expectedError: nibblers.ErrLeftRecursiveGrammarRule},
+		{grammarText: "A <- 'a' A / 'a'\nB <- &A B? 'b'", expectedError: nibblers.ErrLeftRecursiveGrammarRule},
+		{grammarText: `A <- 'a' A / 'a'`, expectedError: nil},
+		{grammarText: `A <- ('a'`, expectedError: nibblers.ErrParseFailed},
+		{grammarText: `A <- 'a\q'`, expectedError: nibblers.ErrParseFailed},
+		{grammarText: ``, expectedError: nibblers.ErrParseFailed},
+		{grammarText: `A <- [z-a]`, expectedError: nibblers.ErrParseFailed},
+		{grammarText: `A <- [a-a\--\-]`, expectedError: nil},
+	} {
+		_, err := nibblers.NewGrammar(testCase.grammarText)
+		if !errors.Is(err, testCase.expectedError) || (testCase.expectedError == nil && err != nil) {
+			t.Errorf("(TestNewGrammarErrors) (%q) expected error (%v), got (%v)", testCase.grammarText, testCase.expectedError, err)
+		}
+	}
+
+	_, err := nibblers.NewGrammar("A <- 'a' B\nB <- 'b' [a-cz-a]")
+	var parseError *nibblers.ParseError
+	if !errors.As(err, &parseError) {
+		t.Errorf("(TestNewGrammarErrors) (reversed class range) expected a *ParseError, got (%v)", err)
+	} else if parseError.Position.Line != 2 || parseError.Position.Column != 10 {
+		t.Errorf("(TestNewGrammarErrors) (reversed class range) expected error at line (2) column (10), got (%s)", err.Error())
+	}
+}
+
+// nibblerFailingAfterReset is a UTF8Nibbler whose reads fail once it has been reset to a Mark.
+type nibblerFailingAfterReset struct {
+	nibblers.UTF8Nibbler
+	hasBeenReset bool
+}
+
+var errReadAfterReset = errors.New("read after reset")
+
+func (nibbler *nibblerFailingAfterReset) ReadCharacter() (rune, error) {
+	if nibbler.hasBeenReset {
+		return 0, errReadAfterReset
+	}
+
+	return nibbler.UTF8Nibbler.ReadCharacter()
+}
+
+func (nibbler *nibblerFailingAfterReset) ResetTo(mark nibblers.Mark) error {
+	nibbler.hasBeenReset = true
+	return nibbler.UTF8Nibbler.ResetTo(mark)
+}
+
+func TestGrammarParseWithFailingReadPastMatch(t *testing.T) {
+	grammar, _ := nibblers.NewGrammar(arithmeticPEG)
+
+	nibbler := &nibblerFailingAfterReset{UTF8Nibbler: nibblers.NewUTF8StringNibbler("1+2")}
+	if tree, err := grammar.Parse(nibbler); !errors.Is(err, errReadAfterReset) {
+		t.Errorf("(TestGrammarParseWithFailingReadPastMatch) expected errReadAfterReset, got (%v) and (%v)", tree, err)
+	}
+
+	if position := nibbler.Position(); position.RuneOffset != 0 {
+		t.Errorf("(TestGrammarParseWithFailingReadPastMatch) expected cursor at start after failed parse, got (%d)", position.RuneOffset)
+	}
+}
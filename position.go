@@ -0,0 +1,139 @@
+package nibblers
+
+import "sort"
+
+// Position describes the location of a nibbler cursor in its stream.  ByteOffset and RuneOffset
+// are zero-based counts of the bytes and characters before the cursor.  Line and Column are one-based,
+// and identify the line and column of the next unread character.
+type Position struct {
+	ByteOffset int64
+	RuneOffset int64
+	Line       int
+	Column     int
+}
+
+// LineBreakConvention determines which characters a nibbler treats as the end of a line when
+// computing a Position.
+type LineBreakConvention int
+
+const (
+	// LineBreaksAtLF treats only '\n' as the end of a line.  A '\r' occupies a column like any other
+	// character, so "\r\n" ends a line at the '\n'.
+	LineBreaksAtLF LineBreakConvention = iota
+
+	// LineBreaksAtLFOrCR treats '\n', '\r' and the pair "\r\n" as the end of a line.  The pair "\r\n"
+	// counts as a single line break.
+	LineBreaksAtLFOrCR
+)
+
+// PositionTrackingOptions controls how a nibbler computes the Line and Column of a Position.  TabWidth
+// is the distance between tab stops, so a tab advances the column to one past the next multiple of
+// TabWidth.  A TabWidth less than 2 means that a tab occupies a single column.  The zero value uses
+// LineBreaksAtLF and single column tabs.
+type PositionTrackingOptions struct {
+	LineBreaks LineBreakConvention
+	TabWidth   int
+}
+
+// positionEvent records the position immediately after a character that does not simply advance
+// the column by one (that is, a line break or a tab).
+type positionEvent struct {
+	byteOffset int64
+	runeOffset int64
+	line       int
+	column     int
+}
+
+// positionTracker computes positions for a UTF8 nibbler.  Every character that does not advance the
+// column by exactly one produces a positionEvent, so the position at any offset is the nearest preceding
+// event plus the count of characters since that event.  Characters are recorded only the first time that
+// the cursor moves past them, which keeps positions correct when characters are unread and re-read.
+type positionTracker struct {
+	options                     PositionTrackingOptions
+	events                      []positionEvent
+	byteOffsetAfterLastRecorded int64
+	lastRecordedCharacter       rune
+}
+
+func newPositionTracker() *positionTracker {
+	return &positionTracker{
+		events: []positionEvent{{byteOffset: 0, runeOffset: 0, line: 1, column: 1}},
+	}
+}
+
+func (tracker *positionTracker) setOptions(options PositionTrackingOptions) {
+	tracker.options = options
+}
+
+// recordCharacter notes that the character r, which is sizeInBytes long, starts at the provided
+// offsets and has been read.  If the character was previously recorded, this does nothing.
+func (tracker *positionTracker) recordCharacter(r rune, byteOffset int64, runeOffset int64, sizeInBytes int) {
+	if byteOffset < tracker.byteOffsetAfterLastRecorded {
+		return
+	}
+
+	// no event can follow an unrecorded character, so the last event precedes this character
+	lastEvent := tracker.events[len(tracker.events)-1]
+	columnBeforeCharacter := lastEvent.column + int(runeOffset-lastEvent.runeOffset)
+
+	eventAfterCharacter := positionEvent{
+		byteOffset: byteOffset + int64(sizeInBytes),
+		runeOffset: runeOffset + 1,
+		line:       lastEvent.line,
+		column:     columnBeforeCharacter,
+	}
+
+	switch {
+	case r == '\n' && tracker.options.LineBreaks == LineBreaksAtLFOrCR && tracker.lastRecordedCharacter == '\r':
+		// the line break was already counted at the '\r'
+		tracker.events = append(tracker.events, eventAfterCharacter)
+
+	case r == '\n' || (r == '\r' && tracker.options.LineBreaks == LineBreaksAtLFOrCR):
+		eventAfterCharacter.line++
+		eventAfterCharacter.column = 1
+		tracker.events = append(tracker.events, eventAfterCharacter)
+
+	case r == '\t' && tracker.options.TabWidth > 1:
+		eventAfterCharacter.column = ((columnBeforeCharacter-1)/tracker.options.TabWidth+1)*tracker.options.TabWidth + 1
+		tracker.events = append(tracker.events, eventAfterCharacter)
+	}
+
+	tracker.byteOffsetAfterLastRecorded = byteOffset + int64(sizeInBytes)
+	tracker.lastRecordedCharacter = r
+}
+
+// positionAt returns the Position for a cursor at the provided offsets, which must not be beyond the
+// last recorded character.
+func (tracker *positionTracker) positionAt(byteOffset int64, runeOffset int64) Position {
+	indexOfEvent := sort.Search(len(tracker.events), func(i int) bool {
+		return tracker.events[i].byteOffset > byteOffset
+	}) - 1
+
+	if indexOfEvent < 0 {
+		indexOfEvent = 0
+	}
+
+	event := tracker.events[indexOfEvent]
+
+	return Position{
+		ByteOffset: byteOffset,
+		RuneOffset: runeOffset,
+		Line:       event.line,
+		Column:     event.column + int(runeOffset-event.runeOffset),
+	}
+}
+
+// discardEventsBefore removes events that can no longer be reached because the cursor cannot move
+// before byteOffset.  The last event at or before byteOffset is retained.
+func (tracker *positionTracker) discardEventsBefore(byteOffset int64) {
+	indexOfEvent := sort.Search(len(tracker.events), func(i int) bool {
+		return tracker.events[i].byteOffset > byteOffset
+	}) - 1
+
+	if indexOfEvent <= 0 {
+		return
+	}
+
+	countOfRetainedEvents := copy(tracker.events, tracker.events[indexOfEvent:])
+	tracker.events = tracker.events[:countOfRetainedEvents]
+}
@@ -0,0 +1,283 @@
+package nibblers
+
+import (
+	"io"
+	"regexp"
+	"regexp/syntax"
+	"unicode/utf8"
+)
+
+// RegexpSyntax is how a regexp given to a matcher was compiled.  The regexp package does not report this, and
+// a matcher needs it to anchor the regexp at the cursor without changing what the regexp matches.
+type RegexpSyntax int
+
+const (
+	// PerlRegexpSyntax is the syntax of a regexp compiled by regexp.Compile, which prefers the leftmost-first
+	// match.
+	PerlRegexpSyntax RegexpSyntax = iota
+
+	// PerlRegexpSyntaxWithLongestMatch is the syntax of a regexp compiled by regexp.Compile on which Longest
+	// was called, which prefers the leftmost-longest match.
+	PerlRegexpSyntaxWithLongestMatch
+
+	// POSIXRegexpSyntax is the syntax of a regexp compiled by regexp.CompilePOSIX, which prefers the
+	// leftmost-longest match.
+	POSIXRegexpSyntax
+)
+
+// maximumCountOfCachedRegexps bounds the size of a regexpCache, so that a matcher given a new regexp on each
+// call does not grow without bound.
+const maximumCountOfCachedRegexps = 32
+
+type regexpCacheKey struct {
+	re             *regexp.Regexp
+	syntaxOfRegexp RegexpSyntax
+}
+
+// regexpCache holds, for recently used regexps, the equivalent regexp that only matches at the start of its
+// input.  When it is full, it is emptied before the next regexp is added.
+type regexpCache map[regexpCacheKey]*regexp.Regexp
+
+// anchored returns the anchored equivalent of re, compiling it if it is not in the cache.
+func (cache regexpCache) anchored(re *regexp.Regexp, syntaxOfRegexp RegexpSyntax) *regexp.Regexp {
+	key := regexpCacheKey{re: re, syntaxOfRegexp: syntaxOfRegexp}
+	if anchored, isCached := cache[key]; isCached {
+		return anchored
+	}
+
+	if len(cache) >= maximumCountOfCachedRegexps {
+		for cachedKey := range cache {
+			delete(cache, cachedKey)
+		}
+	}
+
+	anchored := anchoredRegexp(re, syntaxOfRegexp)
+	cache[key] = anchored
+
+	return anchored
+}
+
+// anchoredRegexp returns a regexp equivalent to re, which was compiled with syntaxOfRegexp, that only matches
+// at the start of its input.  re is parsed again with the flags of its syntax, and the parsed form, in which
+// those flags are explicit, is compiled after \A.  \A rather than ^ is used so that a (?m) flag in re does not
+// allow a match at the start of a later line.  It panics if re is not valid in syntaxOfRegexp.
+func anchoredRegexp(re *regexp.Regexp, syntaxOfRegexp RegexpSyntax) *regexp.Regexp {
+	flags := syntax.Perl
+	if syntaxOfRegexp == POSIXRegexpSyntax {
+		flags = syntax.POSIX
+	}
+
+	parsedRegexp, err := syntax.Parse(re.String(), flags)
+	if err != nil {
+		panic("nibblers: regexp `" + re.String() + "` does not have the stated syntax: " + err.Error())
+	}
+
+	anchored := regexp.MustCompile(`\A(?:` + parsedRegexp.String() + `)`)
+	if syntaxOfRegexp != PerlRegexpSyntax {
+		anchored.Longest()
+	}
+
+	return anchored
+}
+
+// characterRecordingRuneReader is an io.RuneReader over a UTF8Nibbler that records the UTF8 encoding of
+// every character that it reads, so that the byte offsets of a regexp match can be mapped back to
+// characters.  An error other than io.EOF is held in err, and io.EOF is returned to the regexp in its place.
+type characterRecordingRuneReader struct {
+	nibbler          UTF8Nibbler
+	recordedEncoding []byte
+	reachedEOF       bool
+	err              error
+}
+
+func (reader *characterRecordingRuneReader) ReadRune() (rune, int, error) {
+	nextCharacter, err := reader.nibbler.ReadCharacter()
+	if err != nil {
+		if err == io.EOF {
+			reader.reachedEOF = true
+		} else {
+			reader.err = err
+		}
+
+		return utf8.RuneError, 0, io.EOF
+	}
+
+	var encoding [utf8.UTFMax]byte
+	sizeOfEncoding := utf8.EncodeRune(encoding[:], nextCharacter)
+	reader.recordedEncoding = append(reader.recordedEncoding, encoding[:sizeOfEncoding]...)
+
+	return nextCharacter, sizeOfEncoding, nil
+}
+
+// byteRecordingRuneReader is an io.RuneReader over a ByteNibbler that decodes the bytes that it reads as
+// UTF8, as a regexp does for a []byte, and records them.  Each byte that is not part of a valid encoding is
+// returned as utf8.RuneError with a size of one, so the byte offsets of a regexp match are offsets in the
+// recorded bytes.  An error other than io.EOF is held in err, and io.EOF is returned to the regexp in its
+// place.
+type byteRecordingRuneReader struct {
+	nibbler       ByteNibbler
+	recordedBytes []byte
+	reachedEOF    bool
+	err           error
+}
+
+func (reader *byteRecordingRuneReader) ReadRune() (rune, int, error) {
+	firstByte, err := reader.nibbler.ReadByte()
+	if err != nil {
+		return reader.stopOn(err)
+	}
+
+	if firstByte < utf8.RuneSelf {
+		reader.recordedBytes = append(reader.recordedBytes, firstByte)
+		return rune(firstByte), 1, nil
+	}
+
+	// peek at only as many bytes as the encoding needs, since a reader-backed nibbler waits for each of them
+	encoding := []byte{firstByte}
+	for !utf8.FullRune(encoding) {
+		followingBytes, err := reader.nibbler.PeekAtNextBytes(uint(len(encoding)))
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return reader.stopOn(err)
+		}
+
+		encoding = append(encoding[:1], followingBytes...)
+	}
+
+	nextRune, sizeOfRune := utf8.DecodeRune(encoding)
+	reader.recordedBytes = append(reader.recordedBytes, firstByte)
+	for _, followingByte := range encoding[1:sizeOfRune] {
+		if _, err := reader.nibbler.ReadByte(); err != nil {
+			return reader.stopOn(err)
+		}
+		reader.recordedBytes = append(reader.recordedBytes, followingByte)
+	}
+
+	return nextRune, sizeOfRune, nil
+}
+
+func (reader *byteRecordingRuneReader) stopOn(err error) (rune, int, error) {
+	if err == io.EOF {
+		reader.reachedEOF = true
+	} else {
+		reader.err = err
+	}
+
+	return utf8.RuneError, 0, io.EOF
+}
+
+// ReadMatchingRegexp matches re, which must have been compiled by regexp.Compile, against the characters at
+// the cursor.  The match must start at the cursor, as if re began with \A; among such matches, re chooses in
+// the usual way (leftmost-first, so an alternation prefers its earlier branches).  If re matches, the cursor
+// is advanced past exactly the matched characters, even though the regexp may have read further, and the
+// match and its submatches are returned, as by regexp.FindStringSubmatch.  A submatch that did not
+// participate in the match is the empty string.  If re does not match, nil is returned and the cursor is not
+// moved, and if, in addition, the cursor is at the end of the stream, io.EOF is returned.  If any other error
+// occurs, it is returned and the cursor is not moved.
+func (matcher *UTF8NibblerMatcher) ReadMatchingRegexp(re *regexp.Regexp) ([]string, error) {
+	return matcher.ReadMatchingRegexpWithSyntax(re, PerlRegexpSyntax)
+}
+
+// ReadMatchingRegexpWithSyntax does the same thing as ReadMatchingRegexp, for a regexp that was compiled with
+// syntaxOfRegexp, which determines what the regexp matches and which match it prefers.
+func (matcher *UTF8NibblerMatcher) ReadMatchingRegexpWithSyntax(re *regexp.Regexp, syntaxOfRegexp RegexpSyntax) ([]string, error) {
+	return matcher.readMatchingAnchoredRegexp(matcher.anchoredRegexps.anchored(re, syntaxOfRegexp))
+}
+
+// readMatchingAnchoredRegexp is ReadMatchingRegexp for a regexp that was returned by anchoredRegexp.
+func (matcher *UTF8NibblerMatcher) readMatchingAnchoredRegexp(anchored *regexp.Regexp) ([]string, error) {
+	mark := matcher.nibbler.Mark()
+	defer matcher.nibbler.Release(mark)
+
+	reader := &characterRecordingRuneReader{nibbler: matcher.nibbler, recordedEncoding: make([]byte, 0, 32)}
+	matchIndices := anchored.FindReaderSubmatchIndex(reader)
+
+	matcher.nibbler.ResetTo(mark)
+
+	if reader.err != nil {
+		return nil, reader.err
+	}
+
+	if matchIndices == nil {
+		if reader.reachedEOF && len(reader.recordedEncoding) == 0 {
+			return nil, io.EOF
+		}
+
+		return nil, nil
+	}
+
+	for countOfMatchedCharacters := utf8.RuneCount(reader.recordedEncoding[:matchIndices[1]]); countOfMatchedCharacters > 0; countOfMatchedCharacters-- {
+		if _, err := matcher.nibbler.ReadCharacter(); err != nil {
+			matcher.nibbler.ResetTo(mark)
+			return nil, err
+		}
+	}
+
+	submatches := make([]string, len(matchIndices)/2)
+	for i := range submatches {
+		if matchIndices[2*i] >= 0 {
+			submatches[i] = string(reader.recordedEncoding[matchIndices[2*i]:matchIndices[2*i+1]])
+		}
+	}
+
+	return submatches, nil
+}
+
+// ReadMatchingRegexp matches re, which must have been compiled by regexp.Compile, against the bytes at the
+// cursor, which are interpreted as UTF8 in the same way as by regexp.FindSubmatch.  The match must start at the cursor, as if re began with \A.  If re matches,
+// the cursor is advanced past exactly the matched bytes, even though the regexp may have read further, and the
+// match and its submatches are returned, as by regexp.FindSubmatch.  A submatch that did not participate in
+// the match is nil.  If re does not match, nil is returned and the cursor is not moved, and if, in addition,
+// the cursor is at the end of the stream, io.EOF is returned.  If any other error occurs, it is returned and
+// the cursor is not moved.
+func (matcher *ByteNibblerMatcher) ReadMatchingRegexp(re *regexp.Regexp) ([][]byte, error) {
+	return matcher.ReadMatchingRegexpWithSyntax(re, PerlRegexpSyntax)
+}
+
+// ReadMatchingRegexpWithSyntax does the same thing as ReadMatchingRegexp, for a regexp that was compiled with
+// syntaxOfRegexp, which determines what the regexp matches and which match it prefers.
+func (matcher *ByteNibblerMatcher) ReadMatchingRegexpWithSyntax(re *regexp.Regexp, syntaxOfRegexp RegexpSyntax) ([][]byte, error) {
+	return matcher.readMatchingAnchoredRegexp(matcher.anchoredRegexps.anchored(re, syntaxOfRegexp))
+}
+
+// readMatchingAnchoredRegexp is ReadMatchingRegexp for a regexp that was returned by anchoredRegexp.
+func (matcher *ByteNibblerMatcher) readMatchingAnchoredRegexp(anchored *regexp.Regexp) ([][]byte, error) {
+	mark := matcher.nibbler.Mark()
+	defer matcher.nibbler.Release(mark)
+
+	reader := &byteRecordingRuneReader{nibbler: matcher.nibbler, recordedBytes: make([]byte, 0, 32)}
+	matchIndices := anchored.FindReaderSubmatchIndex(reader)
+
+	matcher.nibbler.ResetTo(mark)
+
+	if reader.err != nil {
+		return nil, reader.err
+	}
+
+	if matchIndices == nil {
+		if reader.reachedEOF && len(reader.recordedBytes) == 0 {
+			return nil, io.EOF
+		}
+
+		return nil, nil
+	}
+
+	for countOfMatchedBytes := matchIndices[1]; countOfMatchedBytes > 0; countOfMatchedBytes-- {
+		if _, err := matcher.nibbler.ReadByte(); err != nil {
+			matcher.nibbler.ResetTo(mark)
+			return nil, err
+		}
+	}
+
+	submatches := make([][]byte, len(matchIndices)/2)
+	for i := range submatches {
+		if matchIndices[2*i] >= 0 {
+			submatches[i] = reader.recordedBytes[matchIndices[2*i]:matchIndices[2*i+1]:matchIndices[2*i+1]]
+		}
+	}
+
+	return submatches, nil
+}
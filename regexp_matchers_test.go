@@ -0,0 +1,229 @@
+package nibblers_test
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"testing"
+	"time"
+
+	nibblers "github.com/blorticus-go/nibblers"
+	mock "github.com/blorticus/go-test-mocks"
+)
+
+type regexpMatchingTestStep struct {
+	re                 *regexp.Regexp
+	expectedSubmatches []string // nil if no match is expected
+	expectedError      error
+}
+
+func compareSubmatches(expected []string, got []string) error {
+	if (expected == nil) != (got == nil) || len(expected) != len(got) {
+		return fmt.Errorf("expected submatches (%q), got (%q)", expected, got)
+	}
+
+	for i := range expected {
+		if expected[i] != got[i] {
+			return fmt.Errorf("expected submatches (%q), got (%q)", expected, got)
+		}
+	}
+
+	return nil
+}
+
+func TestReadMatchingRegexp(t *testing.T) {
+	number := regexp.MustCompile(`([0-9]+)(\.[0-9]+)?([eE][+-]?[0-9]+)?`)
+	identifier := regexp.MustCompile(`\pL[\pL0-9_]*`)
+	space := regexp.MustCompile(`\s+`)
+	operator := regexp.MustCompile(`<=|<|=`)
+	lineStart := regexp.MustCompile(`(?m)^x`)
+
+	s := "12.5e+3 3 naïve_1 <= 42x"
+
+	steps := []*regexpMatchingTestStep{
+		{re: identifier, expectedSubmatches: nil},
+		{re: number, expectedSubmatches: []string{"12.5e+3", "12", ".5", "e+3"}},
+		{re: space, expectedSubmatches: []string{" "}},
+		{re: number, expectedSubmatches: []string{"3", "3", "", ""}},
+		{re: space, expectedSubmatches: []string{" "}},
+		{re: number, expectedSubmatches: nil},
+		{re: identifier, expectedSubmatches: []string{"naïve_1"}},
+		{re: space, expectedSubmatches: []string{" "}},
+		{re: operator, expectedSubmatches: []string{"<="}},
+		{re: space, expectedSubmatches: []string{" "}},
+		{re: lineStart, expectedSubmatches: nil},
+		{re: number, expectedSubmatches: []string{"42", "42", "", ""}},
+		{re: identifier, expectedSubmatches: []string{"x"}},
+		{re: space, expectedSubmatches: nil, expectedError: io.EOF},
+	}
+
+	for _, typeOfNibbler := range []string{"UTF8String", "UTF8Reader", "ByteSlice", "ByteReader"} {
+		reader := mock.NewReader()
+		for i := 0; i < len(s); i += 3 {
+			end := i + 3
+			if end > len(s) {
+				end = len(s)
+			}
+			reader.AddGoodRead([]byte(s[i:end]))
+		}
+		reader.AddEOF()
+
+		var readMatchingRegexp func(re *regexp.Regexp) ([]string, error)
+
+		switch typeOfNibbler {
+		case "UTF8String", "UTF8Reader":
+			var nibbler nibblers.UTF8Nibbler = nibblers.NewUTF8StringNibbler(s)
+			if typeOfNibbler == "UTF8Reader" {
+				nibbler = nibblers.NewUTF8ReaderNibbler(reader)
+			}
+
+			matcher := nibblers.NewUTF8NibblerMatcher(nibbler)
+			readMatchingRegexp = matcher.ReadMatchingRegexp
+
+		case "ByteSlice", "ByteReader":
+			var nibbler nibblers.ByteNibbler = nibblers.NewByteSliceNibbler([]byte(s))
+			if typeOfNibbler == "ByteReader" {
+				nibbler = nibblers.NewByteReaderNibbler(reader)
+			}
+
+			matcher := nibblers.NewByteNibblerMatcher(nibbler)
+			readMatchingRegexp = func(re *regexp.Regexp) ([]string, error) {
+				byteSubmatches, err := matcher.ReadMatchingRegexp(re)
+				if byteSubmatches == nil {
+					return nil, err
+				}
+
+				submatches := make([]string, len(byteSubmatches))
+				for i := range byteSubmatches {
+					submatches[i] = string(byteSubmatches[i])
+				}
+
+				return submatches, err
+			}
+		}
+
+		for stepIndex, step := range steps {
+			submatches, err := readMatchingRegexp(step.re)
+			if err != step.expectedError {
+				t.Errorf("(TestReadMatchingRegexp) (%s) (step %d: %s) expected error (%v), got (%v)", typeOfNibbler, stepIndex+1, step.re, step.expectedError, err)
+			}
+
+			if err := compareSubmatches(step.expectedSubmatches, submatches); err != nil {
+				t.Errorf("(TestReadMatchingRegexp) (%s) (step %d: %s) %s", typeOfNibbler, stepIndex+1, step.re, err.Error())
+			}
+		}
+	}
+}
+
+func TestReadMatchingRegexpLeavesRestOfStreamUnread(t *testing.T) {
+	nibbler := nibblers.NewByteReaderNibbler(mock.NewReader().AddGoodRead([]byte("GET /a")).AddGoodRead([]byte("bc HTTP/1.1\r\n")).AddEOF())
+	matcher := nibblers.NewByteNibblerMatcher(nibbler)
+
+	if submatches, err := matcher.ReadMatchingRegexp(regexp.MustCompile(`([A-Z]+) (\S*)`)); err != nil || len(submatches) != 3 || string(submatches[2]) != "/abc" {
+		t.Errorf("(TestReadMatchingRegexpLeavesRestOfStreamUnread) expected match with path (/abc), got (%q) and (%v)", submatches, err)
+	}
+
+	if nextByte, err := nibbler.ReadByte(); err != nil || nextByte != ' ' {
+		t.Errorf("(TestReadMatchingRegexpLeavesRestOfStreamUnread) expected next byte ( ), got (%c) and (%v)", nextByte, err)
+	}
+
+	if submatches, err := matcher.ReadMatchingRegexp(regexp.MustCompile(`.*`)); err != nil || string(submatches[0]) != "HTTP/1.1\r" {
+		t.Errorf("(TestReadMatchingRegexpLeavesRestOfStreamUnread) expected match (HTTP/1.1\\r), got (%q) and (%v)", submatches, err)
+	}
+
+	if nextByte, err := nibbler.ReadByte(); err != nil || nextByte != '\n' {
+		t.Errorf("(TestReadMatchingRegexpLeavesRestOfStreamUnread) expected next byte (\\n), got (%c) and (%v)", nextByte, err)
+	}
+}
+
+func TestReadMatchingRegexpWithSyntax(t *testing.T) {
+	longest := regexp.MustCompile(`a|ab`)
+	longest.Longest()
+
+	for _, testCase := range []struct {
+		name           string
+		re             *regexp.Regexp
+		syntaxOfRegexp nibblers.RegexpSyntax
+		input          string
+		expectedMatch  string // empty if no match is expected
+	}{
+		{name: "Perl alternation", re: regexp.MustCompile(`a|ab`), syntaxOfRegexp: nibblers.PerlRegexpSyntax, input: "abc", expectedMatch: "a"},
+		{name: "Longest alternation", re: longest, syntaxOfRegexp: nibblers.PerlRegexpSyntaxWithLongestMatch, input: "abc", expectedMatch: "ab"},
+		{name: "POSIX alternation", re: regexp.MustCompilePOSIX(`a|ab`), syntaxOfRegexp: nibblers.POSIXRegexpSyntax, input: "abc", expectedMatch: "ab"},
+		{name: "Perl negated class", re: regexp.MustCompile(`[^x]+`), syntaxOfRegexp: nibblers.PerlRegexpSyntax, input: "ab\ncd", expectedMatch: "ab\ncd"},
+		{name: "POSIX negated class", re: regexp.MustCompilePOSIX(`[^x]+`), syntaxOfRegexp: nibblers.POSIXRegexpSyntax, input: "ab\ncd", expectedMatch: "ab"},
+		{name: "Perl $", re: regexp.MustCompile(`a$`), syntaxOfRegexp: nibblers.PerlRegexpSyntax, input: "a\nb", expectedMatch: ""},
+		{name: "POSIX $", re: regexp.MustCompilePOSIX(`a$`), syntaxOfRegexp: nibblers.POSIXRegexpSyntax, input: "a\nb", expectedMatch: "a"},
+		{name: "POSIX $ at end", re: regexp.MustCompilePOSIX(`a$`), syntaxOfRegexp: nibblers.POSIXRegexpSyntax, input: "a", expectedMatch: "a"},
+	} {
+		if directMatch := testCase.re.FindString(testCase.input); directMatch != testCase.expectedMatch {
+			t.Fatalf("(TestReadMatchingRegexpWithSyntax) (%s) test case expects match (%q), but the regexp matches (%q)", testCase.name, testCase.expectedMatch, directMatch)
+		}
+
+		utf8Submatches, err := nibblers.NewUTF8NibblerMatcher(nibblers.NewUTF8StringNibbler(testCase.input)).ReadMatchingRegexpWithSyntax(testCase.re, testCase.syntaxOfRegexp)
+		if err != nil || len(utf8Submatches) != 0 && utf8Submatches[0] != testCase.expectedMatch || len(utf8Submatches) == 0 && testCase.expectedMatch != "" {
+			t.Errorf("(TestReadMatchingRegexpWithSyntax) (%s) (UTF8) expected match (%q), got (%q) and (%v)", testCase.name, testCase.expectedMatch, utf8Submatches, err)
+		}
+
+		byteSubmatches, err := nibblers.NewByteNibblerMatcher(nibblers.NewByteSliceNibbler([]byte(testCase.input))).ReadMatchingRegexpWithSyntax(testCase.re, testCase.syntaxOfRegexp)
+		if err != nil || len(byteSubmatches) != 0 && string(byteSubmatches[0]) != testCase.expectedMatch || len(byteSubmatches) == 0 && testCase.expectedMatch != "" {
+			t.Errorf("(TestReadMatchingRegexpWithSyntax) (%s) (Byte) expected match (%q), got (%q) and (%v)", testCase.name, testCase.expectedMatch, byteSubmatches, err)
+		}
+
+		value, err := nibblers.ParseUTF8(nibblers.RegexpWithSyntax(testCase.re, testCase.syntaxOfRegexp), nibblers.NewUTF8StringNibbler(testCase.input))
+		if testCase.expectedMatch == "" {
+			if !errors.Is(err, nibblers.ErrParseFailed) {
+				t.Errorf("(TestReadMatchingRegexpWithSyntax) (%s) (RegexpWithSyntax parser) expected ErrParseFailed, got (%v) and (%v)", testCase.name, value, err)
+			}
+		} else if err != nil || value != testCase.expectedMatch {
+			t.Errorf("(TestReadMatchingRegexpWithSyntax) (%s) (RegexpWithSyntax parser) expected match (%q), got (%v) and (%v)", testCase.name, testCase.expectedMatch, value, err)
+		}
+	}
+}
+
+func TestReadMatchingRegexpWithManyRegexps(t *testing.T) {
+	s := ""
+	for i := 0; i < 100; i++ {
+		s += fmt.Sprintf("%d;", i)
+	}
+
+	matcher := nibblers.NewUTF8NibblerMatcher(nibblers.NewUTF8StringNibbler(s))
+	for i := 0; i < 100; i++ {
+		expectedMatch := fmt.Sprintf("%d;", i)
+		if submatches, err := matcher.ReadMatchingRegexp(regexp.MustCompile(regexp.QuoteMeta(expectedMatch))); err != nil || len(submatches) != 1 || submatches[0] != expectedMatch {
+			t.Errorf("(TestReadMatchingRegexpWithManyRegexps) (regexp %d) expected match (%s), got (%q) and (%v)", i+1, expectedMatch, submatches, err)
+		}
+	}
+}
+
+func TestReadMatchingRegexpDoesNotWaitForUnneededBytes(t *testing.T) {
+	// the regexp reads ahead of its match, into a two byte character and then an ASCII character, after which
+	// no more bytes arrive
+	pipeReader, pipeWriter := io.Pipe()
+	defer pipeWriter.Close()
+
+	go pipeWriter.Write([]byte("aéx"))
+
+	matcher := nibblers.NewByteNibblerMatcher(nibblers.NewByteReaderNibbler(pipeReader))
+
+	type matchResult struct {
+		submatches [][]byte
+		err        error
+	}
+
+	resultChannel := make(chan matchResult, 1)
+	go func() {
+		submatches, err := matcher.ReadMatchingRegexp(regexp.MustCompile(`a`))
+		resultChannel <- matchResult{submatches, err}
+	}()
+
+	select {
+	case result := <-resultChannel:
+		if result.err != nil || len(result.submatches) != 1 || string(result.submatches[0]) != "a" {
+			t.Errorf("(TestReadMatchingRegexpDoesNotWaitForUnneededBytes) expected match (a), got (%q) and (%v)", result.submatches, result.err)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("(TestReadMatchingRegexpDoesNotWaitForUnneededBytes) expected match without more input, but ReadMatchingRegexp is still waiting")
+	}
+}
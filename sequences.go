@@ -0,0 +1,45 @@
+package nibblers
+
+import (
+	"errors"
+	"fmt"
+)
+
+// UnlimitedSequenceLength can be provided as the maximum length to ReadUntilSequence or SkipUntilSequence to
+// permit any number of units before the delimiter.
+const UnlimitedSequenceLength = -1
+
+// ErrSequenceLengthExceeded is returned by ReadUntilSequence and SkipUntilSequence when the delimiter does
+// not start within the maximum length.  The cursor is not moved.
+var ErrSequenceLengthExceeded = errors.New("delimiter sequence not found within the maximum length")
+
+var errEmptyDelimiter = fmt.Errorf("delimiter sequence is empty")
+
+// DelimiterHandling determines what ReadUntilSequence and SkipUntilSequence do with the delimiter sequence
+// once it is found.
+type DelimiterHandling int
+
+const (
+	// ExcludeDelimiter stops before the delimiter, so the delimiter is the next thing read from the nibbler.
+	ExcludeDelimiter DelimiterHandling = iota
+
+	// IncludeDelimiter moves the cursor past the delimiter and includes the delimiter in the returned units.
+	IncludeDelimiter
+
+	// ConsumeDelimiter moves the cursor past the delimiter but does not include the delimiter in the
+	// returned units.
+	ConsumeDelimiter
+)
+
+// countOfUnitsToAdvance returns how far the cursor moves when the delimiter is found after
+// countOfUnitsBeforeDelimiter units, and how many of those units are returned.
+func (handling DelimiterHandling) countOfUnitsToAdvance(countOfUnitsBeforeDelimiter int, lengthOfDelimiter int) (advance int, returned int) {
+	switch handling {
+	case IncludeDelimiter:
+		return countOfUnitsBeforeDelimiter + lengthOfDelimiter, countOfUnitsBeforeDelimiter + lengthOfDelimiter
+	case ConsumeDelimiter:
+		return countOfUnitsBeforeDelimiter + lengthOfDelimiter, countOfUnitsBeforeDelimiter
+	default:
+		return countOfUnitsBeforeDelimiter, countOfUnitsBeforeDelimiter
+	}
+}
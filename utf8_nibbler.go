@@ -1,11 +1,73 @@
 package nibblers
 
 import (
-	"fmt"
+	"bytes"
+	"context"
+	"errors"
 	"io"
 	"unicode/utf8"
 )
 
+// UnlimitedBookendLength can be provided as the maximum bookend length for a reader-backed nibbler
+// to permit bookends of any length.
+const UnlimitedBookendLength = -1
+
+// ErrBookendLimitExceeded is returned when a read would extend an active bookend past the maximum
+// bookend length set for the nibbler.
+var ErrBookendLimitExceeded = errors.New("read would exceed the maximum bookend length")
+
+// InvalidUTF8Policy determines what a UTF8Nibbler that decodes bytes does when it encounters bytes that
+// are not a valid UTF8 encoding.
+type InvalidUTF8Policy int
+
+const (
+	// ReportInvalidUTF8 returns a *NibbleError wrapping ErrInvalidUTF8, with the offset of the invalid
+	// bytes, and does not advance the cursor.  This is the default.
+	ReportInvalidUTF8 InvalidUTF8Policy = iota
+
+	// ReplaceInvalidUTF8 returns each invalid byte as the character U+FFFD (utf8.RuneError), advancing the
+	// cursor by one byte.  This is the same substitution made by a Go string to []rune conversion.
+	ReplaceInvalidUTF8
+
+	// SkipInvalidUTF8 discards invalid bytes, so that the next character returned is the next valid one.
+	SkipInvalidUTF8
+)
+
+// isInvalidByte returns true if the character and length returned by a utf8 decoding function indicate an
+// invalid byte rather than a literal U+FFFD, which is three bytes long.
+func isInvalidByte(decodedCharacter rune, sizeOfCharacterInBytes int) bool {
+	return decodedCharacter == utf8.RuneError && sizeOfCharacterInBytes == 1
+}
+
+// countOfInvalidBytesHandled returns the number of invalid bytes that were skipped or replaced to produce a
+// decoded character.
+func countOfInvalidBytesHandled(decodedCharacter rune, countOfSkippedBytes int, sizeOfCharacterInBytes int) int {
+	if isInvalidByte(decodedCharacter, sizeOfCharacterInBytes) {
+		return countOfSkippedBytes + 1
+	}
+
+	return countOfSkippedBytes
+}
+
+// runesFromUTF8 converts already read bytes to runes in the way that they were read under policy.
+func runesFromUTF8(encoded string, policy InvalidUTF8Policy) []rune {
+	if policy != SkipInvalidUTF8 {
+		return []rune(encoded)
+	}
+
+	decodedRunes := make([]rune, 0, len(encoded))
+	for i := 0; i < len(encoded); {
+		decodedCharacter, sizeOfCharacterInBytes := utf8.DecodeRuneInString(encoded[i:])
+		if !isInvalidByte(decodedCharacter, sizeOfCharacterInBytes) {
+			decodedRunes = append(decodedRunes, decodedCharacter)
+		}
+
+		i += sizeOfCharacterInBytes
+	}
+
+	return decodedRunes
+}
+
 // UTF8Nibbler is any nibbler that operates on UTF8 character encodings.
 type UTF8Nibbler interface {
 	// ReadCharacter reads and returns the next character from the nibbler stream. If the cursor is past the end of the
@@ -23,6 +85,30 @@ type UTF8Nibbler interface {
 	// io.EOF or an error in the same way and for the same reason that ReadCharacter() does.
 	PeekAtNextCharacter() (rune, error)
 
+	// PeekAtNextCharacters returns the next countOfCharactersToPeek characters in the stream, but does not advance
+	// the cursor.  If fewer characters remain in the stream, the remaining characters are returned with io.EOF.  If
+	// any other error occurs, the characters before the error are returned with that error.
+	PeekAtNextCharacters(countOfCharactersToPeek uint) ([]rune, error)
+
+	// Read reads the UTF8 encoding of as many whole characters from the cursor as fit in p, and advances the
+	// cursor past them, so that a UTF8Nibbler is an io.Reader.  A character is never split across calls, so
+	// if p is too short for the next character, io.ErrShortBuffer is returned.  If p is empty, Read returns 0
+	// and no error without examining the stream.
+	Read(p []byte) (int, error)
+
+	// ReadRune reads the next character and returns it with the number of bytes that it consumed, which is
+	// the length of its UTF8 encoding unless invalid bytes were replaced or skipped (see InvalidUTF8Policy).
+	// With UnreadRune, this makes a UTF8Nibbler an io.RuneScanner.
+	ReadRune() (r rune, size int, err error)
+
+	// UnreadRune is the same as UnreadCharacter.
+	UnreadRune() error
+
+	// WriteTo writes the UTF8 encoding of every character from the cursor to the end of the stream to w,
+	// advancing the cursor past the written characters.  It returns the number of bytes written.  Reaching the
+	// end of the stream is not an error.
+	WriteTo(w io.Writer) (int64, error)
+
 	// Bookends instruct the Nibbler to preserve characters that are read in the backing store.  This starts a bookend
 	// at the next unread character (though the character may have been peeked).  In between the start and end
 	// bookends, checkpoints can be produced.  The bookend start is implicitly a checkpoint.  When a checkpoint is
@@ -38,14 +124,36 @@ type UTF8Nibbler interface {
 	// backing buffer.  If there is no active bookend (i.e., StartBookending has not been called or was not called
 	// since the last StopBookending call), and empty slice is returned.
 	StopBookending() []rune
+
+	// Position returns the location of the cursor (that is, of the next unread character).  The position remains
+	// correct when characters are unread.
+	Position() Position
+
+	// Mark returns a savepoint at the cursor.  Marks may be nested.  While a Mark is live, the nibbler retains
+	// every character from the Mark onward, so that ResetTo the Mark always succeeds.
+	Mark() Mark
+
+	// ResetTo moves the cursor to the provided Mark, which remains live.  If an active bookend starts after the
+	// Mark, the bookend is moved to start at the Mark.  Returns ErrInvalidMark if the Mark is not live.
+	ResetTo(mark Mark) error
+
+	// Release releases the provided Mark and every Mark created after it.  This permits the nibbler to discard
+	// characters that it retained for the Marks.
+	Release(mark Mark)
 }
 
 // UTF8StringNibbler is a UTF8Nibbler that operates on golang strings, treating them as UTF8 byte streams.
 type UTF8StringNibbler struct {
 	backingString                              string
 	indexInStringOfNextReadByte                int
+	countOfRunesBeforeNextReadByte             int
 	bookendStartOffsetInBackingString          int // negative if no bookend start is active
 	bookendLastCheckpointOffsetInBackingString int // negative if no bookend start is active
+	invalidUTF8Policy                          InvalidUTF8Policy
+	indexInStringAfterFurthestReadCharacter    int
+	countOfInvalidUTF8Replacements             int64
+	positions                                  *positionTracker
+	marks                                      *markRegistry
 }
 
 // NewUTF8StringNibbler creates a new UTF8StringNibbler that will operate on the provided string.
@@ -53,8 +161,47 @@ func NewUTF8StringNibbler(nibbleString string) *UTF8StringNibbler {
 	return &UTF8StringNibbler{
 		backingString:                              nibbleString,
 		indexInStringOfNextReadByte:                0,
+		countOfRunesBeforeNextReadByte:             0,
 		bookendStartOffsetInBackingString:          -1,
 		bookendLastCheckpointOffsetInBackingString: -1,
+		invalidUTF8Policy:                          ReportInvalidUTF8,
+		indexInStringAfterFurthestReadCharacter:    0,
+		countOfInvalidUTF8Replacements:             0,
+		positions:                                  newPositionTracker(),
+		marks:                                      newMarkRegistry(),
+	}
+}
+
+// SetInvalidUTF8Policy sets what the nibbler does with bytes that are not a valid UTF8 encoding.  By
+// default, ReportInvalidUTF8 is used.
+func (nibbler *UTF8StringNibbler) SetInvalidUTF8Policy(policy InvalidUTF8Policy) {
+	nibbler.invalidUTF8Policy = policy
+}
+
+// CountOfInvalidUTF8Replacements returns the number of invalid bytes that have been replaced or skipped by
+// reading characters.  A byte is counted once, even if it is read again after an unread.
+func (nibbler *UTF8StringNibbler) CountOfInvalidUTF8Replacements() int64 {
+	return nibbler.countOfInvalidUTF8Replacements
+}
+
+// decodeCharacterAt decodes the character at index i of the backing string, handling invalid bytes as
+// directed by the nibbler's InvalidUTF8Policy.  It returns the character, the number of invalid bytes
+// skipped before it, and its length in bytes.  A replaced invalid byte is returned as utf8.RuneError
+// with a length of one.
+func (nibbler *UTF8StringNibbler) decodeCharacterAt(op string, i int) (rune, int, int, error) {
+	for countOfSkippedBytes := 0; ; countOfSkippedBytes++ {
+		if i+countOfSkippedBytes >= len(nibbler.backingString) {
+			return utf8.RuneError, 0, 0, io.EOF
+		}
+
+		nextCharacter, sizeOfCharacterInBytes := utf8.DecodeRuneInString(nibbler.backingString[i+countOfSkippedBytes:])
+		if !isInvalidByte(nextCharacter, sizeOfCharacterInBytes) || nibbler.invalidUTF8Policy == ReplaceInvalidUTF8 {
+			return nextCharacter, countOfSkippedBytes, sizeOfCharacterInBytes, nil
+		}
+
+		if nibbler.invalidUTF8Policy != SkipInvalidUTF8 {
+			return utf8.RuneError, 0, 0, newNibbleError(op, int64(i+countOfSkippedBytes), ErrInvalidUTF8)
+		}
 	}
 }
 
@@ -62,16 +209,21 @@ func NewUTF8StringNibbler(nibbleString string) *UTF8StringNibbler {
 // if the read cursor is now beyond the end of the string, or an error.  If an error is returned
 // the value of the rune is undefined and the position of the pointer is undefined.
 func (nibbler *UTF8StringNibbler) ReadCharacter() (rune, error) {
-	if nibbler.indexInStringOfNextReadByte >= len(nibbler.backingString) {
-		return utf8.RuneError, io.EOF
+	nextCharacter, countOfSkippedBytes, sizeOfCharacterInBytes, err := nibbler.decodeCharacterAt("ReadCharacter", nibbler.indexInStringOfNextReadByte)
+	if err != nil {
+		return utf8.RuneError, err
 	}
 
-	nextCharacter, sizeOfCharacterInBytes := utf8.DecodeRuneInString(nibbler.backingString[nibbler.indexInStringOfNextReadByte:])
-	if nextCharacter == utf8.RuneError {
-		return utf8.RuneError, fmt.Errorf("invalid UTF-8 string element")
+	indexOfCharacter := nibbler.indexInStringOfNextReadByte + countOfSkippedBytes
+	nibbler.positions.recordCharacter(nextCharacter, int64(indexOfCharacter), int64(nibbler.countOfRunesBeforeNextReadByte), sizeOfCharacterInBytes)
+	nibbler.indexInStringOfNextReadByte = indexOfCharacter + sizeOfCharacterInBytes
+	nibbler.countOfRunesBeforeNextReadByte++
+
+	if nibbler.indexInStringOfNextReadByte > nibbler.indexInStringAfterFurthestReadCharacter {
+		nibbler.countOfInvalidUTF8Replacements += int64(countOfInvalidBytesHandled(nextCharacter, countOfSkippedBytes, sizeOfCharacterInBytes))
+		nibbler.indexInStringAfterFurthestReadCharacter = nibbler.indexInStringOfNextReadByte
 	}
 
-	nibbler.indexInStringOfNextReadByte += sizeOfCharacterInBytes
 	return nextCharacter, nil
 }
 
@@ -80,17 +232,25 @@ func (nibbler *UTF8StringNibbler) ReadCharacter() (rune, error) {
 // or if a decoding error occurs.
 func (nibbler *UTF8StringNibbler) UnreadCharacter() error {
 	s := nibbler.backingString[:nibbler.indexInStringOfNextReadByte]
-	previousRune, sizeOfPreviousRune := utf8.DecodeLastRuneInString(s)
 
-	if previousRune == utf8.RuneError {
-		if sizeOfPreviousRune == 0 {
-			return fmt.Errorf("already at start of string")
+	if nibbler.invalidUTF8Policy == SkipInvalidUTF8 {
+		for len(s) > 0 && isInvalidByte(utf8.DecodeLastRuneInString(s)) {
+			s = s[:len(s)-1]
 		}
+	}
 
-		return fmt.Errorf("invalid UTF-8 string element")
+	previousRune, sizeOfPreviousRune := utf8.DecodeLastRuneInString(s)
+
+	if sizeOfPreviousRune == 0 {
+		return newNibbleError("UnreadCharacter", 0, ErrAtStartOfStream)
 	}
 
-	nibbler.indexInStringOfNextReadByte -= sizeOfPreviousRune
+	if isInvalidByte(previousRune, sizeOfPreviousRune) && nibbler.invalidUTF8Policy == ReportInvalidUTF8 {
+		return newNibbleError("UnreadCharacter", int64(len(s)-sizeOfPreviousRune), ErrInvalidUTF8)
+	}
+
+	nibbler.indexInStringOfNextReadByte = len(s) - sizeOfPreviousRune
+	nibbler.countOfRunesBeforeNextReadByte--
 
 	return nil
 }
@@ -100,16 +260,51 @@ func (nibbler *UTF8StringNibbler) UnreadCharacter() error {
 // io.EOF is returned.  If an error occurs, it is returned and both the value of the returned
 // rune is undefined and the position of the pointer are undefined.
 func (nibbler *UTF8StringNibbler) PeekAtNextCharacter() (rune, error) {
-	if nibbler.indexInStringOfNextReadByte >= len(nibbler.backingString) {
-		return utf8.RuneError, io.EOF
+	nextCharacter, _, _, err := nibbler.decodeCharacterAt("PeekAtNextCharacter", nibbler.indexInStringOfNextReadByte)
+	if err != nil {
+		return utf8.RuneError, err
 	}
 
-	nextCharacter, _ := utf8.DecodeRuneInString(nibbler.backingString[nibbler.indexInStringOfNextReadByte:])
-	if nextCharacter == utf8.RuneError {
-		return 0, fmt.Errorf("invalid UTF-8 string element")
+	return nextCharacter, nil
+}
+
+// PeekAtNextCharacters returns the next countOfCharactersToPeek characters in the source string without
+// advancing the pointer.  If fewer characters remain, the remaining characters are returned with io.EOF.
+func (nibbler *UTF8StringNibbler) PeekAtNextCharacters(countOfCharactersToPeek uint) ([]rune, error) {
+	peekedCharacters := make([]rune, 0, countOfCharactersToPeek)
+
+	for i := nibbler.indexInStringOfNextReadByte; uint(len(peekedCharacters)) < countOfCharactersToPeek; {
+		nextCharacter, countOfSkippedBytes, sizeOfCharacterInBytes, err := nibbler.decodeCharacterAt("PeekAtNextCharacters", i)
+		if err != nil {
+			return peekedCharacters, err
+		}
+
+		peekedCharacters = append(peekedCharacters, nextCharacter)
+		i += countOfSkippedBytes + sizeOfCharacterInBytes
 	}
 
-	return nextCharacter, nil
+	return peekedCharacters, nil
+}
+
+// Read reads the UTF8 encoding of as many whole characters as fit in p.  If p is too short for the
+// next character, io.ErrShortBuffer is returned.
+func (nibbler *UTF8StringNibbler) Read(p []byte) (int, error) {
+	return readCharactersIntoByteSlice(nibbler, p)
+}
+
+// ReadRune reads the next character and returns it with the number of bytes that it consumed.
+func (nibbler *UTF8StringNibbler) ReadRune() (rune, int, error) {
+	return readRuneFrom(nibbler)
+}
+
+// UnreadRune is the same as UnreadCharacter.
+func (nibbler *UTF8StringNibbler) UnreadRune() error {
+	return nibbler.UnreadCharacter()
+}
+
+// WriteTo writes the UTF8 encoding of every unread character to w.
+func (nibbler *UTF8StringNibbler) WriteTo(w io.Writer) (int64, error) {
+	return writeCharactersTo(nibbler, w)
 }
 
 // StartBookending starts a bookend at the the next unread character.
@@ -119,7 +314,7 @@ func (nibbler *UTF8StringNibbler) StartBookending() error {
 	}
 
 	if nibbler.bookendStartOffsetInBackingString >= 0 {
-		return fmt.Errorf("a bookend is already active")
+		return newNibbleError("StartBookending", int64(nibbler.indexInStringOfNextReadByte), ErrBookendAlreadyActive)
 	}
 
 	nibbler.bookendStartOffsetInBackingString = nibbler.indexInStringOfNextReadByte
@@ -137,7 +332,7 @@ func (nibbler *UTF8StringNibbler) BookendCheckpoint() []rune {
 	s := nibbler.bookendLastCheckpointOffsetInBackingString
 	nibbler.bookendLastCheckpointOffsetInBackingString = nibbler.indexInStringOfNextReadByte
 
-	return []rune(nibbler.backingString[s:nibbler.indexInStringOfNextReadByte])
+	return runesFromUTF8(nibbler.backingString[s:nibbler.indexInStringOfNextReadByte], nibbler.invalidUTF8Policy)
 }
 
 // StopBookending returns a rune slice from the underlying string from the character
@@ -151,15 +346,59 @@ func (nibbler *UTF8StringNibbler) StopBookending() []rune {
 	nibbler.bookendStartOffsetInBackingString = -1
 	nibbler.bookendLastCheckpointOffsetInBackingString = -1
 
-	return []rune(nibbler.backingString[s:nibbler.indexInStringOfNextReadByte])
+	return runesFromUTF8(nibbler.backingString[s:nibbler.indexInStringOfNextReadByte], nibbler.invalidUTF8Policy)
+}
+
+// SetPositionTrackingOptions changes how Position() computes lines and columns.  It should be called
+// before any characters are read.
+func (nibbler *UTF8StringNibbler) SetPositionTrackingOptions(options PositionTrackingOptions) {
+	nibbler.positions.setOptions(options)
+}
+
+// Position returns the location of the cursor in the string.
+func (nibbler *UTF8StringNibbler) Position() Position {
+	return nibbler.positions.positionAt(int64(nibbler.indexInStringOfNextReadByte), int64(nibbler.countOfRunesBeforeNextReadByte))
+}
+
+// Mark returns a savepoint at the cursor.
+func (nibbler *UTF8StringNibbler) Mark() Mark {
+	return nibbler.marks.add(int64(nibbler.indexInStringOfNextReadByte), int64(nibbler.countOfRunesBeforeNextReadByte))
+}
+
+// ResetTo moves the cursor to the provided Mark.  Return ErrInvalidMark if the Mark is not live.
+func (nibbler *UTF8StringNibbler) ResetTo(mark Mark) error {
+	if !nibbler.marks.isLive(mark) {
+		return newNibbleError("ResetTo", int64(nibbler.indexInStringOfNextReadByte), ErrInvalidMark)
+	}
+
+	nibbler.indexInStringOfNextReadByte = int(mark.byteOffset)
+	nibbler.countOfRunesBeforeNextReadByte = int(mark.runeOffset)
+
+	if nibbler.bookendLastCheckpointOffsetInBackingString > nibbler.indexInStringOfNextReadByte {
+		nibbler.bookendLastCheckpointOffsetInBackingString = nibbler.indexInStringOfNextReadByte
+	}
+
+	if nibbler.bookendStartOffsetInBackingString > nibbler.indexInStringOfNextReadByte {
+		nibbler.bookendStartOffsetInBackingString = nibbler.indexInStringOfNextReadByte
+	}
+
+	return nil
+}
+
+// Release releases the provided Mark and every Mark created after it.
+func (nibbler *UTF8StringNibbler) Release(mark Mark) {
+	nibbler.marks.release(mark)
 }
 
 // UTF8RuneSliceNibbler is a concrete implementation of UTF8Nibbler. It operates on a fixed rune slice.
 type UTF8RuneSliceNibbler struct {
 	backingSlice                              []rune
 	indexOfLastReadRune                       int
+	countOfEncodedBytesThroughLastReadRune    int
 	bookendStartOffsetInBackingSlice          int // negative if no bookend start is active
-	bookendLastCheckpointOffsetInBackingSlice int
+	bookendLastCheckpointOffsetInBackingSlice int // negative if no bookend start is active
+	positions                                 *positionTracker
+	marks                                     *markRegistry
 }
 
 // NewUTF8RuneSliceNibbler returns a nibbler for the provided rune slice.
@@ -167,8 +406,11 @@ func NewUTF8RuneSliceNibbler(runeSlice []rune) *UTF8RuneSliceNibbler {
 	return &UTF8RuneSliceNibbler{
 		backingSlice:                              runeSlice,
 		indexOfLastReadRune:                       -1,
+		countOfEncodedBytesThroughLastReadRune:    0,
 		bookendStartOffsetInBackingSlice:          -1,
 		bookendLastCheckpointOffsetInBackingSlice: -1,
+		positions: newPositionTracker(),
+		marks:     newMarkRegistry(),
 	}
 }
 
@@ -180,7 +422,13 @@ func (nibbler *UTF8RuneSliceNibbler) ReadCharacter() (rune, error) {
 	}
 
 	nibbler.indexOfLastReadRune++
-	return nibbler.backingSlice[nibbler.indexOfLastReadRune], nil
+	nextRune := nibbler.backingSlice[nibbler.indexOfLastReadRune]
+	sizeOfEncodedRune := encodedLengthOfRune(nextRune)
+
+	nibbler.positions.recordCharacter(nextRune, int64(nibbler.countOfEncodedBytesThroughLastReadRune), int64(nibbler.indexOfLastReadRune), sizeOfEncodedRune)
+	nibbler.countOfEncodedBytesThroughLastReadRune += sizeOfEncodedRune
+
+	return nextRune, nil
 }
 
 // UnreadCharacter returns the next rune in the underlying slice or io.EOF if the
@@ -188,9 +436,10 @@ func (nibbler *UTF8RuneSliceNibbler) ReadCharacter() (rune, error) {
 // at the start of the slice.
 func (nibbler *UTF8RuneSliceNibbler) UnreadCharacter() error {
 	if nibbler.indexOfLastReadRune < 0 {
-		return fmt.Errorf("already at start of rune stream")
+		return newNibbleError("UnreadCharacter", 0, ErrAtStartOfStream)
 	}
 
+	nibbler.countOfEncodedBytesThroughLastReadRune -= encodedLengthOfRune(nibbler.backingSlice[nibbler.indexOfLastReadRune])
 	nibbler.indexOfLastReadRune--
 
 	return nil
@@ -206,18 +455,51 @@ func (nibbler *UTF8RuneSliceNibbler) PeekAtNextCharacter() (rune, error) {
 	return nibbler.backingSlice[nibbler.indexOfLastReadRune+1], nil
 }
 
+// PeekAtNextCharacters returns a copy of the next countOfCharactersToPeek characters in the slice without
+// advancing the cursor.  If fewer characters remain, the remaining characters are returned with io.EOF.
+func (nibbler *UTF8RuneSliceNibbler) PeekAtNextCharacters(countOfCharactersToPeek uint) ([]rune, error) {
+	remainingCharacters := nibbler.backingSlice[nibbler.indexOfLastReadRune+1:]
+
+	if uint(len(remainingCharacters)) < countOfCharactersToPeek {
+		return append([]rune(nil), remainingCharacters...), io.EOF
+	}
+
+	return append([]rune(nil), remainingCharacters[:countOfCharactersToPeek]...), nil
+}
+
+// Read reads the UTF8 encoding of as many whole characters as fit in p.  If p is too short for the
+// next character, io.ErrShortBuffer is returned.
+func (nibbler *UTF8RuneSliceNibbler) Read(p []byte) (int, error) {
+	return readCharactersIntoByteSlice(nibbler, p)
+}
+
+// ReadRune reads the next character and returns it with the number of bytes that it consumed.
+func (nibbler *UTF8RuneSliceNibbler) ReadRune() (rune, int, error) {
+	return readRuneFrom(nibbler)
+}
+
+// UnreadRune is the same as UnreadCharacter.
+func (nibbler *UTF8RuneSliceNibbler) UnreadRune() error {
+	return nibbler.UnreadCharacter()
+}
+
+// WriteTo writes the UTF8 encoding of every unread character to w.
+func (nibbler *UTF8RuneSliceNibbler) WriteTo(w io.Writer) (int64, error) {
+	return writeCharactersTo(nibbler, w)
+}
+
 // StartBookending instruct the Nibbler to preserve characters that are read in the backing store
 func (nibbler *UTF8RuneSliceNibbler) StartBookending() error {
-	if nibbler.indexOfLastReadRune >= len(nibbler.backingSlice) {
+	if nibbler.indexOfLastReadRune+1 >= len(nibbler.backingSlice) {
 		return io.EOF
 	}
 
 	if nibbler.bookendStartOffsetInBackingSlice >= 0 {
-		return fmt.Errorf("a bookend is already active")
+		return newNibbleError("StartBookending", int64(nibbler.countOfEncodedBytesThroughLastReadRune), ErrBookendAlreadyActive)
 	}
 
-	nibbler.bookendStartOffsetInBackingSlice = nibbler.indexOfLastReadRune
-	nibbler.bookendLastCheckpointOffsetInBackingSlice = nibbler.indexOfLastReadRune
+	nibbler.bookendStartOffsetInBackingSlice = nibbler.indexOfLastReadRune + 1
+	nibbler.bookendLastCheckpointOffsetInBackingSlice = nibbler.indexOfLastReadRune + 1
 
 	return nil
 }
@@ -242,12 +524,140 @@ func (nibbler *UTF8RuneSliceNibbler) StopBookending() []rune {
 
 	s := nibbler.bookendStartOffsetInBackingSlice
 	nibbler.bookendStartOffsetInBackingSlice = -1
+	nibbler.bookendLastCheckpointOffsetInBackingSlice = -1
 
 	return nibbler.backingSlice[s : nibbler.indexOfLastReadRune+1]
 }
 
+// SetPositionTrackingOptions changes how Position() computes lines and columns.  It should be called
+// before any characters are read.
+func (nibbler *UTF8RuneSliceNibbler) SetPositionTrackingOptions(options PositionTrackingOptions) {
+	nibbler.positions.setOptions(options)
+}
+
+// Position returns the location of the cursor in the slice.  The ByteOffset is the number of bytes
+// required to UTF8 encode the runes before the cursor.
+func (nibbler *UTF8RuneSliceNibbler) Position() Position {
+	return nibbler.positions.positionAt(int64(nibbler.countOfEncodedBytesThroughLastReadRune), int64(nibbler.indexOfLastReadRune+1))
+}
+
+// Mark returns a savepoint at the cursor.
+func (nibbler *UTF8RuneSliceNibbler) Mark() Mark {
+	return nibbler.marks.add(int64(nibbler.countOfEncodedBytesThroughLastReadRune), int64(nibbler.indexOfLastReadRune+1))
+}
+
+// ResetTo moves the cursor to the provided Mark.  Return ErrInvalidMark if the Mark is not live.
+func (nibbler *UTF8RuneSliceNibbler) ResetTo(mark Mark) error {
+	if !nibbler.marks.isLive(mark) {
+		return newNibbleError("ResetTo", int64(nibbler.countOfEncodedBytesThroughLastReadRune), ErrInvalidMark)
+	}
+
+	nibbler.indexOfLastReadRune = int(mark.runeOffset) - 1
+	nibbler.countOfEncodedBytesThroughLastReadRune = int(mark.byteOffset)
+
+	if nibbler.bookendLastCheckpointOffsetInBackingSlice > nibbler.indexOfLastReadRune+1 {
+		nibbler.bookendLastCheckpointOffsetInBackingSlice = nibbler.indexOfLastReadRune + 1
+	}
+
+	if nibbler.bookendStartOffsetInBackingSlice > nibbler.indexOfLastReadRune+1 {
+		nibbler.bookendStartOffsetInBackingSlice = nibbler.indexOfLastReadRune + 1
+	}
+
+	return nil
+}
+
+// Release releases the provided Mark and every Mark created after it.
+func (nibbler *UTF8RuneSliceNibbler) Release(mark Mark) {
+	nibbler.marks.release(mark)
+}
+
+// encodedLengthOfRune returns the number of bytes in the UTF8 encoding of r.  A rune that
+// cannot be encoded is encoded as utf8.RuneError.
+func encodedLengthOfRune(r rune) int {
+	if length := utf8.RuneLen(r); length > 0 {
+		return length
+	}
+
+	return utf8.RuneLen(utf8.RuneError)
+}
+
+// readCharactersIntoByteSlice reads whole characters from nibbler, writing their UTF8 encodings into p, until
+// the next character does not fit.  A character is peeked before it is read so that a character that does
+// not fit is never read (and need not be unread, which may not be possible).
+func readCharactersIntoByteSlice(nibbler UTF8Nibbler, p []byte) (int, error) {
+	countOfBytesWritten := 0
+
+	for countOfBytesWritten < len(p) {
+		nextCharacter, err := nibbler.PeekAtNextCharacter()
+		if err != nil {
+			return countOfBytesWritten, err
+		}
+
+		if encodedLengthOfRune(nextCharacter) > len(p)-countOfBytesWritten {
+			if countOfBytesWritten == 0 {
+				return 0, io.ErrShortBuffer
+			}
+
+			break
+		}
+
+		if _, err := nibbler.ReadCharacter(); err != nil {
+			return countOfBytesWritten, err
+		}
+
+		countOfBytesWritten += utf8.EncodeRune(p[countOfBytesWritten:], nextCharacter)
+	}
+
+	return countOfBytesWritten, nil
+}
+
+// readRuneFrom reads the next character from nibbler in the form expected by io.RuneReader.  The size is
+// taken from the cursor position, so it counts any invalid bytes that were replaced or skipped.
+func readRuneFrom(nibbler UTF8Nibbler) (rune, int, error) {
+	byteOffsetBefore := nibbler.Position().ByteOffset
+
+	nextCharacter, err := nibbler.ReadCharacter()
+	if err != nil {
+		return utf8.RuneError, 0, err
+	}
+
+	return nextCharacter, int(nibbler.Position().ByteOffset - byteOffsetBefore), nil
+}
+
+// writeCharactersTo drains nibbler into w through a fixed size buffer.
+func writeCharactersTo(nibbler UTF8Nibbler, w io.Writer) (int64, error) {
+	buffer := make([]byte, 4096)
+	var countOfWrittenBytes int64
+
+	for {
+		countOfBytesInBuffer, readErr := readCharactersIntoByteSlice(nibbler, buffer)
+
+		if countOfBytesInBuffer > 0 {
+			countOfBytesWrittenFromBuffer, err := w.Write(buffer[:countOfBytesInBuffer])
+			countOfWrittenBytes += int64(countOfBytesWrittenFromBuffer)
+
+			if err != nil {
+				return countOfWrittenBytes, err
+			}
+
+			if countOfBytesWrittenFromBuffer < countOfBytesInBuffer {
+				return countOfWrittenBytes, io.ErrShortWrite
+			}
+		}
+
+		if readErr != nil {
+			if readErr == io.EOF {
+				return countOfWrittenBytes, nil
+			}
+
+			return countOfWrittenBytes, readErr
+		}
+	}
+}
+
 // UTF8ByteSliceNibbler is a concrete implementation of UTF8Nibbler, operating on a
-// byte slice, which must contain only valid UTF8 sequences.
+// byte slice.  Invalid UTF8 sequences in the slice are handled according to the
+// nibbler's InvalidUTF8Policy.
 type UTF8ByteSliceNibbler struct {
 	underlyingStringNibbler *UTF8StringNibbler
 }
@@ -260,6 +670,18 @@ func NewUTF8ByteSliceNibbler(byteSlice []byte) *UTF8ByteSliceNibbler {
 	}
 }
 
+// SetInvalidUTF8Policy sets what the nibbler does with bytes that are not a valid UTF8 encoding.  By
+// default, ReportInvalidUTF8 is used.
+func (nibbler *UTF8ByteSliceNibbler) SetInvalidUTF8Policy(policy InvalidUTF8Policy) {
+	nibbler.underlyingStringNibbler.SetInvalidUTF8Policy(policy)
+}
+
+// CountOfInvalidUTF8Replacements returns the number of invalid bytes that have been replaced or skipped by
+// reading characters.  A byte is counted once, even if it is read again after an unread.
+func (nibbler *UTF8ByteSliceNibbler) CountOfInvalidUTF8Replacements() int64 {
+	return nibbler.underlyingStringNibbler.CountOfInvalidUTF8Replacements()
+}
+
 // ReadCharacter attempts to read the next UTF8 encoded character from the slice. If
 // successful, returns the next rune. If the cursor is passed the end of the slice, returns
 // io.EOF. If the bytes starting at the cursor are not valid UTF8, return an error.
@@ -280,6 +702,33 @@ func (nibbler *UTF8ByteSliceNibbler) PeekAtNextCharacter() (rune, error) {
 	return nibbler.underlyingStringNibbler.PeekAtNextCharacter()
 }
 
+// PeekAtNextCharacters returns the next countOfCharactersToPeek characters without advancing the cursor.  If
+// fewer characters remain, the remaining characters are returned with io.EOF.
+func (nibbler *UTF8ByteSliceNibbler) PeekAtNextCharacters(countOfCharactersToPeek uint) ([]rune, error) {
+	return nibbler.underlyingStringNibbler.PeekAtNextCharacters(countOfCharactersToPeek)
+}
+
+// Read reads the UTF8 encoding of as many whole characters as fit in p.  If p is too short for the next
+// character, io.ErrShortBuffer is returned.
+func (nibbler *UTF8ByteSliceNibbler) Read(p []byte) (int, error) {
+	return nibbler.underlyingStringNibbler.Read(p)
+}
+
+// ReadRune reads the next character and returns it with the number of bytes that it consumed.
+func (nibbler *UTF8ByteSliceNibbler) ReadRune() (rune, int, error) {
+	return nibbler.underlyingStringNibbler.ReadRune()
+}
+
+// UnreadRune is the same as UnreadCharacter.
+func (nibbler *UTF8ByteSliceNibbler) UnreadRune() error {
+	return nibbler.underlyingStringNibbler.UnreadRune()
+}
+
+// WriteTo writes the UTF8 encoding of every unread character to w.
+func (nibbler *UTF8ByteSliceNibbler) WriteTo(w io.Writer) (int64, error) {
+	return nibbler.underlyingStringNibbler.WriteTo(w)
+}
+
 // StartBookending instruct the Nibbler to preserve characters that are read in the backing store.
 func (nibbler *UTF8ByteSliceNibbler) StartBookending() error {
 	return nibbler.underlyingStringNibbler.StartBookending()
@@ -295,39 +744,192 @@ func (nibbler *UTF8ByteSliceNibbler) StopBookending() []rune {
 	return nibbler.underlyingStringNibbler.StopBookending()
 }
 
+// SetPositionTrackingOptions changes how Position() computes lines and columns.  It should be called
+// before any characters are read.
+func (nibbler *UTF8ByteSliceNibbler) SetPositionTrackingOptions(options PositionTrackingOptions) {
+	nibbler.underlyingStringNibbler.SetPositionTrackingOptions(options)
+}
+
+// Position returns the location of the cursor in the slice.
+func (nibbler *UTF8ByteSliceNibbler) Position() Position {
+	return nibbler.underlyingStringNibbler.Position()
+}
+
+// Mark returns a savepoint at the cursor.
+func (nibbler *UTF8ByteSliceNibbler) Mark() Mark {
+	return nibbler.underlyingStringNibbler.Mark()
+}
+
+// ResetTo moves the cursor to the provided Mark.  Return ErrInvalidMark if the Mark is not live.
+func (nibbler *UTF8ByteSliceNibbler) ResetTo(mark Mark) error {
+	return nibbler.underlyingStringNibbler.ResetTo(mark)
+}
+
+// Release releases the provided Mark and every Mark created after it.
+func (nibbler *UTF8ByteSliceNibbler) Release(mark Mark) {
+	nibbler.underlyingStringNibbler.Release(mark)
+}
+
 // UTF8ReaderNibbler is a concrete implementation of UTF8Nibbler, operating on an io.Reader().
 // It will trigger Read() when necessary to read more characters from the stream, until it reaches
-// io.EOF or an error on Read().
+// io.EOF or an error on Read().  Read bytes are retained only within a rewind window (by default,
+// DefaultMaximumUnreadDepth bytes behind the furthest read character), except that every byte from
+// the start of an active bookend, or from the oldest live Mark, onward is retained until the bookend
+// is stopped or the Mark is released.
 type UTF8ReaderNibbler struct {
-	sourceReader                     io.Reader
-	readBuffer                       []byte
-	bufferOfReadBytes                []byte
-	indexInReadBytesBufferOfNextRune int
-	indexInBufferOfBookendStart      int
-	indexInBufferOfLastCheckpoint    int
+	sourceReader                            *contextualStreamReader
+	readBuffer                              []byte
+	bufferOfReadBytes                       []byte
+	indexInReadBytesBufferOfNextRune        int
+	indexInReadBytesBufferAfterFurthestRune int
+	streamOffsetOfReadBytesBufferStart      int64
+	indexInBufferOfBookendStart             int
+	indexInBufferOfLastCheckpoint           int
+	maximumUnreadDepth                      int
+	maximumBookendLength                    int
+	isDetached                              bool
+	invalidUTF8Policy                       InvalidUTF8Policy
+	countOfInvalidUTF8Replacements          int64
+	countOfRunesBeforeNextRune              int64
+	positions                               *positionTracker
+	marks                                   *markRegistry
 }
 
-// NewUTF8ReaderNibbler returns a new UTF8ReaderNibbler using the provided reader as the source. The
-// io.Reader must only returns validly encoded UTF8 encoded bytes.
+// NewUTF8ReaderNibbler returns a new UTF8ReaderNibbler using the provided reader as the source. Bytes
+// from the io.Reader that are not validly encoded UTF8 are handled according to the nibbler's
+// InvalidUTF8Policy.
 func NewUTF8ReaderNibbler(sourceReader io.Reader) *UTF8ReaderNibbler {
 	return &UTF8ReaderNibbler{
-		sourceReader:                     sourceReader,
-		readBuffer:                       make([]byte, 9000),
-		bufferOfReadBytes:                make([]byte, 0, 9000),
-		indexInReadBytesBufferOfNextRune: 0,
-		indexInBufferOfBookendStart:      -1,
-		indexInBufferOfLastCheckpoint:    -1,
+		sourceReader:                            newContextualStreamReader(sourceReader),
+		readBuffer:                              make([]byte, 9000),
+		bufferOfReadBytes:                       make([]byte, 0, 9000),
+		indexInReadBytesBufferOfNextRune:        0,
+		indexInReadBytesBufferAfterFurthestRune: 0,
+		streamOffsetOfReadBytesBufferStart:      0,
+		indexInBufferOfBookendStart:             -1,
+		indexInBufferOfLastCheckpoint:           -1,
+		maximumUnreadDepth:                      DefaultMaximumUnreadDepth,
+		maximumBookendLength:                    UnlimitedBookendLength,
+		isDetached:                              false,
+		invalidUTF8Policy:                       ReportInvalidUTF8,
+		countOfInvalidUTF8Replacements:          0,
+		countOfRunesBeforeNextRune:              0,
+		positions:                               newPositionTracker(),
+		marks:                                   newMarkRegistry(),
+	}
+}
+
+// SetContext binds the nibbler to ctx.  When a read from the stream is needed and ctx is done (or becomes
+// done while the stream Read is blocked), the read is abandoned and ctx.Err() is returned.  No bytes from the
+// stream are lost, so the operation can be retried after binding a new context.  If the stream has a
+// SetReadDeadline method (as net.Conn and os.File do), the read deadline is used to interrupt a blocked Read.
+// Otherwise, the Read is made in a goroutine, and if ctx is done first, its result is kept for the next read
+// from the stream.  A nil ctx is the same as context.Background().
+//
+// While the nibbler is bound to a context that can be done, it owns the read deadline of the stream: each
+// Read replaces any deadline set by the caller, and clears it afterward.  While it is bound to a context
+// that can never be done, such as context.Background(), the read deadline is left alone, so the caller may
+// set its own.
+func (nibbler *UTF8ReaderNibbler) SetContext(ctx context.Context) {
+	nibbler.sourceReader.setContext(ctx)
+}
+
+// SetEmptyReadPolicy sets what the nibbler does when a Read of the stream returns no bytes and no error.
+// By default, ErrNoProgress is returned immediately.
+func (nibbler *UTF8ReaderNibbler) SetEmptyReadPolicy(policy EmptyReadPolicy) {
+	nibbler.sourceReader.setEmptyReadPolicy(policy)
+}
+
+// SetInvalidUTF8Policy sets what the nibbler does with bytes that are not a valid UTF8 encoding.  By
+// default, ReportInvalidUTF8 is used.
+func (nibbler *UTF8ReaderNibbler) SetInvalidUTF8Policy(policy InvalidUTF8Policy) {
+	nibbler.invalidUTF8Policy = policy
+}
+
+// CountOfInvalidUTF8Replacements returns the number of invalid bytes that have been replaced or skipped by
+// reading characters.  A byte is counted once, even if it is read again after an unread.
+func (nibbler *UTF8ReaderNibbler) CountOfInvalidUTF8Replacements() int64 {
+	return nibbler.countOfInvalidUTF8Replacements
+}
+
+// SetMaximumUnreadDepth sets the number of bytes behind the furthest read character that the nibbler
+// retains, and thus how far UnreadCharacter() can rewind.  Bytes outside of this window (and not inside
+// of an active bookend) are discarded the next time the nibbler reads from the underlying stream.  If
+// depth is UnlimitedUnreadDepth (or any negative value), no bytes are ever discarded.
+func (nibbler *UTF8ReaderNibbler) SetMaximumUnreadDepth(depth int) {
+	nibbler.maximumUnreadDepth = depth
+}
+
+// SetMaximumBookendLength sets the largest number of bytes that an active bookend may span.  Because
+// bookended bytes cannot be discarded, this caps the memory used by a bookend.  A ReadCharacter() that
+// would extend the bookend past this length returns ErrBookendLimitExceeded and does not advance the
+// cursor.  If length is UnlimitedBookendLength (or any negative value), bookends may grow without bound.
+func (nibbler *UTF8ReaderNibbler) SetMaximumBookendLength(length int) {
+	nibbler.maximumBookendLength = length
+}
+
+// errorAtCursor wraps err in a NibbleError for the operation op at the cursor.
+func (nibbler *UTF8ReaderNibbler) errorAtCursor(op string, err error) error {
+	return newNibbleError(op, nibbler.streamOffsetOfReadBytesBufferStart+int64(nibbler.indexInReadBytesBufferOfNextRune), err)
+}
+
+// discardBytesOutsideOfRewindWindow removes bytes from the start of the buffer of read bytes that are
+// more than maximumUnreadDepth behind the furthest read character, but never a byte at or after the
+// start of an active bookend, the oldest live Mark or the cursor.  All buffer indices are adjusted to
+// account for the discarded bytes.
+func (nibbler *UTF8ReaderNibbler) discardBytesOutsideOfRewindWindow() {
+	if nibbler.maximumUnreadDepth < 0 {
+		return
+	}
+
+	countOfBytesToDiscard := nibbler.indexInReadBytesBufferAfterFurthestRune - nibbler.maximumUnreadDepth
+	if nibbler.indexInBufferOfBookendStart >= 0 && nibbler.indexInBufferOfBookendStart < countOfBytesToDiscard {
+		countOfBytesToDiscard = nibbler.indexInBufferOfBookendStart
+	}
+
+	if oldestMarkOffset, thereIsALiveMark := nibbler.marks.oldestByteOffset(); thereIsALiveMark {
+		if indexOfOldestMark := int(oldestMarkOffset - nibbler.streamOffsetOfReadBytesBufferStart); indexOfOldestMark < countOfBytesToDiscard {
+			countOfBytesToDiscard = indexOfOldestMark
+		}
+	}
+
+	// after a ResetTo a Mark that is then released, the cursor can be further back than the unread depth,
+	// and bytes at or after the cursor must be kept
+	if nibbler.indexInReadBytesBufferOfNextRune < countOfBytesToDiscard {
+		countOfBytesToDiscard = nibbler.indexInReadBytesBufferOfNextRune
+	}
+
+	if countOfBytesToDiscard <= 0 {
+		return
+	}
+
+	countOfRetainedBytes := copy(nibbler.bufferOfReadBytes, nibbler.bufferOfReadBytes[countOfBytesToDiscard:])
+	nibbler.bufferOfReadBytes = nibbler.bufferOfReadBytes[:countOfRetainedBytes]
+	nibbler.indexInReadBytesBufferOfNextRune -= countOfBytesToDiscard
+	nibbler.indexInReadBytesBufferAfterFurthestRune -= countOfBytesToDiscard
+	nibbler.streamOffsetOfReadBytesBufferStart += int64(countOfBytesToDiscard)
+	nibbler.positions.discardEventsBefore(nibbler.streamOffsetOfReadBytesBufferStart)
+
+	if nibbler.indexInBufferOfBookendStart >= 0 {
+		nibbler.indexInBufferOfBookendStart -= countOfBytesToDiscard
+		nibbler.indexInBufferOfLastCheckpoint -= countOfBytesToDiscard
 	}
 }
 
 func (nibbler *UTF8ReaderNibbler) readFromStreamIntoReadBuffer() (bytesRead int, err error) {
+	if nibbler.isDetached {
+		return 0, newNibbleError("Read", nibbler.streamOffsetOfReadBytesBufferStart+int64(len(nibbler.bufferOfReadBytes)), ErrDetached)
+	}
+
+	nibbler.discardBytesOutsideOfRewindWindow()
+
 	countOfReadBytes, err := nibbler.sourceReader.Read(nibbler.readBuffer)
-	if err != nil {
-		return countOfReadBytes, err
+	if err == ErrNoProgress {
+		return 0, newNibbleError("Read", nibbler.streamOffsetOfReadBytesBufferStart+int64(len(nibbler.bufferOfReadBytes)), err)
 	}
 
-	if countOfReadBytes == 0 {
-		return countOfReadBytes, fmt.Errorf("nothing returned from Read()")
+	if err != nil {
+		return countOfReadBytes, err
 	}
 
 	nibbler.bufferOfReadBytes = append(nibbler.bufferOfReadBytes, nibbler.readBuffer[:countOfReadBytes]...)
@@ -335,84 +937,214 @@ func (nibbler *UTF8ReaderNibbler) readFromStreamIntoReadBuffer() (bytesRead int,
 	return countOfReadBytes, nil
 }
 
-func (nibbler *UTF8ReaderNibbler) triggerReadFromStreamIntoBufferIfNeeded() error {
-	if nibbler.indexInReadBytesBufferOfNextRune >= len(nibbler.bufferOfReadBytes) {
+// Remainder detaches the nibbler from its stream and returns an io.Reader that yields the bytes that the
+// nibbler has buffered after the cursor, followed by the rest of the stream.  After Remainder, characters
+// before the cursor can still be unread and re-read, but any operation that would read past the cursor
+// returns ErrDetached.  The EmptyReadPolicy does not apply to the returned io.Reader, which returns an empty
+// Read of the stream as it is.  Calling Remainder again returns an empty io.Reader.
+func (nibbler *UTF8ReaderNibbler) Remainder() io.Reader {
+	if nibbler.isDetached {
+		return bytes.NewReader(nil)
+	}
+
+	bufferedBytesAfterCursor := append([]byte(nil), nibbler.bufferOfReadBytes[nibbler.indexInReadBytesBufferOfNextRune:]...)
+	remainder := io.MultiReader(bytes.NewReader(bufferedBytesAfterCursor), nibbler.sourceReader.detach())
+
+	nibbler.bufferOfReadBytes = nibbler.bufferOfReadBytes[:nibbler.indexInReadBytesBufferOfNextRune]
+	nibbler.indexInReadBytesBufferAfterFurthestRune = nibbler.indexInReadBytesBufferOfNextRune
+	nibbler.isDetached = true
+
+	return remainder
+}
+
+// decodeNextRune decodes the UTF8 sequence at the cursor, reading from the stream as needed, but
+// does not advance the cursor.
+func (nibbler *UTF8ReaderNibbler) decodeNextRune(op string) (rune, int, int, error) {
+	return nibbler.decodeRuneAfterCursor(op, 0)
+}
+
+// decodeRuneAfterCursor decodes the character that starts countOfBytesAfterCursor bytes after the cursor,
+// reading from the stream as needed and handling invalid bytes as directed by the nibbler's
+// InvalidUTF8Policy.  It returns the character, the number of invalid bytes skipped before it, and its
+// length in bytes.  The distance is measured from the cursor rather than from the start of the buffer
+// because a read from the stream may discard bytes before the cursor.
+func (nibbler *UTF8ReaderNibbler) decodeRuneAfterCursor(op string, countOfBytesAfterCursor int) (rune, int, int, error) {
+	for countOfSkippedBytes := 0; ; countOfSkippedBytes++ {
+		nextRune, sizeOfRune, err := nibbler.decodeSequenceAfterCursor(countOfBytesAfterCursor + countOfSkippedBytes)
+		streamOffsetOfSequence := nibbler.streamOffsetOfReadBytesBufferStart + int64(nibbler.indexInReadBytesBufferOfNextRune+countOfBytesAfterCursor+countOfSkippedBytes)
+
+		if err == ErrTruncatedUTF8 {
+			if nibbler.invalidUTF8Policy == ReportInvalidUTF8 {
+				return utf8.RuneError, 0, 0, newNibbleError(op, streamOffsetOfSequence, ErrTruncatedUTF8)
+			}
+
+			// the bytes of a truncated encoding are invalid bytes like any other
+			nextRune, sizeOfRune = utf8.RuneError, 1
+		} else if err != nil {
+			return utf8.RuneError, 0, 0, err
+		}
+
+		if !isInvalidByte(nextRune, sizeOfRune) || nibbler.invalidUTF8Policy == ReplaceInvalidUTF8 {
+			return nextRune, countOfSkippedBytes, sizeOfRune, nil
+		}
+
+		if nibbler.invalidUTF8Policy != SkipInvalidUTF8 {
+			return utf8.RuneError, 0, 0, newNibbleError(op, streamOffsetOfSequence, ErrInvalidUTF8)
+		}
+	}
+}
+
+// decodeSequenceAfterCursor decodes the UTF8 sequence that starts countOfBytesAfterCursor bytes after the
+// cursor.  Bytes are read from the stream until the buffer holds a full sequence (in the sense of
+// utf8.FullRune) at that point, so a character whose encoding is split across stream Reads is always
+// reassembled.  If the bytes there are not a valid UTF8 encoding, it returns utf8.RuneError with a length
+// of one.  If the stream ends part way through a sequence, it returns ErrTruncatedUTF8, unwrapped.
+func (nibbler *UTF8ReaderNibbler) decodeSequenceAfterCursor(countOfBytesAfterCursor int) (rune, int, error) {
+	for !utf8.FullRune(nibbler.bufferOfReadBytes[nibbler.indexInReadBytesBufferOfNextRune+countOfBytesAfterCursor:]) {
 		if _, err := nibbler.readFromStreamIntoReadBuffer(); err != nil {
-			return err
+			if err == io.EOF && nibbler.indexInReadBytesBufferOfNextRune+countOfBytesAfterCursor < len(nibbler.bufferOfReadBytes) {
+				return utf8.RuneError, 0, ErrTruncatedUTF8
+			}
+
+			return utf8.RuneError, 0, err
 		}
 	}
 
-	return nil
+	nextRuneInByteStream, numberOfBytesConsumedByRune := utf8.DecodeRune(nibbler.bufferOfReadBytes[nibbler.indexInReadBytesBufferOfNextRune+countOfBytesAfterCursor:])
+
+	return nextRuneInByteStream, numberOfBytesConsumedByRune, nil
 }
 
 // ReadCharacter attempts to read the next UTF8 encoded character from the underlying reader. If it
-// succeeds the corresponding rune is returned.  If the reader returns io.EOF, return that. If
-// the next set of bytes read are not a valid UTF8 encoding, return an error.
+// succeeds the corresponding rune is returned.  If the reader returns io.EOF, return that, unless the
+// stream ends part way through a character, in which case return ErrTruncatedUTF8 (or, under
+// ReplaceInvalidUTF8 or SkipInvalidUTF8, handle the bytes of the partial character as invalid). If
+// the next set of bytes read are not a valid UTF8 encoding, return an error.  If a bookend is active
+// and reading the character would extend it past the maximum bookend length, return
+// ErrBookendLimitExceeded without advancing the cursor.
 func (nibbler *UTF8ReaderNibbler) ReadCharacter() (rune, error) {
-	if err := nibbler.triggerReadFromStreamIntoBufferIfNeeded(); err != nil {
+	nextRune, countOfSkippedBytes, sizeOfRune, err := nibbler.decodeNextRune("ReadCharacter")
+	if err != nil {
 		return utf8.RuneError, err
 	}
 
-	nextRuneInByteStream, numberOfBytesConsumedByRune := utf8.DecodeRune(nibbler.bufferOfReadBytes[nibbler.indexInReadBytesBufferOfNextRune:])
-	if nextRuneInByteStream != utf8.RuneError {
-		nibbler.indexInReadBytesBufferOfNextRune += numberOfBytesConsumedByRune
-		return nextRuneInByteStream, nil
-	}
+	indexOfRune := nibbler.indexInReadBytesBufferOfNextRune + countOfSkippedBytes
 
-	for bytesAddedToReadBuffer := 0; bytesAddedToReadBuffer <= 4; {
-		countOfReadBytes, err := nibbler.readFromStreamIntoReadBuffer()
-		if err != nil {
-			return utf8.RuneError, err
-		}
+	if nibbler.indexInBufferOfBookendStart >= 0 && nibbler.maximumBookendLength >= 0 &&
+		indexOfRune+sizeOfRune-nibbler.indexInBufferOfBookendStart > nibbler.maximumBookendLength {
+		return utf8.RuneError, nibbler.errorAtCursor("ReadCharacter", ErrBookendLimitExceeded)
+	}
 
-		nextRuneInByteStream, numberOfBytesConsumedByRune := utf8.DecodeRune(nibbler.bufferOfReadBytes[nibbler.indexInReadBytesBufferOfNextRune:])
-		if nextRuneInByteStream != utf8.RuneError {
-			nibbler.indexInReadBytesBufferOfNextRune += numberOfBytesConsumedByRune
-			return nextRuneInByteStream, nil
-		}
+	nibbler.positions.recordCharacter(nextRune, nibbler.streamOffsetOfReadBytesBufferStart+int64(indexOfRune), nibbler.countOfRunesBeforeNextRune, sizeOfRune)
+	nibbler.indexInReadBytesBufferOfNextRune = indexOfRune + sizeOfRune
+	nibbler.countOfRunesBeforeNextRune++
 
-		bytesAddedToReadBuffer += countOfReadBytes
+	if nibbler.indexInReadBytesBufferOfNextRune > nibbler.indexInReadBytesBufferAfterFurthestRune {
+		nibbler.countOfInvalidUTF8Replacements += int64(countOfInvalidBytesHandled(nextRune, countOfSkippedBytes, sizeOfRune))
+		nibbler.indexInReadBytesBufferAfterFurthestRune = nibbler.indexInReadBytesBufferOfNextRune
 	}
 
-	return utf8.RuneError, fmt.Errorf("invalid UTF-8 encoding in stream")
+	return nextRune, nil
 }
 
 // UnreadCharacter attempts to "return" the last read UTF8 sequence to the stream. The intermediate stored
 // buffer is constrained so UnreadCharacter() may not be able to reach the start of the stream. If the cursor
-// is at the start of the intermediate buffer, return an error.
+// is at the start of the stream, return an error.  If the previous character is outside of the rewind window
+// (see SetMaximumUnreadDepth), return ErrUnreadLimitExceeded.
 func (nibbler *UTF8ReaderNibbler) UnreadCharacter() error {
-	if nibbler.indexInReadBytesBufferOfNextRune <= 0 {
-		return fmt.Errorf("already at start of stream")
+	indexAfterPreviousRune := nibbler.indexInReadBytesBufferOfNextRune
+	if nibbler.invalidUTF8Policy == SkipInvalidUTF8 {
+		for indexAfterPreviousRune > 0 && isInvalidByte(utf8.DecodeLastRune(nibbler.bufferOfReadBytes[:indexAfterPreviousRune])) {
+			indexAfterPreviousRune--
+		}
+	}
+
+	if indexAfterPreviousRune <= 0 {
+		if nibbler.streamOffsetOfReadBytesBufferStart == 0 {
+			return newNibbleError("UnreadCharacter", 0, ErrAtStartOfStream)
+		}
+
+		return nibbler.errorAtCursor("UnreadCharacter", ErrUnreadLimitExceeded)
 	}
 
-	previousRuneInReadBuffer, bytesRequiredForPreviousRune := utf8.DecodeLastRune(nibbler.bufferOfReadBytes[:nibbler.indexInReadBytesBufferOfNextRune])
-	if previousRuneInReadBuffer == utf8.RuneError || bytesRequiredForPreviousRune == 0 {
-		return fmt.Errorf("UTF-8 decode failure")
+	previousRuneInReadBuffer, bytesRequiredForPreviousRune := utf8.DecodeLastRune(nibbler.bufferOfReadBytes[:indexAfterPreviousRune])
+	indexOfPreviousRune := indexAfterPreviousRune - bytesRequiredForPreviousRune
+
+	if nibbler.maximumUnreadDepth >= 0 && nibbler.indexInReadBytesBufferAfterFurthestRune-indexOfPreviousRune > nibbler.maximumUnreadDepth {
+		return nibbler.errorAtCursor("UnreadCharacter", ErrUnreadLimitExceeded)
 	}
 
-	nibbler.indexInReadBytesBufferOfNextRune -= bytesRequiredForPreviousRune
+	if isInvalidByte(previousRuneInReadBuffer, bytesRequiredForPreviousRune) {
+		if indexOfPreviousRune == 0 && nibbler.streamOffsetOfReadBytesBufferStart > 0 {
+			// the start of the previous character has been discarded
+			return nibbler.errorAtCursor("UnreadCharacter", ErrUnreadLimitExceeded)
+		}
+
+		if nibbler.invalidUTF8Policy != ReplaceInvalidUTF8 {
+			return newNibbleError("UnreadCharacter", nibbler.streamOffsetOfReadBytesBufferStart+int64(indexOfPreviousRune), ErrInvalidUTF8)
+		}
+	}
+
+	nibbler.indexInReadBytesBufferOfNextRune = indexOfPreviousRune
+	nibbler.countOfRunesBeforeNextRune--
 
 	return nil
 }
 
 // PeekAtNextCharacter is logically the same as ReadCharacter() followed by UnreadCharacter().
 func (nibbler *UTF8ReaderNibbler) PeekAtNextCharacter() (rune, error) {
-	nextRune, err := nibbler.ReadCharacter()
+	nextRune, _, _, err := nibbler.decodeNextRune("PeekAtNextCharacter")
 	if err != nil {
 		return utf8.RuneError, err
 	}
 
-	if err := nibbler.UnreadCharacter(); err != nil {
-		return utf8.RuneError, err
+	return nextRune, nil
+}
+
+// PeekAtNextCharacters returns the next countOfCharactersToPeek characters in the stream without advancing
+// the cursor, reading from the stream as needed.  If the stream ends first, the remaining characters are
+// returned with io.EOF.  If any other error occurs, the characters before the error are returned with that error.
+func (nibbler *UTF8ReaderNibbler) PeekAtNextCharacters(countOfCharactersToPeek uint) ([]rune, error) {
+	peekedCharacters := make([]rune, 0, countOfCharactersToPeek)
+
+	for countOfBytesAfterCursor := 0; uint(len(peekedCharacters)) < countOfCharactersToPeek; {
+		nextRune, countOfSkippedBytes, sizeOfRune, err := nibbler.decodeRuneAfterCursor("PeekAtNextCharacters", countOfBytesAfterCursor)
+		if err != nil {
+			return peekedCharacters, err
+		}
+
+		peekedCharacters = append(peekedCharacters, nextRune)
+		countOfBytesAfterCursor += countOfSkippedBytes + sizeOfRune
 	}
 
-	return nextRune, nil
+	return peekedCharacters, nil
+}
+
+// Read reads the UTF8 encoding of as many whole characters as fit in p, reading from the stream as
+// needed.  If p is too short for the next character, io.ErrShortBuffer is returned.
+func (nibbler *UTF8ReaderNibbler) Read(p []byte) (int, error) {
+	return readCharactersIntoByteSlice(nibbler, p)
+}
+
+// ReadRune reads the next character and returns it with the number of bytes that it consumed.
+func (nibbler *UTF8ReaderNibbler) ReadRune() (rune, int, error) {
+	return readRuneFrom(nibbler)
+}
+
+// UnreadRune is the same as UnreadCharacter.
+func (nibbler *UTF8ReaderNibbler) UnreadRune() error {
+	return nibbler.UnreadCharacter()
+}
+
+// WriteTo writes the UTF8 encoding of every unread character to w.
+func (nibbler *UTF8ReaderNibbler) WriteTo(w io.Writer) (int64, error) {
+	return writeCharactersTo(nibbler, w)
 }
 
 // StartBookending instruct the Nibbler to preserve characters that are read in the backing store.
 func (nibbler *UTF8ReaderNibbler) StartBookending() error {
 	if nibbler.indexInBufferOfBookendStart >= 0 {
-		return fmt.Errorf("a bookend is already active")
+		return nibbler.errorAtCursor("StartBookending", ErrBookendAlreadyActive)
 	}
 
 	nibbler.indexInBufferOfBookendStart = nibbler.indexInReadBytesBufferOfNextRune
@@ -430,7 +1162,7 @@ func (nibbler *UTF8ReaderNibbler) BookendCheckpoint() []rune {
 	s := nibbler.indexInBufferOfLastCheckpoint
 	nibbler.indexInBufferOfLastCheckpoint = nibbler.indexInReadBytesBufferOfNextRune
 
-	return []rune(string(nibbler.bufferOfReadBytes[s:nibbler.indexInReadBytesBufferOfNextRune]))
+	return runesFromUTF8(string(nibbler.bufferOfReadBytes[s:nibbler.indexInReadBytesBufferOfNextRune]), nibbler.invalidUTF8Policy)
 }
 
 // StopBookending stops the bookend at the last read character and returns a slice containing the contents of the bookend.
@@ -443,5 +1175,51 @@ func (nibbler *UTF8ReaderNibbler) StopBookending() []rune {
 	nibbler.indexInBufferOfBookendStart = -1
 	nibbler.indexInBufferOfLastCheckpoint = -1
 
-	return []rune(string(nibbler.bufferOfReadBytes[s:nibbler.indexInReadBytesBufferOfNextRune]))
+	return runesFromUTF8(string(nibbler.bufferOfReadBytes[s:nibbler.indexInReadBytesBufferOfNextRune]), nibbler.invalidUTF8Policy)
+}
+
+// SetPositionTrackingOptions changes how Position() computes lines and columns.  It should be called
+// before any characters are read.
+func (nibbler *UTF8ReaderNibbler) SetPositionTrackingOptions(options PositionTrackingOptions) {
+	nibbler.positions.setOptions(options)
+}
+
+// Position returns the location of the cursor in the stream.
+func (nibbler *UTF8ReaderNibbler) Position() Position {
+	return nibbler.positions.positionAt(nibbler.streamOffsetOfReadBytesBufferStart+int64(nibbler.indexInReadBytesBufferOfNextRune), nibbler.countOfRunesBeforeNextRune)
+}
+
+// Mark returns a savepoint at the cursor.
+func (nibbler *UTF8ReaderNibbler) Mark() Mark {
+	return nibbler.marks.add(nibbler.streamOffsetOfReadBytesBufferStart+int64(nibbler.indexInReadBytesBufferOfNextRune), nibbler.countOfRunesBeforeNextRune)
+}
+
+// ResetTo moves the cursor to the provided Mark.  Return ErrInvalidMark if the Mark is not live.
+func (nibbler *UTF8ReaderNibbler) ResetTo(mark Mark) error {
+	if !nibbler.marks.isLive(mark) {
+		return nibbler.errorAtCursor("ResetTo", ErrInvalidMark)
+	}
+
+	// a Mark after the cursor is beyond the buffer of read bytes once the nibbler is detached
+	if int(mark.byteOffset-nibbler.streamOffsetOfReadBytesBufferStart) > len(nibbler.bufferOfReadBytes) {
+		return nibbler.errorAtCursor("ResetTo", ErrInvalidMark)
+	}
+
+	nibbler.indexInReadBytesBufferOfNextRune = int(mark.byteOffset - nibbler.streamOffsetOfReadBytesBufferStart)
+	nibbler.countOfRunesBeforeNextRune = mark.runeOffset
+
+	if nibbler.indexInBufferOfLastCheckpoint > nibbler.indexInReadBytesBufferOfNextRune {
+		nibbler.indexInBufferOfLastCheckpoint = nibbler.indexInReadBytesBufferOfNextRune
+	}
+
+	if nibbler.indexInBufferOfBookendStart > nibbler.indexInReadBytesBufferOfNextRune {
+		nibbler.indexInBufferOfBookendStart = nibbler.indexInReadBytesBufferOfNextRune
+	}
+
+	return nil
+}
+
+// Release releases the provided Mark and every Mark created after it.
+func (nibbler *UTF8ReaderNibbler) Release(mark Mark) {
+	nibbler.marks.release(mark)
 }
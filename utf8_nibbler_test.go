@@ -1,9 +1,13 @@
 package nibblers_test
 
 import (
+	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
+	"strings"
 	"testing"
+	"testing/iotest"
 	"unicode/utf8"
 
 	"github.com/blorticus-go/nibblers"
@@ -791,6 +795,14 @@ func TestUTF8NibblerBookending(t *testing.T) {
 		t.Errorf(err.Error())
 	}
 
+	tester = NewBookendTester(func(nibblerString string) nibblers.UTF8Nibbler {
+		return nibblers.NewUTF8RuneSliceNibbler(stringToRuneSlice(nibblerString))
+	})
+
+	if err := bookendTestsForUTF8Nibblers(tester); err != nil {
+		t.Errorf(err.Error())
+	}
+
 	tester = NewBookendTester(func(nibblerString string) nibblers.UTF8Nibbler {
 		reader := mock.NewReader().AddGoodRead([]byte(nibblerString)).AddEOF()
 		return nibblers.NewUTF8ReaderNibbler(reader)
@@ -900,3 +912,777 @@ func bookendTestsForUTF8Nibblers(tester *BookendTester) error {
 
 	return nil
 }
+
+func repeatedUTF8StreamReader(unit string, repetitions int, bytesPerRead int) (string, *mock.Reader) {
+	completeStream := strings.Repeat(unit, repetitions)
+	reader := mock.NewReader()
+
+	for i := 0; i < len(completeStream); i += bytesPerRead {
+		end := i + bytesPerRead
+		if end > len(completeStream) {
+			end = len(completeStream)
+		}
+		reader.AddGoodRead([]byte(completeStream[i:end]))
+	}
+
+	return completeStream, reader.AddEOF()
+}
+
+func TestUTF8ReaderNibblerRewindWindow(t *testing.T) {
+	_, reader := repeatedUTF8StreamReader("aé∀", 5000, 7000)
+	nibbler := nibblers.NewUTF8ReaderNibbler(reader)
+	nibbler.SetMaximumUnreadDepth(6)
+
+	for i := 0; i < 12000; i++ {
+		if _, err := nibbler.ReadCharacter(); err != nil {
+			t.Fatalf("(TestUTF8ReaderNibblerRewindWindow) on ReadCharacter (%d) expected no error, got error = (%s)", i+1, err.Error())
+		}
+	}
+
+	// the last three characters read are "aé∀", which are 6 bytes
+	for i := 0; i < 3; i++ {
+		if err := nibbler.UnreadCharacter(); err != nil {
+			t.Fatalf("(TestUTF8ReaderNibblerRewindWindow) on UnreadCharacter (%d) expected no error, got error = (%s)", i+1, err.Error())
+		}
+	}
+
+	if err := nibbler.UnreadCharacter(); err == nil {
+		t.Errorf("(TestUTF8ReaderNibblerRewindWindow) expected ErrUnreadLimitExceeded on UnreadCharacter past window, got no error")
+	} else if !errors.Is(err, nibblers.ErrUnreadLimitExceeded) {
+		t.Errorf("(TestUTF8ReaderNibblerRewindWindow) expected ErrUnreadLimitExceeded on UnreadCharacter past window, got error = (%s)", err.Error())
+	}
+
+	if r, err := nibbler.ReadCharacter(); err != nil || r != 'a' {
+		t.Errorf("(TestUTF8ReaderNibblerRewindWindow) expected (a) on ReadCharacter after unreads, got (%c, %v)", r, err)
+	}
+}
+
+func TestUTF8ReaderNibblerBookendRetention(t *testing.T) {
+	completeStream, reader := repeatedUTF8StreamReader("aé∀", 5000, 7000)
+	nibbler := nibblers.NewUTF8ReaderNibbler(reader)
+	nibbler.SetMaximumUnreadDepth(0)
+
+	for i := 0; i < 300; i++ {
+		if _, err := nibbler.ReadCharacter(); err != nil {
+			t.Fatalf("(TestUTF8ReaderNibblerBookendRetention) on ReadCharacter (%d) expected no error, got error = (%s)", i+1, err.Error())
+		}
+	}
+
+	if err := nibbler.StartBookending(); err != nil {
+		t.Fatalf("(TestUTF8ReaderNibblerBookendRetention) expected no error on StartBookending, got error = (%s)", err.Error())
+	}
+
+	for i := 0; i < 6000; i++ {
+		if _, err := nibbler.ReadCharacter(); err != nil {
+			t.Fatalf("(TestUTF8ReaderNibblerBookendRetention) on bookended ReadCharacter (%d) expected no error, got error = (%s)", i+1, err.Error())
+		}
+	}
+
+	checkpoint := nibbler.BookendCheckpoint()
+	if err := compareRuneSets(checkpoint, []rune(strings.Repeat("aé∀", 2000))); err != nil {
+		t.Errorf("(TestUTF8ReaderNibblerBookendRetention) on checkpoint: %s", err.Error())
+	}
+
+	for i := 0; i < 6000; i++ {
+		if _, err := nibbler.ReadCharacter(); err != nil {
+			t.Fatalf("(TestUTF8ReaderNibblerBookendRetention) on bookended ReadCharacter (%d) expected no error, got error = (%s)", i+6001, err.Error())
+		}
+	}
+
+	checkpoint = nibbler.BookendCheckpoint()
+	if err := compareRuneSets(checkpoint, []rune(strings.Repeat("aé∀", 2000))); err != nil {
+		t.Errorf("(TestUTF8ReaderNibblerBookendRetention) on second checkpoint: %s", err.Error())
+	}
+
+	bookend := nibbler.StopBookending()
+	if err := compareRuneSets(bookend, []rune(completeStream[300*2:300*2+4000*6])); err != nil {
+		t.Errorf("(TestUTF8ReaderNibblerBookendRetention) on stop: %s", err.Error())
+	}
+
+	_, reader = repeatedUTF8StreamReader("aé∀", 5000, 7000)
+	nibbler = nibblers.NewUTF8ReaderNibbler(reader)
+	nibbler.SetMaximumBookendLength(5)
+
+	if err := nibbler.StartBookending(); err != nil {
+		t.Fatalf("(TestUTF8ReaderNibblerBookendRetention) with maximum bookend length, expected no error on StartBookending, got error = (%s)", err.Error())
+	}
+
+	for i := 0; i < 3; i++ {
+		if _, err := nibbler.ReadCharacter(); err != nil {
+			if !errors.Is(err, nibblers.ErrBookendLimitExceeded) {
+				t.Errorf("(TestUTF8ReaderNibblerBookendRetention) with maximum bookend length, expected ErrBookendLimitExceeded, got error = (%s)", err.Error())
+			} else if i != 2 {
+				t.Errorf("(TestUTF8ReaderNibblerBookendRetention) with maximum bookend length, expected ErrBookendLimitExceeded on third read, got it on read (%d)", i+1)
+			}
+		} else if i == 2 {
+			t.Errorf("(TestUTF8ReaderNibblerBookendRetention) with maximum bookend length, expected ErrBookendLimitExceeded on third read, got no error")
+		}
+	}
+
+	if err := compareRuneSets(nibbler.StopBookending(), []rune("aé")); err != nil {
+		t.Errorf("(TestUTF8ReaderNibblerBookendRetention) with maximum bookend length, on stop: %s", err.Error())
+	}
+
+	if r, err := nibbler.ReadCharacter(); err != nil || r != '∀' {
+		t.Errorf("(TestUTF8ReaderNibblerBookendRetention) expected (∀) on ReadCharacter after stopping bookend, got (%c, %v)", r, err)
+	}
+}
+
+type positionTestStep struct {
+	operation        string // "Read", "Unread"
+	count            int
+	expectedPosition nibblers.Position
+}
+
+type positionTrackingNibbler interface {
+	nibblers.UTF8Nibbler
+	SetPositionTrackingOptions(nibblers.PositionTrackingOptions)
+}
+
+func newPositionTrackingNibblerOfType(typeOfNibbler string, s string) positionTrackingNibbler {
+	switch typeOfNibbler {
+	case "String":
+		return nibblers.NewUTF8StringNibbler(s)
+	case "RuneSlice":
+		return nibblers.NewUTF8RuneSliceNibbler(stringToRuneSlice(s))
+	case "ByteSlice":
+		return nibblers.NewUTF8ByteSliceNibbler([]byte(s))
+	case "Reader":
+		reader := mock.NewReader().
+			AddGoodRead([]byte(s[:8])).
+			AddGoodRead([]byte(s[8:12])).
+			AddGoodRead([]byte(s[12:])).AddEOF()
+		return nibblers.NewUTF8ReaderNibbler(reader)
+	default:
+		panic(fmt.Sprintf("invalid typeOfNibbler (%s) for newPositionTrackingNibblerOfType", typeOfNibbler))
+	}
+}
+
+func testPositionStepsAgainstNibbler(nibbler nibblers.UTF8Nibbler, steps []positionTestStep) error {
+	if p := nibbler.Position(); p != (nibblers.Position{Line: 1, Column: 1}) {
+		return fmt.Errorf("expected initial position (%+v), got (%+v)", nibblers.Position{Line: 1, Column: 1}, p)
+	}
+
+	for stepIndex, step := range steps {
+		for i := 0; i < step.count; i++ {
+			var err error
+			if step.operation == "Read" {
+				_, err = nibbler.ReadCharacter()
+			} else {
+				err = nibbler.UnreadCharacter()
+			}
+
+			if err != nil {
+				return fmt.Errorf("on step %d (%s), expected no error, got error = (%s)", stepIndex+1, step.operation, err.Error())
+			}
+		}
+
+		if p := nibbler.Position(); p != step.expectedPosition {
+			return fmt.Errorf("on step %d (%s), expected position (%+v), got (%+v)", stepIndex+1, step.operation, step.expectedPosition, p)
+		}
+	}
+
+	return nil
+}
+
+func TestUTF8NibblerPosition(t *testing.T) {
+	s := "ab\tc\r\nd∀\re\n\tf"
+
+	for _, typeOfNibbler := range []string{"String", "RuneSlice", "ByteSlice", "Reader"} {
+		nibbler := newPositionTrackingNibblerOfType(typeOfNibbler, s)
+
+		if err := testPositionStepsAgainstNibbler(nibbler, []positionTestStep{
+			{operation: "Read", count: 4, expectedPosition: nibblers.Position{ByteOffset: 4, RuneOffset: 4, Line: 1, Column: 5}},
+			{operation: "Read", count: 1, expectedPosition: nibblers.Position{ByteOffset: 5, RuneOffset: 5, Line: 1, Column: 6}},
+			{operation: "Read", count: 1, expectedPosition: nibblers.Position{ByteOffset: 6, RuneOffset: 6, Line: 2, Column: 1}},
+			{operation: "Read", count: 3, expectedPosition: nibblers.Position{ByteOffset: 11, RuneOffset: 9, Line: 2, Column: 4}},
+			{operation: "Read", count: 4, expectedPosition: nibblers.Position{ByteOffset: 15, RuneOffset: 13, Line: 3, Column: 3}},
+			{operation: "Unread", count: 3, expectedPosition: nibblers.Position{ByteOffset: 12, RuneOffset: 10, Line: 2, Column: 5}},
+			{operation: "Unread", count: 5, expectedPosition: nibblers.Position{ByteOffset: 5, RuneOffset: 5, Line: 1, Column: 6}},
+			{operation: "Read", count: 1, expectedPosition: nibblers.Position{ByteOffset: 6, RuneOffset: 6, Line: 2, Column: 1}},
+		}); err != nil {
+			t.Errorf("(TestUTF8NibblerPosition) (%s nibbler with default options) %s", typeOfNibbler, err.Error())
+		}
+
+		nibbler = newPositionTrackingNibblerOfType(typeOfNibbler, s)
+		nibbler.SetPositionTrackingOptions(nibblers.PositionTrackingOptions{LineBreaks: nibblers.LineBreaksAtLFOrCR, TabWidth: 4})
+
+		if err := testPositionStepsAgainstNibbler(nibbler, []positionTestStep{
+			{operation: "Read", count: 3, expectedPosition: nibblers.Position{ByteOffset: 3, RuneOffset: 3, Line: 1, Column: 5}},
+			{operation: "Read", count: 2, expectedPosition: nibblers.Position{ByteOffset: 5, RuneOffset: 5, Line: 2, Column: 1}},
+			{operation: "Read", count: 1, expectedPosition: nibblers.Position{ByteOffset: 6, RuneOffset: 6, Line: 2, Column: 1}},
+			{operation: "Read", count: 3, expectedPosition: nibblers.Position{ByteOffset: 11, RuneOffset: 9, Line: 3, Column: 1}},
+			{operation: "Read", count: 3, expectedPosition: nibblers.Position{ByteOffset: 14, RuneOffset: 12, Line: 4, Column: 5}},
+			{operation: "Read", count: 1, expectedPosition: nibblers.Position{ByteOffset: 15, RuneOffset: 13, Line: 4, Column: 6}},
+			{operation: "Unread", count: 8, expectedPosition: nibblers.Position{ByteOffset: 5, RuneOffset: 5, Line: 2, Column: 1}},
+			{operation: "Unread", count: 1, expectedPosition: nibblers.Position{ByteOffset: 4, RuneOffset: 4, Line: 1, Column: 6}},
+			{operation: "Unread", count: 2, expectedPosition: nibblers.Position{ByteOffset: 2, RuneOffset: 2, Line: 1, Column: 3}},
+			{operation: "Read", count: 3, expectedPosition: nibblers.Position{ByteOffset: 5, RuneOffset: 5, Line: 2, Column: 1}},
+		}); err != nil {
+			t.Errorf("(TestUTF8NibblerPosition) (%s nibbler with CR line breaks and tab width 4) %s", typeOfNibbler, err.Error())
+		}
+	}
+}
+
+func TestUTF8NibblerMarks(t *testing.T) {
+	s := "∋c∍lylongi schön but \r\n ok? おはよう"
+
+	for _, typeOfNibbler := range []string{"String", "RuneSlice", "ByteSlice", "Reader"} {
+		var nibbler nibblers.UTF8Nibbler
+
+		switch typeOfNibbler {
+		case "String":
+			nibbler = nibblers.NewUTF8StringNibbler(s)
+		case "RuneSlice":
+			nibbler = nibblers.NewUTF8RuneSliceNibbler(stringToRuneSlice(s))
+		case "ByteSlice":
+			nibbler = nibblers.NewUTF8ByteSliceNibbler([]byte(s))
+		case "Reader":
+			reader := mock.NewReader()
+			for i := 0; i < len(s); i += 3 {
+				end := i + 3
+				if end > len(s) {
+					end = len(s)
+				}
+				reader.AddGoodRead([]byte(s[i:end]))
+			}
+			readerNibbler := nibblers.NewUTF8ReaderNibbler(reader.AddEOF())
+			readerNibbler.SetMaximumUnreadDepth(0)
+			nibbler = readerNibbler
+		}
+
+		outerMark := nibbler.Mark()
+		Reading(3).Characters(nibbler)
+		innerMark := nibbler.Mark()
+		innerPosition := nibbler.Position()
+		Reading(20).Characters(nibbler)
+
+		if err := nibbler.ResetTo(innerMark); err != nil {
+			t.Errorf("(TestUTF8NibblerMarks) (%s) on ResetTo inner mark expected no error, got error = (%s)", typeOfNibbler, err.Error())
+		}
+
+		if p := nibbler.Position(); p != innerPosition {
+			t.Errorf("(TestUTF8NibblerMarks) (%s) after ResetTo inner mark expected position (%+v), got (%+v)", typeOfNibbler, innerPosition, p)
+		}
+
+		if err := nibbler.StartBookending(); err != nil {
+			t.Errorf("(TestUTF8NibblerMarks) (%s) on StartBookending expected no error, got error = (%s)", typeOfNibbler, err.Error())
+		}
+
+		Reading(5).Characters(nibbler)
+		nibbler.ResetTo(outerMark)
+		Reading(2).Characters(nibbler)
+
+		if err := compareRuneSets([]rune("∋c"), nibbler.StopBookending()); err != nil {
+			t.Errorf("(TestUTF8NibblerMarks) (%s) on StopBookending after ResetTo before bookend start: %s", typeOfNibbler, err.Error())
+		}
+
+		nibbler.Release(innerMark)
+		if err := nibbler.ResetTo(innerMark); !errors.Is(err, nibblers.ErrInvalidMark) {
+			t.Errorf("(TestUTF8NibblerMarks) (%s) on ResetTo released mark expected ErrInvalidMark, got (%v)", typeOfNibbler, err)
+		}
+
+		Reading(40).Characters(nibbler)
+		if err := nibbler.ResetTo(outerMark); err != nil {
+			t.Errorf("(TestUTF8NibblerMarks) (%s) on ResetTo outer mark at end of stream expected no error, got error = (%s)", typeOfNibbler, err.Error())
+		}
+
+		if runes, err := nibblers.NewUTF8NibblerMatcher(nibbler).ReadConsecutiveCharactersNotMatching(func(r rune) bool { return false }); err != nil {
+			t.Errorf("(TestUTF8NibblerMarks) (%s) on read after ResetTo outer mark expected no error, got error = (%s)", typeOfNibbler, err.Error())
+		} else if err := compareRuneSets([]rune(s), runes); err != nil {
+			t.Errorf("(TestUTF8NibblerMarks) (%s) on read after ResetTo outer mark: %s", typeOfNibbler, err.Error())
+		}
+
+		nibbler.Release(outerMark)
+	}
+}
+
+func TestUTF8ReaderNibblerReadsAfterResetToReleasedMark(t *testing.T) {
+	s := "∋c∍lylongi schön but ok"
+
+	reader := mock.NewReader()
+	for i := 0; i < len(s); i += 4 {
+		end := i + 4
+		if end > len(s) {
+			end = len(s)
+		}
+		reader.AddGoodRead([]byte(s[i:end]))
+	}
+
+	nibbler := nibblers.NewUTF8ReaderNibbler(reader.AddEOF())
+	nibbler.SetMaximumUnreadDepth(2)
+
+	// once the mark is released, the cursor is further back than the unread depth, and the bytes after it
+	// must not be discarded when more are read from the stream
+	mark := nibbler.Mark()
+	Reading(10).Characters(nibbler)
+	nibbler.ResetTo(mark)
+	nibbler.Release(mark)
+
+	if nextCharacters, err := nibbler.PeekAtNextCharacters(14); err != nil || string(nextCharacters) != "∋c∍lylongi sch" {
+		t.Errorf("(TestUTF8ReaderNibblerReadsAfterResetToReleasedMark) on PeekAtNextCharacters expected (∋c∍lylongi sch), got (%s) and (%v)", string(nextCharacters), err)
+	}
+
+	if runes, err := nibblers.NewUTF8NibblerMatcher(nibbler).ReadConsecutiveCharactersNotMatching(func(r rune) bool { return false }); err != nil || string(runes) != s {
+		t.Errorf("(TestUTF8ReaderNibblerReadsAfterResetToReleasedMark) on reading the rest expected the whole stream, got (%s) and (%v)", string(runes), err)
+	}
+}
+
+func TestUTF8NibblerPeekAtNextCharacters(t *testing.T) {
+	s := "x≫=y<!--お"
+
+	for _, typeOfNibbler := range []string{"String", "RuneSlice", "ByteSlice", "Reader"} {
+		var nibbler nibblers.UTF8Nibbler
+
+		switch typeOfNibbler {
+		case "String":
+			nibbler = nibblers.NewUTF8StringNibbler(s)
+		case "RuneSlice":
+			nibbler = nibblers.NewUTF8RuneSliceNibbler(stringToRuneSlice(s))
+		case "ByteSlice":
+			nibbler = nibblers.NewUTF8ByteSliceNibbler([]byte(s))
+		case "Reader":
+			reader := mock.NewReader()
+			for i := 0; i < len(s); i += 2 {
+				end := i + 2
+				if end > len(s) {
+					end = len(s)
+				}
+				reader.AddGoodRead([]byte(s[i:end]))
+			}
+			readerNibbler := nibblers.NewUTF8ReaderNibbler(reader.AddEOF())
+			readerNibbler.SetMaximumUnreadDepth(0)
+			nibbler = readerNibbler
+		}
+
+		for stepIndex, step := range []struct {
+			countOfCharactersToRead  int
+			countOfCharactersToPeek  uint
+			expectedPeekedCharacters string
+			expectEOF                bool
+		}{
+			{countOfCharactersToRead: 0, countOfCharactersToPeek: 0, expectedPeekedCharacters: ""},
+			{countOfCharactersToRead: 1, countOfCharactersToPeek: 2, expectedPeekedCharacters: "≫="},
+			{countOfCharactersToRead: 0, countOfCharactersToPeek: 5, expectedPeekedCharacters: "≫=y<!"},
+			{countOfCharactersToRead: 3, countOfCharactersToPeek: 4, expectedPeekedCharacters: "<!--"},
+			{countOfCharactersToRead: 0, countOfCharactersToPeek: 9, expectedPeekedCharacters: "<!--お", expectEOF: true},
+			{countOfCharactersToRead: 5, countOfCharactersToPeek: 1, expectedPeekedCharacters: "", expectEOF: true},
+		} {
+			Reading(step.countOfCharactersToRead).Characters(nibbler)
+
+			peekedCharacters, err := nibbler.PeekAtNextCharacters(step.countOfCharactersToPeek)
+			if step.expectEOF {
+				if err != io.EOF {
+					t.Errorf("(TestUTF8NibblerPeekAtNextCharacters) (%s) (step %d) expected io.EOF, got (%v)", typeOfNibbler, stepIndex+1, err)
+				}
+			} else if err != nil {
+				t.Errorf("(TestUTF8NibblerPeekAtNextCharacters) (%s) (step %d) expected no error, got error = (%s)", typeOfNibbler, stepIndex+1, err.Error())
+			}
+
+			if err := compareRuneSets([]rune(step.expectedPeekedCharacters), peekedCharacters); err != nil {
+				t.Errorf("(TestUTF8NibblerPeekAtNextCharacters) (%s) (step %d) %s", typeOfNibbler, stepIndex+1, err.Error())
+			}
+		}
+
+		if r, err := nibbler.ReadCharacter(); err != io.EOF {
+			t.Errorf("(TestUTF8NibblerPeekAtNextCharacters) (%s) expected io.EOF on final read, got (%c) and (%v)", typeOfNibbler, r, err)
+		}
+	}
+}
+
+func TestUTF8NibblerMatcherSequences(t *testing.T) {
+	s := "/* お早う */ x ≪≫ y --> tail"
+
+	for _, typeOfNibbler := range []string{"String", "RuneSlice", "ByteSlice", "Reader"} {
+		var nibbler nibblers.UTF8Nibbler
+
+		switch typeOfNibbler {
+		case "String":
+			nibbler = nibblers.NewUTF8StringNibbler(s)
+		case "RuneSlice":
+			nibbler = nibblers.NewUTF8RuneSliceNibbler(stringToRuneSlice(s))
+		case "ByteSlice":
+			nibbler = nibblers.NewUTF8ByteSliceNibbler([]byte(s))
+		case "Reader":
+			reader := mock.NewReader()
+			for i := 0; i < len(s); i += 2 {
+				end := i + 2
+				if end > len(s) {
+					end = len(s)
+				}
+				reader.AddGoodRead([]byte(s[i:end]))
+			}
+			readerNibbler := nibblers.NewUTF8ReaderNibbler(reader.AddEOF())
+			readerNibbler.SetMaximumUnreadDepth(0)
+			nibbler = readerNibbler
+		}
+
+		matcher := nibblers.NewUTF8NibblerMatcher(nibbler)
+
+		for stepIndex, step := range []struct {
+			skip                       bool
+			delimiter                  string
+			handling                   nibblers.DelimiterHandling
+			maximumLength              int
+			expectedCharacters         string
+			expectedCountOfSkipped     int
+			expectedError              error
+			expectedNextCharacterAfter rune // 0 means io.EOF is expected
+		}{
+			{delimiter: "*/", handling: nibblers.IncludeDelimiter, maximumLength: nibblers.UnlimitedSequenceLength, expectedCharacters: "/* お早う */", expectedNextCharacterAfter: ' '},
+			{delimiter: "≪≫", handling: nibblers.ExcludeDelimiter, maximumLength: 2, expectedError: nibblers.ErrSequenceLengthExceeded, expectedNextCharacterAfter: ' '},
+			{delimiter: "≪≫", handling: nibblers.ExcludeDelimiter, maximumLength: 3, expectedCharacters: " x ", expectedNextCharacterAfter: '≪'},
+			{skip: true, delimiter: "≫", handling: nibblers.ConsumeDelimiter, maximumLength: nibblers.UnlimitedSequenceLength, expectedCountOfSkipped: 2, expectedNextCharacterAfter: ' '},
+			{delimiter: "-->", handling: nibblers.ConsumeDelimiter, maximumLength: nibblers.UnlimitedSequenceLength, expectedCharacters: " y ", expectedNextCharacterAfter: ' '},
+			{skip: true, delimiter: "--", handling: nibblers.ExcludeDelimiter, maximumLength: 4, expectedError: nibblers.ErrSequenceLengthExceeded, expectedNextCharacterAfter: ' '},
+			{delimiter: "--", handling: nibblers.ExcludeDelimiter, maximumLength: 5, expectedCharacters: " tail", expectedError: io.EOF},
+			{skip: true, delimiter: "--", handling: nibblers.ExcludeDelimiter, maximumLength: nibblers.UnlimitedSequenceLength, expectedError: io.EOF},
+		} {
+			var readCharacters []rune
+			var countOfSkippedCharacters int
+			var err error
+
+			if step.skip {
+				countOfSkippedCharacters, err = matcher.SkipUntilSequence([]rune(step.delimiter), step.handling, step.maximumLength)
+			} else {
+				readCharacters, err = matcher.ReadUntilSequence([]rune(step.delimiter), step.handling, step.maximumLength)
+			}
+
+			if !errors.Is(err, step.expectedError) {
+				t.Errorf("(TestUTF8NibblerMatcherSequences) (%s) (step %d) expected error (%v), got (%v)", typeOfNibbler, stepIndex+1, step.expectedError, err)
+			}
+
+			if err := compareRuneSets([]rune(step.expectedCharacters), readCharacters); err != nil {
+				t.Errorf("(TestUTF8NibblerMatcherSequences) (%s) (step %d) %s", typeOfNibbler, stepIndex+1, err.Error())
+			}
+
+			if countOfSkippedCharacters != step.expectedCountOfSkipped {
+				t.Errorf("(TestUTF8NibblerMatcherSequences) (%s) (step %d) expected (%d) skipped characters, got (%d)", typeOfNibbler, stepIndex+1, step.expectedCountOfSkipped, countOfSkippedCharacters)
+			}
+
+			nextCharacter, err := nibbler.PeekAtNextCharacter()
+			if step.expectedNextCharacterAfter == 0 {
+				if err != io.EOF {
+					t.Errorf("(TestUTF8NibblerMatcherSequences) (%s) (step %d) expected io.EOF on peek, got (%v)", typeOfNibbler, stepIndex+1, err)
+				}
+			} else if err != nil || nextCharacter != step.expectedNextCharacterAfter {
+				t.Errorf("(TestUTF8NibblerMatcherSequences) (%s) (step %d) expected (%c) on peek, got (%c) and (%v)", typeOfNibbler, stepIndex+1, step.expectedNextCharacterAfter, nextCharacter, err)
+			}
+		}
+	}
+}
+
+func TestUTF8NibblerMatcherSequencesWithBookendLimit(t *testing.T) {
+	for stepIndex, step := range []struct {
+		stream                     string
+		delimiter                  string
+		maximumBookendLength       int
+		isSkip                     bool
+		expectedCountOfSkipped     int
+		expectedNextCharacterAfter rune
+	}{
+		{stream: "abcdefgh;", delimiter: ";", maximumBookendLength: 3, expectedNextCharacterAfter: 'a'},
+		{stream: "ab;;cd", delimiter: ";;", maximumBookendLength: 3, expectedNextCharacterAfter: 'a'},
+		{stream: "abcdefgh;", delimiter: ";", maximumBookendLength: 4, isSkip: true, expectedCountOfSkipped: 4, expectedNextCharacterAfter: 'e'},
+	} {
+		nibbler := nibblers.NewUTF8ReaderNibbler(mock.NewReader().AddGoodRead([]byte(step.stream)).AddEOF())
+		nibbler.SetMaximumBookendLength(step.maximumBookendLength)
+		nibbler.StartBookending()
+
+		matcher := nibblers.NewUTF8NibblerMatcher(nibbler)
+
+		var err error
+		if step.isSkip {
+			var countOfSkippedCharacters int
+			countOfSkippedCharacters, err = matcher.SkipUntilSequence([]rune(step.delimiter), nibblers.ConsumeDelimiter, nibblers.UnlimitedSequenceLength)
+			if countOfSkippedCharacters != step.expectedCountOfSkipped {
+				t.Errorf("(TestUTF8NibblerMatcherSequencesWithBookendLimit) (step %d) expected (%d) skipped characters, got (%d)", stepIndex+1, step.expectedCountOfSkipped, countOfSkippedCharacters)
+			}
+		} else {
+			var readCharacters []rune
+			readCharacters, err = matcher.ReadUntilSequence([]rune(step.delimiter), nibblers.ConsumeDelimiter, nibblers.UnlimitedSequenceLength)
+			if readCharacters != nil {
+				t.Errorf("(TestUTF8NibblerMatcherSequencesWithBookendLimit) (step %d) expected no characters, got (%q)", stepIndex+1, string(readCharacters))
+			}
+		}
+
+		if !errors.Is(err, nibblers.ErrBookendLimitExceeded) {
+			t.Errorf("(TestUTF8NibblerMatcherSequencesWithBookendLimit) (step %d) expected ErrBookendLimitExceeded, got (%v)", stepIndex+1, err)
+		}
+
+		if nextCharacter, err := nibbler.PeekAtNextCharacter(); err != nil || nextCharacter != step.expectedNextCharacterAfter {
+			t.Errorf("(TestUTF8NibblerMatcherSequencesWithBookendLimit) (step %d) expected (%c) on peek, got (%c) and (%v)", stepIndex+1, step.expectedNextCharacterAfter, nextCharacter, err)
+		}
+	}
+}
+
+func testAnyUTF8NibblerForIOInterfaces(nibbler nibblers.UTF8Nibbler, baseTestName string, t *testing.T) {
+	var scanner io.RuneScanner = nibbler
+
+	for _, expected := range []struct {
+		r    rune
+		size int
+	}{{'h', 1}, {'é', 2}} {
+		if r, size, err := scanner.ReadRune(); err != nil || r != expected.r || size != expected.size {
+			t.Errorf("(%s) on ReadRune expected (%q) and (%d), got (%q) and (%d) and (%v)", baseTestName, expected.r, expected.size, r, size, err)
+		}
+	}
+
+	if err := scanner.UnreadRune(); err != nil {
+		t.Errorf("(%s) on UnreadRune expected no error, got (%s)", baseTestName, err.Error())
+	}
+
+	readBytes := make([]byte, 3)
+	if countOfReadBytes, err := nibbler.Read(readBytes); err != nil || string(readBytes[:countOfReadBytes]) != "él" {
+		t.Errorf("(%s) on Read expected (él), got (%s) and (%v)", baseTestName, readBytes[:countOfReadBytes], err)
+	}
+
+	var word string
+	var number int
+	if _, err := fmt.Fscan(nibbler, &word, &number); err != nil || word != "lo" || number != 42 {
+		t.Errorf("(%s) on fmt.Fscan expected (lo) and (42), got (%s) and (%d) and (%v)", baseTestName, word, number, err)
+	}
+
+	if r, _, err := scanner.ReadRune(); err != nil || r != ' ' {
+		t.Errorf("(%s) on ReadRune after fmt.Fscan expected (' '), got (%q) and (%v)", baseTestName, r, err)
+	}
+
+	if countOfReadBytes, err := nibbler.Read(readBytes[:2]); err != io.ErrShortBuffer || countOfReadBytes != 0 {
+		t.Errorf("(%s) on Read with buffer shorter than next character expected (0) and io.ErrShortBuffer, got (%d) and (%v)", baseTestName, countOfReadBytes, err)
+	}
+
+	var receiver strings.Builder
+	if countOfWrittenBytes, err := nibbler.WriteTo(&receiver); err != nil || countOfWrittenBytes != 7 || receiver.String() != "✓done" {
+		t.Errorf("(%s) on WriteTo expected (✓done) and (7), got (%s) and (%d) and (%v)", baseTestName, receiver.String(), countOfWrittenBytes, err)
+	}
+
+	if _, _, err := scanner.ReadRune(); err != io.EOF {
+		t.Errorf("(%s) on ReadRune at end of stream expected io.EOF, got (%v)", baseTestName, err)
+	}
+}
+
+func TestUTF8NibblerIOInterfaces(t *testing.T) {
+	stream := "héllo 42 ✓done"
+
+	testAnyUTF8NibblerForIOInterfaces(nibblers.NewUTF8StringNibbler(stream), "TestUTF8NibblerIOInterfaces UTF8StringNibbler", t)
+	testAnyUTF8NibblerForIOInterfaces(nibblers.NewUTF8RuneSliceNibbler([]rune(stream)), "TestUTF8NibblerIOInterfaces UTF8RuneSliceNibbler", t)
+	testAnyUTF8NibblerForIOInterfaces(nibblers.NewUTF8ByteSliceNibbler([]byte(stream)), "TestUTF8NibblerIOInterfaces UTF8ByteSliceNibbler", t)
+
+	reader := mock.NewReader()
+	for i := 0; i < len(stream); i += 3 {
+		end := i + 3
+		if end > len(stream) {
+			end = len(stream)
+		}
+		reader.AddGoodRead([]byte(stream[i:end]))
+	}
+
+	testAnyUTF8NibblerForIOInterfaces(nibblers.NewUTF8ReaderNibbler(reader.AddEOF()), "TestUTF8NibblerIOInterfaces UTF8ReaderNibbler", t)
+}
+
+func TestUTF8ReaderNibblerRemainder(t *testing.T) {
+	reader := mock.NewReader().AddGoodRead([]byte("naïve\nré")).AddGoodRead([]byte("sumé")).AddEOF()
+	nibbler := nibblers.NewUTF8ReaderNibbler(reader)
+
+	if _, err := nibblers.NewUTF8NibblerMatcher(nibbler).ReadUntilSequence([]rune("\n"), nibblers.ConsumeDelimiter, nibblers.UnlimitedSequenceLength); err != nil {
+		t.Fatalf("(TestUTF8ReaderNibblerRemainder) on ReadUntilSequence expected no error, got (%s)", err.Error())
+	}
+
+	if remainingBytes, err := ioutil.ReadAll(nibbler.Remainder()); err != nil || string(remainingBytes) != "résumé" {
+		t.Errorf("(TestUTF8ReaderNibblerRemainder) on Remainder expected (résumé), got (%s) and (%v)", remainingBytes, err)
+	}
+
+	if _, err := nibbler.PeekAtNextCharacter(); !errors.Is(err, nibblers.ErrDetached) {
+		t.Errorf("(TestUTF8ReaderNibblerRemainder) on PeekAtNextCharacter after Remainder expected ErrDetached, got (%v)", err)
+	}
+
+	if err := nibbler.UnreadCharacter(); err != nil {
+		t.Errorf("(TestUTF8ReaderNibblerRemainder) on UnreadCharacter after Remainder expected no error, got (%s)", err.Error())
+	}
+}
+
+type utf8NibblerWithInvalidUTF8Policy interface {
+	nibblers.UTF8Nibbler
+	SetInvalidUTF8Policy(policy nibblers.InvalidUTF8Policy)
+	CountOfInvalidUTF8Replacements() int64
+}
+
+func TestUTF8NibblerInvalidUTF8Policy(t *testing.T) {
+	s := "�a\xffb\xc3(c\xe3\x81done"
+
+	for _, testCase := range []struct {
+		policy                      nibblers.InvalidUTF8Policy
+		expectedCharacters          string
+		expectedCountOfReplacements int64
+	}{
+		{policy: nibblers.ReportInvalidUTF8, expectedCharacters: "�a", expectedCountOfReplacements: 0},
+		{policy: nibblers.ReplaceInvalidUTF8, expectedCharacters: "�a�b�(c��done", expectedCountOfReplacements: 4},
+		{policy: nibblers.SkipInvalidUTF8, expectedCharacters: "�ab(cdone", expectedCountOfReplacements: 4},
+	} {
+		for _, typeOfNibbler := range []string{"String", "ByteSlice", "Reader"} {
+			var nibbler utf8NibblerWithInvalidUTF8Policy
+
+			switch typeOfNibbler {
+			case "String":
+				nibbler = nibblers.NewUTF8StringNibbler(s)
+			case "ByteSlice":
+				nibbler = nibblers.NewUTF8ByteSliceNibbler([]byte(s))
+			case "Reader":
+				reader := mock.NewReader()
+				for i := 0; i < len(s); i += 2 {
+					end := i + 2
+					if end > len(s) {
+						end = len(s)
+					}
+					reader.AddGoodRead([]byte(s[i:end]))
+				}
+				nibbler = nibblers.NewUTF8ReaderNibbler(reader.AddEOF().AddEOF())
+			}
+
+			nibbler.SetInvalidUTF8Policy(testCase.policy)
+			nibbler.StartBookending()
+
+			readCharacters := make([]rune, 0, len(s))
+			var err error
+			for {
+				var r rune
+				if r, err = nibbler.ReadCharacter(); err != nil {
+					break
+				}
+				readCharacters = append(readCharacters, r)
+			}
+
+			if testCase.policy == nibblers.ReportInvalidUTF8 {
+				var nibbleError *nibblers.NibbleError
+				if !errors.As(err, &nibbleError) || !errors.Is(err, nibblers.ErrInvalidUTF8) || nibbleError.Offset != 4 {
+					t.Errorf("(TestUTF8NibblerInvalidUTF8Policy) (%s) (policy %d) expected ErrInvalidUTF8 at offset 4, got (%v)", typeOfNibbler, testCase.policy, err)
+				}
+			} else if err != io.EOF {
+				t.Errorf("(TestUTF8NibblerInvalidUTF8Policy) (%s) (policy %d) expected io.EOF, got (%v)", typeOfNibbler, testCase.policy, err)
+			}
+
+			if err := compareRuneSets([]rune(testCase.expectedCharacters), readCharacters); err != nil {
+				t.Errorf("(TestUTF8NibblerInvalidUTF8Policy) (%s) (policy %d) on ReadCharacter %s", typeOfNibbler, testCase.policy, err.Error())
+			}
+
+			for range readCharacters {
+				if err := nibbler.UnreadCharacter(); err != nil {
+					t.Errorf("(TestUTF8NibblerInvalidUTF8Policy) (%s) (policy %d) on UnreadCharacter expected no error, got (%s)", typeOfNibbler, testCase.policy, err.Error())
+				}
+			}
+
+			if err := nibbler.UnreadCharacter(); !errors.Is(err, nibblers.ErrAtStartOfStream) {
+				t.Errorf("(TestUTF8NibblerInvalidUTF8Policy) (%s) (policy %d) on UnreadCharacter at start expected ErrAtStartOfStream, got (%v)", typeOfNibbler, testCase.policy, err)
+			}
+
+			peekedCharacters, _ := nibbler.PeekAtNextCharacters(uint(len(s)))
+			if err := compareRuneSets([]rune(testCase.expectedCharacters), peekedCharacters); err != nil {
+				t.Errorf("(TestUTF8NibblerInvalidUTF8Policy) (%s) (policy %d) on PeekAtNextCharacters %s", typeOfNibbler, testCase.policy, err.Error())
+			}
+
+			Reading(len(readCharacters)).Characters(nibbler)
+			if err := compareRuneSets([]rune(testCase.expectedCharacters), nibbler.StopBookending()); err != nil {
+				t.Errorf("(TestUTF8NibblerInvalidUTF8Policy) (%s) (policy %d) on StopBookending %s", typeOfNibbler, testCase.policy, err.Error())
+			}
+
+			if count := nibbler.CountOfInvalidUTF8Replacements(); count != testCase.expectedCountOfReplacements {
+				t.Errorf("(TestUTF8NibblerInvalidUTF8Policy) (%s) (policy %d) expected (%d) replacements, got (%d)", typeOfNibbler, testCase.policy, testCase.expectedCountOfReplacements, count)
+			}
+		}
+	}
+}
+
+func TestUTF8NibblerReadRuneSizesWithInvalidUTF8Policy(t *testing.T) {
+	s := "a\xffb\xc3(c\xe3\x81é"
+
+	for _, policy := range []nibblers.InvalidUTF8Policy{nibblers.ReplaceInvalidUTF8, nibblers.SkipInvalidUTF8} {
+		for _, typeOfNibbler := range []string{"String", "ByteSlice", "Reader"} {
+			var nibbler utf8NibblerWithInvalidUTF8Policy
+
+			switch typeOfNibbler {
+			case "String":
+				nibbler = nibblers.NewUTF8StringNibbler(s)
+			case "ByteSlice":
+				nibbler = nibblers.NewUTF8ByteSliceNibbler([]byte(s))
+			case "Reader":
+				nibbler = nibblers.NewUTF8ReaderNibbler(mock.NewReader().AddGoodRead([]byte(s)).AddEOF())
+			}
+
+			nibbler.SetInvalidUTF8Policy(policy)
+
+			sumOfSizes := 0
+			for {
+				_, size, err := nibbler.ReadRune()
+				if err != nil {
+					break
+				}
+				sumOfSizes += size
+			}
+
+			if sumOfSizes != len(s) {
+				t.Errorf("(TestUTF8NibblerReadRuneSizesWithInvalidUTF8Policy) (%s) (policy %d) expected sizes to sum to (%d), got (%d)", typeOfNibbler, policy, len(s), sumOfSizes)
+			}
+		}
+	}
+}
+
+func TestUTF8ReaderNibblerSplitCharacters(t *testing.T) {
+	s := "a≫b𝄞c\U0001F600おé"
+
+	for _, testCase := range []struct {
+		description string
+		reader      io.Reader
+	}{
+		{description: "OneByteReader", reader: iotest.OneByteReader(strings.NewReader(s))},
+		{description: "HalfReader", reader: iotest.HalfReader(strings.NewReader(s))},
+		{description: "DataErrReader", reader: iotest.DataErrReader(iotest.OneByteReader(strings.NewReader(s)))},
+	} {
+		nibbler := nibblers.NewUTF8ReaderNibbler(testCase.reader)
+		nibbler.SetMaximumUnreadDepth(0)
+
+		peekedCharacters, err := nibbler.PeekAtNextCharacters(3)
+		if err != nil {
+			t.Errorf("(TestUTF8ReaderNibblerSplitCharacters) (%s) on PeekAtNextCharacters expected no error, got (%s)", testCase.description, err.Error())
+		} else if err := compareRuneSets([]rune("a≫b"), peekedCharacters); err != nil {
+			t.Errorf("(TestUTF8ReaderNibblerSplitCharacters) (%s) on PeekAtNextCharacters %s", testCase.description, err.Error())
+		}
+
+		readCharacters := make([]rune, 0, len(s))
+		for {
+			r, err := nibbler.ReadCharacter()
+			if err != nil {
+				if err != io.EOF {
+					t.Errorf("(TestUTF8ReaderNibblerSplitCharacters) (%s) expected io.EOF at end of stream, got (%v)", testCase.description, err)
+				}
+				break
+			}
+			readCharacters = append(readCharacters, r)
+		}
+
+		if err := compareRuneSets([]rune(s), readCharacters); err != nil {
+			t.Errorf("(TestUTF8ReaderNibblerSplitCharacters) (%s) on ReadCharacter %s", testCase.description, err.Error())
+		}
+	}
+
+	truncated := "a≫\xf0\x9d\x84"
+
+	nibbler := nibblers.NewUTF8ReaderNibbler(iotest.OneByteReader(strings.NewReader(truncated)))
+	Reading(2).Characters(nibbler)
+	for attempt := 1; attempt <= 2; attempt++ {
+		_, err := nibbler.ReadCharacter()
+		var nibbleError *nibblers.NibbleError
+		if !errors.Is(err, nibblers.ErrTruncatedUTF8) || !errors.As(err, &nibbleError) || nibbleError.Offset != 4 {
+			t.Errorf("(TestUTF8ReaderNibblerSplitCharacters) (attempt %d) expected ErrTruncatedUTF8 at offset 4, got (%v)", attempt, err)
+		}
+	}
+
+	nibbler = nibblers.NewUTF8ReaderNibbler(iotest.OneByteReader(strings.NewReader(truncated)))
+	nibbler.SetInvalidUTF8Policy(nibblers.ReplaceInvalidUTF8)
+	readCharacters, err := nibblers.NewUTF8NibblerMatcher(nibbler).ReadConsecutiveCharactersMatching(func(r rune) bool { return true })
+	if err != nil {
+		t.Errorf("(TestUTF8ReaderNibblerSplitCharacters) (ReplaceInvalidUTF8) expected no error, got (%s)", err.Error())
+	}
+	if err := compareRuneSets([]rune("a≫���"), readCharacters); err != nil {
+		t.Errorf("(TestUTF8ReaderNibblerSplitCharacters) (ReplaceInvalidUTF8) %s", err.Error())
+	}
+}